@@ -0,0 +1,39 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that holds no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps the content of attachments, the metadata lives with the
+// todo.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing what was
+	// there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get reads length bytes of key starting at offset, the rest of the
+	// blob when length is negative.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey rejects keys that could escape the store, keys are slash
+// separated like "todos/<id>/<attachment>".
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
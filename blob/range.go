@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrRange is returned by ParseRange for a range that lies outside the
+// blob, answered with 416.
+var ErrRange = errors.New("range not satisfiable")
+
+// Range is a span of bytes of a blob.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// ContentRange is the Content-Range header answering r for a blob of size
+// bytes.
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Offset, r.Offset+r.Length-1, size)
+}
+
+// ParseRange reads the Range header of a request for a blob of size bytes.
+// It returns nil when the whole blob should be sent: without a header, or
+// for a header asking for several ranges, which is allowed to be ignored.
+func ParseRange(header string, size int64) (*Range, error) {
+	if header == "" {
+		return nil, nil
+	}
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, ErrRange
+	}
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, ErrRange
+	}
+
+	if first == "" {
+		// the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return nil, ErrRange
+		}
+		n = min(n, size)
+		return &Range{Offset: size - n, Length: n}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return nil, ErrRange
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return nil, ErrRange
+		}
+		end = min(end, size-1)
+	}
+	return &Range{Offset: start, Length: end - start + 1}, nil
+}
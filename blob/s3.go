@@ -0,0 +1,223 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDate         = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Store keeps blobs in a bucket of an S3 compatible service, addressed
+// path style so it works against MinIO and the like. Requests are signed
+// with AWS signature version 4, the payload itself is not signed so uploads
+// stream without being buffered.
+type S3Store struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) *S3Store {
+	return &S3Store{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		client:    &http.Client{},
+		now:       time.Now,
+	}
+}
+
+func (s *S3Store) request(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, s.Endpoint+"/"+uriEncode(s.Bucket, true)+"/"+uriEncode(key, false), body)
+}
+
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, unsignedPayload, s.now())
+	return s.client.Do(req)
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case length == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	defer res.Body.Close()
+	return nil, s3Error(res)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return s3Error(res)
+}
+
+func s3Error(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("s3: unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// sign adds the signature version 4 authorization header to req, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("x-amz-date", now.Format(amzDate))
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-type" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(req.URL.EscapedPath() + "\n")
+	canonical.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + payloadHash)
+
+	day := now.Format("20060102")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDate) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters of RFC 3986,
+// which is what signature version 4 expects. Slashes are kept unless
+// encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
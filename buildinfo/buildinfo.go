@@ -0,0 +1,116 @@
+// Package buildinfo describes the running binary: the version and VCS
+// state it was built from, the stores it talks to and the optional features
+// turned on.
+package buildinfo
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Started is when the process started.
+var Started = time.Now()
+
+// Info is what /version and the version command answer.
+type Info struct {
+	// Version is the module version, (devel) when built from a checkout.
+	Version  string `json:"version"`
+	Revision string `json:"revision,omitempty"`
+	Modified bool   `json:"modified"`
+	// CommitTime is the time of Revision, BuildTime the one given by the
+	// build flags if any.
+	CommitTime string            `json:"commit_time,omitempty"`
+	BuildTime  string            `json:"build_time,omitempty"`
+	GoVersion  string            `json:"go_version"`
+	Stores     []Store           `json:"stores,omitempty"`
+	Features   map[string]string `json:"features"`
+	StartTime  *time.Time        `json:"start_time,omitempty"`
+	Uptime     string            `json:"uptime,omitempty"`
+}
+
+// Store is a store the service is connected to.
+type Store struct {
+	Name    string `json:"name"`
+	Driver  string `json:"driver"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Read reads the build of the binary. commit and buildTime are the values
+// set with -ldflags -X, commit is only used when the binary carries no VCS
+// revision, e.g. when built without the .git directory.
+func Read(commit, buildTime string) Info {
+	info := Info{Version: "(devel)", GoVersion: runtime.Version(), BuildTime: buildTime, Features: map[string]string{}}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Version != "" {
+			info.Version = bi.Main.Version
+		}
+		info.GoVersion = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.time":
+				info.CommitTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Revision == "" && commit != "dev" {
+		info.Revision = commit
+	}
+	return info
+}
+
+// SetUptime adds the start of the process and its uptime, only the server
+// has one worth reporting.
+func (i *Info) SetUptime(now time.Time) {
+	start := Started
+	i.StartTime = &start
+	i.Uptime = now.Sub(Started).Truncate(time.Second).String()
+}
+
+// Print writes info for a terminal.
+func Print(w io.Writer, info Info) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "version\t%s\n", info.Version)
+	if info.Revision != "" {
+		revision := info.Revision
+		if info.Modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(tw, "revision\t%s\n", revision)
+	}
+	if info.CommitTime != "" {
+		fmt.Fprintf(tw, "commit time\t%s\n", info.CommitTime)
+	}
+	if info.BuildTime != "" {
+		fmt.Fprintf(tw, "build time\t%s\n", info.BuildTime)
+	}
+	fmt.Fprintf(tw, "go\t%s\n", info.GoVersion)
+	for _, s := range info.Stores {
+		version := s.Version
+		if s.Error != "" {
+			version = "error: " + s.Error
+		}
+		fmt.Fprintf(tw, "store %s\t%s %s\n", s.Name, s.Driver, version)
+	}
+	names := make([]string, 0, len(info.Features))
+	for name := range info.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(tw, "feature %s\t%s\n", name, info.Features[name])
+	}
+	if info.Uptime != "" {
+		fmt.Fprintf(tw, "uptime\t%s\n", info.Uptime)
+	}
+	return tw.Flush()
+}
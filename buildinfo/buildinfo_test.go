@@ -0,0 +1,59 @@
+package buildinfo
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	info := Read("dev", "")
+	if info.GoVersion != runtime.Version() || info.Version == "" {
+		t.Errorf("want the version and go version of the binary, got %+v", info)
+	}
+	if info.Revision != "" {
+		t.Errorf("want no revision in a test binary, got %q", info.Revision)
+	}
+	if info := Read("abc1234", "2026-10-19T10:00:00UTC"); info.Revision != "abc1234" || info.BuildTime != "2026-10-19T10:00:00UTC" {
+		t.Errorf("want the build flags used, got %+v", info)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	info := Info{
+		Version:   "v1.2.0",
+		Revision:  "abc1234",
+		Modified:  true,
+		GoVersion: "go1.23.2",
+		Stores: []Store{
+			{Name: "mongo", Driver: "mongodb", Version: "6.0.1"},
+			{Name: "gorm", Driver: "sqlite", Error: "database is locked"},
+		},
+		Features: map[string]string{"grpc_port": "50051", "blob_store": "s3"},
+	}
+	info.SetUptime(Started.Add(90*time.Minute + 500*time.Millisecond))
+
+	var buf bytes.Buffer
+	if err := Print(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, strings.Join(strings.Fields(line), " "))
+	}
+	want := []string{
+		"version v1.2.0",
+		"revision abc1234 (modified)",
+		"go go1.23.2",
+		"store mongo mongodb 6.0.1",
+		"store gorm sqlite error: database is locked",
+		"feature blob_store s3",
+		"feature grpc_port 50051",
+		"uptime 1h30m0s",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sing3demons/todoapi/buildinfo"
+	"github.com/sing3demons/todoapi/logquery"
+	"github.com/sing3demons/todoapi/logship"
+)
+
+const usage = `usage:
+  todoapi                serve the API
+  todoapi logs ship      forward the rotated log archives to a sink
+  todoapi logs query     find transactions of the detail log
+  todoapi version        print the build, the store versions and the features
+`
+
+// command runs the subcommand of args instead of the server and returns
+// its exit code.
+func command(args []string) int {
+	// os.Exit skips the deferred close of main
+	defer sinks.Close()
+
+	if len(args) >= 2 && args[0] == "logs" && args[1] == "ship" {
+		return logsShip(args[2:])
+	}
+	if len(args) >= 2 && args[0] == "logs" && args[1] == "query" {
+		return logsQuery(args[2:], os.Stdout)
+	}
+	if len(args) >= 1 && args[0] == "version" {
+		return versionCommand(args[1:], os.Stdout)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n%s", strings.Join(args, " "), usage)
+	return 2
+}
+
+func logsShip(args []string) int {
+	fs := flag.NewFlagSet("logs ship", flag.ContinueOnError)
+	dir := fs.String("dir", "logs/details", "directory of the log archives")
+	sinkType := fs.String("sink", "file", "where the lines go, file or http")
+	out := fs.String("out", "logs/shipped.log", "file the lines are appended to with -sink file")
+	url := fs.String("url", os.Getenv("LOG_SHIP_URL"), "endpoint the lines are posted to with -sink http")
+	checkpoint := fs.String("checkpoint", "", "file recording the progress, in -dir by default")
+	batch := fs.Int("batch", 500, "lines sent at once")
+	interval := fs.Duration("interval", 0, "ship again at this interval until stopped, 0 ships once")
+	headers := map[string]string{}
+	fs.Func("header", `header added to the requests of -sink http, "Name: value", repeatable`, func(v string) error {
+		name, value, ok := strings.Cut(v, ":")
+		if !ok {
+			return errors.New(`want "Name: value"`)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var sink logship.Sink
+	switch *sinkType {
+	case "file":
+		sink = logship.NewFileSink(*out)
+	case "http":
+		if *url == "" {
+			fmt.Fprintln(os.Stderr, "-url or LOG_SHIP_URL is required with -sink http")
+			return 2
+		}
+		s := logship.NewHTTPSink(*url)
+		s.Headers = headers
+		sink = s
+	default:
+		fmt.Fprintf(os.Stderr, "unknown sink %q\n", *sinkType)
+		return 2
+	}
+
+	shipper := logship.NewShipper(*dir, sink, log)
+	shipper.Compressed = compressedLog(*dir)
+	if *checkpoint != "" {
+		shipper.Checkpoint = *checkpoint
+	}
+	if *batch > 0 {
+		shipper.BatchSize = *batch
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *interval > 0 {
+		shipper.Interval = *interval
+		go func() {
+			<-ctx.Done()
+			shipper.Shutdown(context.Background())
+		}()
+		shipper.Serve()
+		return 0
+	}
+
+	res, err := shipper.Ship(ctx)
+	json.NewEncoder(os.Stdout).Encode(res)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func logsQuery(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("logs query", flag.ContinueOnError)
+	dir := fs.String("dir", "logs/details", "directory of the log files")
+	var f logquery.Filter
+	fs.StringVar(&f.Session, "session", "", "session of the transactions")
+	fs.StringVar(&f.Event, "event", "", "name of the transaction or of one of its events, e.g. mongo.list_todo")
+	fs.StringVar(&f.Node, "node", "", "node of one of the events, e.g. mongo")
+	since := fs.String("since", "", "earliest start, a time as RFC 3339 or a duration before now, e.g. 1h")
+	until := fs.String("until", "", "latest start, a time as RFC 3339 or a duration before now")
+	fs.BoolVar(&f.Errors, "errors", false, "only transactions with an error")
+	limit := fs.Int("limit", 0, "stop after this many transactions, 0 prints all")
+	asJSON := fs.Bool("json", false, "print a JSON line per transaction instead of timelines")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var err error
+	now := time.Now()
+	if f.Since, err = parseSince(*since, now); err != nil {
+		fmt.Fprintln(os.Stderr, "-since:", err)
+		return 2
+	}
+	if f.Until, err = parseSince(*until, now); err != nil {
+		fmt.Fprintln(os.Stderr, "-until:", err)
+		return 2
+	}
+
+	found := 0
+	enc := json.NewEncoder(w)
+	err = logquery.Query(*dir, f, func(t logquery.Transaction) error {
+		found++
+		if *asJSON {
+			if err := enc.Encode(t); err != nil {
+				return err
+			}
+		} else {
+			if found > 1 {
+				fmt.Fprintln(w)
+			}
+			if err := logquery.Print(w, t); err != nil {
+				return err
+			}
+		}
+		if *limit > 0 && found >= *limit {
+			return logquery.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if found == 0 {
+		fmt.Fprintln(os.Stderr, "no matching transactions")
+		return 1
+	}
+	return 0
+}
+
+// versionCommand prints what /version answers, without the uptime. The
+// store is only reached when MONGO_URI is set.
+func versionCommand(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 5*time.Second, "time given to the stores to answer")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn := db{}
+	if os.Getenv("MONGO_URI") != "" {
+		conn.client = connectMongo()
+		defer conn.client.Disconnect(context.Background())
+	}
+	info := version(ctx, &conn)
+
+	var err error
+	if *asJSON {
+		err = json.NewEncoder(w).Encode(info)
+	} else {
+		err = buildinfo.Print(w, info)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// parseSince reads a time as RFC 3339 or as a duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// compressedLog reports whether a file sink of the log compresses the
+// backups it rotates into dir.
+func compressedLog(dir string) bool {
+	for _, c := range sinks.Files() {
+		if c.Compress && filepath.Dir(c.Path) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// logDirs lists the directories the file sinks of the log write to.
+func logDirs() []string {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, c := range sinks.Files() {
+		if dir := filepath.Dir(c.Path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// newShipper forwards the archives of the log to LOG_SHIP_URL from the
+// server, every LOG_SHIP_INTERVAL.
+func newShipper(url string) *logship.Shipper {
+	shipper := logship.NewShipper("logs/details", logship.NewHTTPSink(url), log)
+	shipper.Compressed = compressedLog("logs/details")
+	if d, err := time.ParseDuration(os.Getenv("LOG_SHIP_INTERVAL")); err == nil && d > 0 {
+		shipper.Interval = d
+	}
+	return shipper
+}
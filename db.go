@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
+	"github.com/sing3demons/todoapi/buildinfo"
+	"github.com/sing3demons/todoapi/health"
+	"github.com/sing3demons/todoapi/metrics"
 	"github.com/sing3demons/todoapi/model"
 	"github.com/sing3demons/todoapi/store"
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,18 +17,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 func connectDB() *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(os.Getenv("DB_CONN")), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: gormLog{},
 	})
 	if err != nil {
 		panic("failed to connect database")
 	}
+	if sqlDB, err := db.DB(); err == nil {
+		metrics.RegisterDB("gorm", sqlDB)
+	}
 
-	if err := db.AutoMigrate(&model.Todo{}); err != nil {
+	if err := db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.Invitation{}, &model.OutboxEntry{}, &model.AuditEntry{}, &model.Reminder{}, &model.Lease{}, &model.Webhook{}, &model.Delivery{}); err != nil {
 		log.Error("failed to migrate", slog.Any("error", err))
 	}
 
@@ -32,14 +38,21 @@ func connectDB() *gorm.DB {
 }
 
 func connectMongo() *mongo.Client {
-	loggerOptions := options.Logger().SetComponentLevel(options.LogComponentCommand, options.LogLevelDebug)
+	// the sink drops what's below the level of the mongo component
+	loggerOptions := options.Logger().
+		SetComponentLevel(options.LogComponentCommand, options.LogLevelDebug).
+		SetSink(mongoSink{l: mongoLog})
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	uri := os.Getenv("MONGO_URI")
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetLoggerOptions(loggerOptions))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).
+		SetLoggerOptions(loggerOptions).
+		SetPoolMonitor(metrics.MongoPoolMonitor()))
 	// client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		log.Error("failed to connect mongodb", slog.Any("error", err))
+		// connect only fails on a bad configuration, the server itself is
+		// reached lazily and checked by the readiness probe
+		panic(fmt.Sprintf("failed to connect mongodb: %v", err))
 	}
 
 	// defer client.Disconnect(ctx)
@@ -48,10 +61,34 @@ func connectMongo() *mongo.Client {
 
 type db struct {
 	client *mongo.Client
+	sql    *gorm.DB
+}
+
+func (d *db) gorm() *gorm.DB {
+	if d.sql == nil {
+		d.sql = connectDB()
+	}
+	return d.sql
 }
 
 func (d *db) GormStore() *store.GormStore {
-	return store.NewGormStore(connectDB())
+	return store.NewGormStore(d.gorm())
+}
+
+func (d *db) GormOutboxStore() *store.GormOutboxStore {
+	return store.NewGormOutboxStore(d.gorm())
+}
+
+func (d *db) GormAuditStore() *store.GormAuditStore {
+	return store.NewGormAuditStore(d.gorm())
+}
+
+func (d *db) GormReminderStore() *store.GormReminderStore {
+	return store.NewGormReminderStore(d.gorm())
+}
+
+func (d *db) GormWebhookStore() *store.GormWebhookStore {
+	return store.NewGormWebhookStore(d.gorm())
 }
 
 func (d *db) MongoStore() *store.MongoStore {
@@ -65,13 +102,163 @@ func (d *db) MongoStore() *store.MongoStore {
 		{
 			Keys: bson.D{bson.E{Key: "created_at", Value: 1}},
 		},
+		{
+			Keys: bson.D{bson.E{Key: "parent_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "list_id", Value: 1}, {Key: "rank", Value: 1}},
+		},
+	})
+	client.Database("myapp").Collection("lists").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	client.Database("myapp").Collection("list_members").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "list_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+	client.Database("myapp").Collection("list_invitations").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	client.Database("myapp").Collection("todo_comments").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "todo_id", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	})
+	client.Database("myapp").Collection("todo_dependencies").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "todo_id", Value: 1}, {Key: "blocked_by_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "blocked_by_id", Value: 1}},
+		},
 	})
 
+	// pre-images let the change stream tell which todo a delete removed,
+	// servers that don't support them keep working without delete events
+	err := client.Database("myapp").RunCommand(context.Background(), bson.D{
+		{Key: "collMod", Value: "todos"},
+		{Key: "changeStreamPreAndPostImages", Value: bson.D{{Key: "enabled", Value: true}}},
+	}).Err()
+	if err != nil {
+		log.Debug("change stream pre-images unavailable", slog.Any("error", err))
+	}
+
 	d.client = client
 
 	return store.NewMongoStore(collection)
 }
 
+// MongoOutboxStore shares the client opened by MongoStore. The collection
+// has to exist before the todo store writes to it inside a transaction.
+func (d *db) MongoOutboxStore() *store.MongoOutboxStore {
+	database := d.client.Database("myapp")
+	database.Collection("outbox").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{bson.E{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{bson.E{Key: "status", Value: 1}, bson.E{Key: "created_at", Value: 1}},
+		},
+	})
+
+	return store.NewMongoOutboxStore(database)
+}
+
+// MongoAuditStore shares the client opened by MongoStore. Like the outbox,
+// the collection has to exist before the first transaction writes to it.
+func (d *db) MongoAuditStore() *store.MongoAuditStore {
+	database := d.client.Database("myapp")
+	database.Collection("todo_audit").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{bson.E{Key: "todo_id", Value: 1}, bson.E{Key: "created_at", Value: -1}},
+	})
+
+	return store.NewMongoAuditStore(database)
+}
+
+// MongoReminderStore shares the client opened by MongoStore.
+func (d *db) MongoReminderStore() *store.MongoReminderStore {
+	database := d.client.Database("myapp")
+	database.Collection("todos").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{bson.E{Key: "completed", Value: 1}, bson.E{Key: "due_at", Value: 1}},
+	})
+	database.Collection("leases").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	database.Collection("reminders").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return store.NewMongoReminderStore(database)
+}
+
+// MongoWebhookStore shares the client opened by MongoStore.
+func (d *db) MongoWebhookStore() *store.MongoWebhookStore {
+	database := d.client.Database("myapp")
+	for _, name := range []string{"webhooks", "webhook_deliveries"} {
+		database.Collection(name).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.D{bson.E{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+	}
+	database.Collection("webhook_deliveries").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{bson.E{Key: "status", Value: 1}, bson.E{Key: "next_attempt", Value: 1}},
+	})
+
+	return store.NewMongoWebhookStore(database)
+}
+
+// Checks adds the ping of each connection opened so far to h.
+func (d *db) Checks(h *health.Checker) {
+	if d.client != nil {
+		h.Add("mongo", health.Mongo(d.client))
+	}
+	if d.sql != nil {
+		if sqlDB, err := d.sql.DB(); err == nil {
+			h.Add("sql", health.SQL(sqlDB))
+		}
+	}
+}
+
+// Stores reports the server version of each connection opened so far.
+func (d *db) Stores(ctx context.Context) []buildinfo.Store {
+	var stores []buildinfo.Store
+	if d.client != nil {
+		s := buildinfo.Store{Name: "mongo", Driver: "mongodb"}
+		var info struct {
+			Version string `bson:"version"`
+		}
+		if err := d.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info); err != nil {
+			s.Error = err.Error()
+		}
+		s.Version = info.Version
+		stores = append(stores, s)
+	}
+	if d.sql != nil {
+		// connectDB only opens sqlite
+		s := buildinfo.Store{Name: "gorm", Driver: d.sql.Dialector.Name()}
+		if err := d.sql.WithContext(ctx).Raw("select sqlite_version()").Scan(&s.Version).Error; err != nil {
+			s.Error = err.Error()
+		}
+		stores = append(stores, s)
+	}
+	return stores
+}
+
 func (d *db) Close() {
 	if d.client != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sing3demons/todoapi/model"
+)
+
+const (
+	Created   = model.TodoCreated
+	Updated   = model.TodoUpdated
+	Completed = model.TodoCompleted
+	Deleted   = model.TodoDeleted
+	Mentioned = model.TodoMentioned
+)
+
+// history is how many past events are kept for clients resuming a feed.
+const history = 256
+
+type Event struct {
+	ID   uint64     `json:"id"`
+	Type string     `json:"type"`
+	Todo model.Todo `json:"todo"`
+	Time time.Time  `json:"time"`
+	// Comment is set on Mentioned events.
+	Comment *model.Comment `json:"comment,omitempty"`
+}
+
+// Bus fans out todo changes to every subscriber. Slow subscribers miss
+// events rather than block the publisher.
+type Bus struct {
+	mu     sync.Mutex
+	seq    uint64
+	recent []Event
+	subs   map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.ID = b.seq
+
+	b.recent = append(b.recent, e)
+	if len(b.recent) > history {
+		b.recent = b.recent[len(b.recent)-history:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a func to stop receiving them.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	return b.SubscribeSince(0)
+}
+
+// SubscribeSince is Subscribe that first replays the kept events published
+// after the event with id after. An after of 0 replays nothing.
+func (b *Bus) SubscribeSince(after uint64) (<-chan Event, func()) {
+	b.mu.Lock()
+	var backlog []Event
+	if after > 0 {
+		for _, e := range b.recent {
+			if e.ID > after {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	ch := make(chan Event, len(backlog)+16)
+	for _, e := range backlog {
+		ch <- e
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+	"github.com/sing3demons/todoapi/todo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSubscribeSince(t *testing.T) {
+	bus := NewBus()
+	for i := 0; i < 3; i++ {
+		bus.Publish(Event{Type: Created})
+	}
+
+	ch, unsubscribe := bus.SubscribeSince(1)
+	defer unsubscribe()
+
+	for _, want := range []uint64{2, 3} {
+		if e := <-ch; e.ID != want {
+			t.Errorf("want event %d, got %d", want, e.ID)
+		}
+	}
+}
+
+var testTokens = NewTokens("secret")
+
+// newMemberStore returns the store list memberships are checked against.
+func newMemberStore(t *testing.T) *store.GormStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.List{}, &model.Membership{})
+	return store.NewGormStore(db)
+}
+
+func newServer(t *testing.T, bus *Bus) *httptest.Server {
+	h := NewHandler(bus, testTokens, todo.NewAccess(newMemberStore(t)))
+	r := router.NewMyRouter(slog.Default())
+	r.AllowOrigins("https://app.example.com")
+	r.POST("/todo/events/token", h.Token)
+	r.GET("/todo/events", h.Stream)
+	r.WS("/todo/events/ws", h.WebSocket)
+
+	s := httptest.NewServer(r)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestStreamResumesAndFilters(t *testing.T) {
+	bus := NewBus()
+	bus.Publish(Event{Type: Created, Todo: model.Todo{ID: "1", UserID: "alice"}})
+	bus.Publish(Event{Type: Created, Todo: model.Todo{ID: "2", UserID: "bob"}})
+	bus.Publish(Event{Type: Created, Todo: model.Todo{ID: "3", UserID: "alice"}})
+	s := newServer(t, bus)
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/todo/events", nil)
+	req.Header.Set(router.UserHeader, "alice")
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for len(ids) < 1 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) != 1 || ids[0] != "3" {
+		t.Errorf("want event 3, got %v", ids)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	bus := NewBus()
+	s := newServer(t, bus)
+
+	token, _ := testTokens.Sign("alice", time.Now())
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/todo/events/ws?token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://app.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	bus.Publish(Event{Type: Created, Todo: model.Todo{ID: "1", UserID: "bob"}})
+	bus.Publish(Event{Type: Updated, Todo: model.Todo{ID: "2", UserID: "alice"}})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var e Event
+	if err := conn.ReadJSON(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Type != Updated || e.Todo.ID != "2" {
+		t.Errorf("want updated event for todo 2, got %+v", e)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	s := newServer(t, NewBus())
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/todo/events/ws"
+
+	_, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	if err == nil || res == nil || res.StatusCode != http.StatusForbidden {
+		t.Errorf("want 403 from another origin, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {s.URL}})
+	if err != nil {
+		t.Fatalf("want the service's own origin let through: %v", err)
+	}
+	conn.Close()
+}
+
+func TestToken(t *testing.T) {
+	s := newServer(t, NewBus())
+
+	res, err := http.Post(s.URL+"/todo/events/token", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous asks for a token: want 401, got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/todo/events/token", nil)
+	req.Header.Set(router.UserHeader, "alice")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct{ Token string }
+	json.NewDecoder(res.Body).Decode(&body)
+	res.Body.Close()
+	if user, err := testTokens.Verify(body.Token, time.Now()); err != nil || user != "alice" {
+		t.Errorf("want a token for alice, got %q %v", user, err)
+	}
+
+	expired, _ := testTokens.Sign("alice", time.Now().Add(-time.Hour))
+	forged, _ := NewTokens("other").Sign("alice", time.Now())
+	for name, token := range map[string]string{"expired": expired, "forged": forged, "garbage": "x.y"} {
+		res, err := http.Get(s.URL + "/todo/events?token=" + token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s token: want 401, got %d", name, res.StatusCode)
+		}
+	}
+}
+
+func TestStreamFiltersByMembership(t *testing.T) {
+	s := newMemberStore(t)
+	h := NewHandler(NewBus(), testTokens, todo.NewAccess(s))
+	log := logger.New(slog.Default(), "", nil)
+	s.AddMember(&model.Membership{ListID: "release", UserID: "bob", Role: model.RoleViewer}, log)
+
+	for _, tt := range []struct {
+		name string
+		user string
+		todo model.Todo
+		want bool
+	}{
+		{"member sees a list todo", "bob", model.Todo{ListID: "release", UserID: "alice"}, true},
+		{"outsider doesn't see a list todo", "carol", model.Todo{ListID: "release"}, false},
+		{"anonymous doesn't see a list todo", "", model.Todo{ListID: "release"}, false},
+		{"other user doesn't see a private todo", "bob", model.Todo{UserID: "alice"}, false},
+		{"anyone sees an unowned todo", "carol", model.Todo{}, true},
+	} {
+		if got := h.viewer(tt.user, "todo_events").Authorize(&tt.todo, model.RoleViewer, time.Now()) == nil; got != tt.want {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/todo"
+)
+
+const keepAlive = 15 * time.Second
+
+type Handler struct {
+	bus    *Bus
+	tokens *Tokens
+	access todo.Access
+}
+
+func NewHandler(bus *Bus, tokens *Tokens, access todo.Access) *Handler {
+	return &Handler{bus: bus, tokens: tokens, access: access}
+}
+
+// caller reads the user from the gateway header. Browsers can't set headers
+// on EventSource or WebSocket, they pass a token from Token in the query
+// instead.
+func (h *Handler) caller(header, query func(string) string) (string, error) {
+	if user := header(router.UserHeader); user != "" {
+		return user, nil
+	}
+	if token := query("token"); token != "" {
+		return h.tokens.Verify(token, time.Now())
+	}
+	return "", nil
+}
+
+// viewer checks what user may see of the feed, the same as the todos the
+// events are about. The transaction of the request is flushed once the
+// feed starts, so its lookups are logged on a worker logger of their own.
+func (h *Handler) viewer(user, name string) *todo.Viewer {
+	return h.access.Viewer(user, logger.New(slog.Default(), name, map[string]any{
+		"route":   "todo_events",
+		"method":  "stream",
+		"user_id": user,
+	}))
+}
+
+// Token hands the caller a token to open the feeds with from a browser.
+func (h *Handler) Token(c router.IContext) {
+	cmd := "events token"
+	logger := c.Log("todo_events_token")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	user := c.Header(router.UserHeader)
+	if user == "" {
+		c.JSON(http.StatusUnauthorized, map[string]any{
+			"error": router.UserHeader + " header is required",
+		})
+		return
+	}
+
+	token, expires := h.tokens.Sign(user, time.Now())
+	logger.AddOutput("client", cmd, map[string]any{"user": user, "expires_at": expires}).End()
+	c.JSON(http.StatusCreated, map[string]any{
+		"token":      token,
+		"expires_at": expires,
+	})
+}
+
+func lastEventID(header, query func(string) string) uint64 {
+	v := header("Last-Event-ID")
+	if v == "" {
+		v = query("last_event_id")
+	}
+	id, _ := strconv.ParseUint(v, 10, 64)
+	return id
+}
+
+// Stream sends todo changes as server-sent events. Reconnecting clients
+// resume after the Last-Event-ID they received.
+func (h *Handler) Stream(c router.IContext) {
+	cmd := "todo events"
+	logger := c.Log("todo_events")
+	user, err := h.caller(c.Header, c.Query)
+	if err != nil {
+		logger.AddError("client", cmd, "output", nil, err)
+		c.JSON(http.StatusUnauthorized, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	after := lastEventID(c.Header, c.Query)
+	logger.AddInput("client", cmd, map[string]any{
+		"user":          user,
+		"last_event_id": after,
+	})
+
+	viewer := h.viewer(user, "todo_events")
+	ch, unsubscribe := h.bus.SubscribeSince(after)
+	ctx := c.UserContext()
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+	logger.AddOutput("client", cmd, "subscribed").End()
+
+	c.SetHeader("Content-Type", "text/event-stream")
+	c.SetHeader("Cache-Control", "no-cache")
+	c.SetHeader("Connection", "keep-alive")
+
+	connected := false
+	ticker := time.NewTicker(keepAlive)
+	c.Stream(func(w io.Writer) bool {
+		if !connected {
+			connected = true
+			fmt.Fprintf(w, "retry: %d\n\n", 3000)
+			return true
+		}
+
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				ticker.Stop()
+				return false
+			}
+			if viewer.Authorize(&e.Todo, model.RoleViewer, time.Now()) != nil {
+				return true
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			return true
+		case <-ticker.C:
+			fmt.Fprint(w, ":\n\n")
+			return true
+		}
+	})
+}
+
+// WebSocket sends todo changes as json messages until the client closes
+// the connection.
+func (h *Handler) WebSocket(conn router.WSConn) {
+	cmd := "todo events"
+	logger := conn.Log("todo_events_ws")
+	user, err := h.caller(conn.Header, conn.Query)
+	if err != nil {
+		logger.AddError("client", cmd, "output", nil, err)
+		conn.WriteJSON(map[string]any{"type": "error", "error": err.Error()})
+		conn.Close()
+		return
+	}
+	after := lastEventID(conn.Header, conn.Query)
+	logger.AddInput("client", cmd, map[string]any{
+		"user":          user,
+		"last_event_id": after,
+	})
+
+	viewer := h.viewer(user, "todo_events_ws")
+	ch, unsubscribe := h.bus.SubscribeSince(after)
+	defer unsubscribe()
+	logger.AddOutput("client", cmd, "subscribed").End()
+
+	// the client isn't expected to send anything, reading only tells us
+	// when it goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		for {
+			var msg any
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	defer conn.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-ch:
+			if viewer.Authorize(&e.Todo, model.RoleViewer, time.Now()) != nil {
+				continue
+			}
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteJSON(map[string]any{"type": "ping"}); err != nil {
+				return
+			}
+		}
+	}
+}
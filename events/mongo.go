@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoFeed publishes the changes of a collection read from a change
+// stream, so writes made by other replicas reach local subscribers too.
+// A stream that fails is reopened after the last change it delivered,
+// waiting Backoff at first and twice as long after each failure up to
+// MaxBackoff.
+type MongoFeed struct {
+	collection *mongo.Collection
+	stream     *mongo.ChangeStream
+	bus        *Bus
+
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type change struct {
+	OperationType            string      `bson:"operationType"`
+	FullDocument             *model.Todo `bson:"fullDocument"`
+	FullDocumentBeforeChange *model.Todo `bson:"fullDocumentBeforeChange"`
+}
+
+// changeStreamHistoryLost is the code of the error resuming after a change
+// the oplog no longer holds.
+const changeStreamHistoryLost = 286
+
+// WatchMongo opens a change stream on collection. It fails when the server
+// is not part of a replica set.
+func WatchMongo(collection *mongo.Collection, bus *Bus) (*MongoFeed, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &MongoFeed{
+		collection: collection,
+		bus:        bus,
+		Backoff:    time.Second,
+		MaxBackoff: time.Minute,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	stream, err := f.watch(nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	f.stream = stream
+	return f, nil
+}
+
+// watch opens the change stream, after the change of resume when not nil.
+func (f *MongoFeed) watch(resume bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{
+			{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}},
+		}}}}},
+	}
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if resume != nil {
+		opts.SetResumeAfter(resume)
+	}
+	return f.collection.Watch(f.ctx, pipeline, opts)
+}
+
+func (f *MongoFeed) Serve() error {
+	stream := f.stream
+	wait := f.Backoff
+	for {
+		if f.publish(stream) {
+			wait = f.Backoff
+		}
+		err := stream.Err()
+		resume := stream.ResumeToken()
+		stream.Close(context.Background())
+		if f.ctx.Err() != nil {
+			return nil
+		}
+		slog.Error("mongo change stream failed", slog.Any("error", err))
+
+		for {
+			select {
+			case <-f.ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+			wait = min(wait*2, f.MaxBackoff)
+
+			stream, err = f.watch(resume)
+			if err == nil {
+				break
+			}
+			var serverErr mongo.ServerError
+			if errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamHistoryLost) {
+				// the changes missed are gone, carry on from now
+				slog.Error("mongo change stream can't resume, changes were missed", slog.Any("error", err))
+				resume = nil
+				continue
+			}
+			slog.Error("reopen mongo change stream", slog.Any("error", err))
+		}
+	}
+}
+
+// publish publishes the changes of stream until it ends and reports
+// whether there was any.
+func (f *MongoFeed) publish(stream *mongo.ChangeStream) bool {
+	published := false
+	for stream.Next(f.ctx) {
+		published = true
+		var c change
+		if err := stream.Decode(&c); err != nil {
+			slog.Error("decode change event", slog.Any("error", err))
+			continue
+		}
+
+		if e, ok := c.event(); ok {
+			f.bus.Publish(e)
+		}
+	}
+	return published
+}
+
+func (f *MongoFeed) Shutdown(context.Context) error {
+	f.cancel()
+	return nil
+}
+
+func (c change) event() (Event, bool) {
+	switch c.OperationType {
+	case "insert":
+		if c.FullDocument != nil {
+			return Event{Type: Created, Todo: *c.FullDocument}, true
+		}
+	case "update", "replace":
+		if c.FullDocument != nil {
+			if c.FullDocument.DeletedAt != nil {
+				return Event{Type: Deleted, Todo: *c.FullDocument}, true
+			}
+			return Event{Type: model.UpdateKind(c.FullDocumentBeforeChange, c.FullDocument), Todo: *c.FullDocument}, true
+		}
+	case "delete":
+		// the todo id only lives in the document, without a pre-image the
+		// event can't be attributed
+		if c.FullDocumentBeforeChange != nil {
+			return Event{Type: Deleted, Todo: *c.FullDocumentBeforeChange}, true
+		}
+	}
+	return Event{}, false
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Tokens signs the short-lived tokens browsers pass to the feeds in place
+// of the user header, which EventSource and WebSocket can't set.
+type Tokens struct {
+	secret []byte
+	TTL    time.Duration
+}
+
+// NewTokens signs with secret. Without one a random key is used, its
+// tokens are only accepted by this process.
+func NewTokens(secret string) *Tokens {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Tokens{secret: key, TTL: 5 * time.Minute}
+}
+
+// Sign returns a token for user and when it expires.
+func (t *Tokens) Sign(user string, now time.Time) (string, time.Time) {
+	expires := now.Add(t.TTL).Truncate(time.Second)
+	payload := strconv.FormatInt(expires.Unix(), 10) + "|" + user
+	return encode([]byte(payload)) + "." + encode(t.mac(payload)), expires
+}
+
+// Verify returns the user of token, ErrInvalidToken when it wasn't signed
+// with our key or has expired.
+func (t *Tokens) Verify(token string, now time.Time) (string, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, t.mac(string(data))) {
+		return "", ErrInvalidToken
+	}
+
+	exp, user, _ := strings.Cut(string(data), "|")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) || user == "" {
+		return "", ErrInvalidToken
+	}
+	return user, nil
+}
+
+func (t *Tokens) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
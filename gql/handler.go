@@ -0,0 +1,111 @@
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+	"github.com/sing3demons/todoapi/todo"
+)
+
+//go:embed schema.graphql
+var schema string
+
+type Handler struct {
+	schema *graphql.Schema
+}
+
+func NewHandler(store store.Storer, bus *events.Bus) *Handler {
+	return &Handler{
+		schema: graphql.MustParseSchema(schema, &Resolver{store: store, bus: bus, access: todo.NewAccess(store)}),
+	}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Serve answers queries and mutations with a json body. Subscriptions are
+// streamed as server-sent events when the client accepts text/event-stream.
+func (h *Handler) Serve(c router.IContext) {
+	cmd := "graphql"
+	logger := c.Log("graphql")
+
+	var req request
+	if err := c.Bind(&req); err != nil {
+		logger.AddError("client", cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	logger.AddInput("client", cmd, req)
+
+	ctx := context.WithValue(c.UserContext(), loggerKey{}, logger)
+	ctx = context.WithValue(ctx, userKey{}, c.Header(router.UserHeader))
+
+	if strings.Contains(c.Header("Accept"), "text/event-stream") {
+		h.subscribe(ctx, c, req)
+		return
+	}
+
+	res := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	logger.AddOutput("client", cmd, res).End()
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) subscribe(ctx context.Context, c router.IContext, req request) {
+	logger := detailLog(ctx)
+	ch, err := h.schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		logger.AddError(node, "subscribe", "output", nil, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	logger.AddOutput(node, "subscribe", req.OperationName).End()
+
+	c.SetHeader("Content-Type", "text/event-stream")
+	c.SetHeader("Cache-Control", "no-cache")
+	c.SetHeader("Connection", "keep-alive")
+
+	// an initial comment makes fasthttp send the headers right away
+	connected := false
+	keepAlive := time.NewTicker(15 * time.Second)
+	c.Stream(func(w io.Writer) bool {
+		if !connected {
+			connected = true
+			fmt.Fprint(w, ":\n\n")
+			return true
+		}
+
+		select {
+		case res, ok := <-ch:
+			if !ok {
+				keepAlive.Stop()
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				return false
+			}
+			data, _ := json.Marshal(res)
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ":\n\n")
+			return true
+		}
+	})
+}
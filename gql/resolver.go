@@ -0,0 +1,311 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"github.com/sing3demons/todoapi/todo"
+	"github.com/sing3demons/todoapi/utils"
+)
+
+const node = "graphql"
+
+type loggerKey struct{}
+
+type userKey struct{}
+
+func detailLog(ctx context.Context) logger.ILogDetail {
+	return ctx.Value(loggerKey{}).(logger.ILogDetail)
+}
+
+// caller returns the user the request was made for.
+func caller(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// columns maps graphql fields of Todo to the store fields they are read from.
+var columns = map[string][]string{
+	"id":          {"id"},
+	"text":        {"title"},
+	"href":        {"id"},
+	"completed":   {"completed"},
+	"completedAt": {"completed_at"},
+	"dueAt":       {"due_at"},
+	"timeZone":    {"time_zone"},
+	"recurrence":  {"recurrence"},
+	"createdAt":   {"created_at"},
+	"updatedAt":   {"updated_at"},
+}
+
+var sortFields = map[string]string{
+	"ID":         "id",
+	"TITLE":      "title",
+	"CREATED_AT": "created_at",
+	"UPDATED_AT": "updated_at",
+}
+
+type Resolver struct {
+	store  store.Storer
+	bus    *events.Bus
+	access todo.Access
+}
+
+type todosArgs struct {
+	Filter *struct {
+		Text *string
+	}
+	Sort *struct {
+		Field string
+		Order string
+	}
+	Page *struct {
+		Limit  int32
+		Offset int32
+	}
+}
+
+func (r *Resolver) Todo(ctx context.Context, args struct{ ID graphql.ID }) (*todoResolver, error) {
+	todo, err := r.access.Find(r.store, string(args.ID), caller(ctx), model.RoleViewer, detailLog(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &todoResolver{todo: *todo}, nil
+}
+
+func (r *Resolver) Todos(ctx context.Context, args todosArgs) ([]*todoResolver, error) {
+	opt := store.FindOption{SelectItem: selectItem(graphql.SelectedFieldNames(ctx))}
+
+	if args.Filter != nil && args.Filter.Text != nil {
+		opt.SearchItem = map[string]interface{}{
+			"title": *args.Filter.Text,
+		}
+	}
+
+	if args.Sort != nil {
+		order := "asc"
+		if args.Sort.Order == "DESC" {
+			order = "desc"
+		}
+		opt.SortItem = map[string]any{
+			sortFields[args.Sort.Field]: order,
+		}
+	}
+
+	if args.Page != nil {
+		opt.Limit = int(args.Page.Limit)
+		opt.Offset = int(args.Page.Offset)
+	}
+
+	scope, err := r.access.Scope(caller(ctx), detailLog(ctx))
+	if err != nil {
+		return nil, err
+	}
+	opt.Scope = scope
+
+	todos, err := r.store.List(opt, detailLog(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*todoResolver, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, &todoResolver{todo: todo})
+	}
+	return out, nil
+}
+
+// selectItem turns the requested graphql fields into a store projection.
+func selectItem(fields []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, f := range fields {
+		for _, col := range columns[f] {
+			if !seen[col] {
+				seen[col] = true
+				out = append(out, col)
+			}
+		}
+	}
+	return out
+}
+
+func (r *Resolver) CreateTodo(ctx context.Context, args struct{ Text string }) (*todoResolver, error) {
+	if strings.TrimSpace(args.Text) == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+
+	if args.Text == "sleep" {
+		return nil, fmt.Errorf("not allowed")
+	}
+
+	todo := model.Todo{Title: args.Text, UserID: caller(ctx)}
+	if err := r.store.Create(&todo, detailLog(ctx)); err != nil {
+		return nil, err
+	}
+	return &todoResolver{todo: todo}, nil
+}
+
+func (r *Resolver) UpdateTodo(ctx context.Context, args struct {
+	ID        graphql.ID
+	Text      *string
+	Completed *bool
+}) (*todoResolver, error) {
+	todo, err := r.access.Find(r.store, string(args.ID), caller(ctx), model.RoleEditor, detailLog(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Text != nil {
+		if strings.TrimSpace(*args.Text) == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		todo.Title = *args.Text
+	}
+	if args.Completed != nil {
+		todo.Completed = *args.Completed
+	}
+
+	if err := r.store.Update(todo, detailLog(ctx)); err != nil {
+		return nil, err
+	}
+	return &todoResolver{todo: *todo}, nil
+}
+
+func (r *Resolver) DeleteTodo(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if _, err := r.access.Find(r.store, string(args.ID), caller(ctx), model.RoleEditor, detailLog(ctx)); err != nil {
+		return "", err
+	}
+	if err := r.store.Delete(string(args.ID), detailLog(ctx)); err != nil {
+		return "", err
+	}
+	return args.ID, nil
+}
+
+func (r *Resolver) TodoChanged(ctx context.Context) <-chan *eventResolver {
+	// the request was answered once subscribed, what the subscription looks
+	// up is logged on a worker logger of its own
+	viewer := r.access.Viewer(caller(ctx), logger.New(slog.Default(), "graphql_todo_changed", map[string]any{
+		"route":   "graphql",
+		"method":  "subscription",
+		"user_id": caller(ctx),
+	}))
+	ch, unsubscribe := r.bus.Subscribe()
+	out := make(chan *eventResolver)
+
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				if viewer.Authorize(&e.Todo, model.RoleViewer, time.Now()) != nil {
+					continue
+				}
+				select {
+				case out <- &eventResolver{event: e}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+type todoResolver struct {
+	todo model.Todo
+}
+
+func (t *todoResolver) ID() graphql.ID {
+	return graphql.ID(t.todo.ID)
+}
+
+func (t *todoResolver) Text() *string {
+	return optional(t.todo.Title)
+}
+
+func (t *todoResolver) Href() *string {
+	if t.todo.Href == "" && t.todo.ID != "" {
+		return optional(utils.GenHref(t.todo.ID))
+	}
+	return optional(t.todo.Href)
+}
+
+func (t *todoResolver) Completed() bool {
+	return t.todo.Completed
+}
+
+func (t *todoResolver) CompletedAt() *string {
+	if t.todo.CompletedAt == nil {
+		return nil
+	}
+	return optionalTime(*t.todo.CompletedAt)
+}
+
+func (t *todoResolver) DueAt() *string {
+	if t.todo.DueAt == nil {
+		return nil
+	}
+	return optionalTime(*t.todo.DueAt)
+}
+
+func (t *todoResolver) TimeZone() *string {
+	return optional(t.todo.TimeZone)
+}
+
+func (t *todoResolver) Recurrence() *string {
+	return optional(t.todo.Recurrence)
+}
+
+func (t *todoResolver) CreatedAt() *string {
+	return optionalTime(t.todo.CreatedAt)
+}
+
+func (t *todoResolver) UpdatedAt() *string {
+	return optionalTime(t.todo.UpdatedAt)
+}
+
+type eventResolver struct {
+	event events.Event
+}
+
+func (e *eventResolver) Type() string {
+	return e.event.Type
+}
+
+func (e *eventResolver) Time() string {
+	return e.event.Time.Format(time.RFC3339)
+}
+
+func (e *eventResolver) Todo() *todoResolver {
+	return &todoResolver{todo: e.event.Todo}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	return optional(t.Format(time.RFC3339))
+}
@@ -0,0 +1,177 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/outbox"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type TestDB struct {
+	opt store.FindOption
+}
+
+func (*TestDB) Create(todo *model.Todo, _ logger.ILogDetail) error {
+	todo.ID = "1"
+	return nil
+}
+func (db *TestDB) List(opt store.FindOption, _ logger.ILogDetail) ([]model.Todo, error) {
+	db.opt = opt
+	return []model.Todo{{ID: "1", Title: "Learn Go"}}, nil
+}
+func (*TestDB) Delete(string, logger.ILogDetail) error      { return nil }
+func (*TestDB) Update(*model.Todo, logger.ILogDetail) error { return nil }
+func (*TestDB) FindOne(id string, _ logger.ILogDetail) (*model.Todo, error) {
+	return &model.Todo{ID: id, Title: "Learn Go"}, nil
+}
+
+func testContext() context.Context {
+	return context.WithValue(context.Background(), loggerKey{}, logger.New(slog.Default(), "", nil))
+}
+
+func TestTodosProjection(t *testing.T) {
+	db := &TestDB{}
+	h := NewHandler(db, events.NewBus())
+
+	res := h.schema.Exec(testContext(), `{
+		todos(filter: {text: "Learn Go"}, sort: {field: CREATED_AT, order: DESC}, page: {limit: 5, offset: 10}) { text href }
+	}`, "", nil)
+	if len(res.Errors) != 0 {
+		t.Fatal(res.Errors)
+	}
+
+	want := store.FindOption{
+		SearchItem: map[string]interface{}{"title": "Learn Go"},
+		SortItem:   map[string]any{"created_at": "desc"},
+		SelectItem: []string{"title", "id"},
+		Limit:      5,
+		Offset:     10,
+	}
+	if !reflect.DeepEqual(db.opt, want) {
+		t.Errorf("want %+v, got %+v", want, db.opt)
+	}
+}
+
+func TestCreateTodoNotAllowSleep(t *testing.T) {
+	h := NewHandler(&TestDB{}, events.NewBus())
+
+	res := h.schema.Exec(testContext(), `mutation { createTodo(text: "sleep") { id } }`, "", nil)
+	if len(res.Errors) != 1 || res.Errors[0].Message != "not allowed" {
+		t.Errorf("want not allowed, got %v", res.Errors)
+	}
+}
+
+func TestTodoChanged(t *testing.T) {
+	// changes reach the bus the way they do in the service: through the
+	// outbox written by the store and the relay
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	bus := events.NewBus()
+	relay := outbox.NewRelay(store.NewGormOutboxStore(db), outbox.NewBusSink(bus), slog.Default())
+	relay.Interval = 10 * time.Millisecond
+	go relay.Serve()
+	defer relay.Shutdown(context.Background())
+	h := NewHandler(store.NewGormStore(db), bus)
+
+	ctx, cancel := context.WithCancel(testContext())
+	defer cancel()
+
+	ch, err := h.schema.Subscribe(ctx, `subscription { todoChanged { type todo { text } } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := h.schema.Exec(testContext(), `mutation { createTodo(text: "Learn Go") { id } }`, "", nil)
+	if len(res.Errors) != 0 {
+		t.Fatal(res.Errors)
+	}
+
+	select {
+	case r := <-ch:
+		data, _ := json.Marshal(r)
+		want := `{"data":{"todoChanged":{"type":"created","todo":{"text":"Learn Go"}}}}`
+		if string(data) != want {
+			t.Errorf("want %s, got %s", want, data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func userContext(user string) context.Context {
+	return context.WithValue(testContext(), userKey{}, user)
+}
+
+func TestTodoAccess(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	s := store.NewGormStore(db)
+	bus := events.NewBus()
+	h := NewHandler(s, bus)
+
+	private := &model.Todo{Title: "dentist", UserID: "alice"}
+	s.Create(private, logger.New(slog.Default(), "", nil))
+	vars := map[string]any{"id": private.ID}
+
+	for _, q := range []string{
+		`query($id: ID!) { todo(id: $id) { text } }`,
+		`mutation($id: ID!) { updateTodo(id: $id, completed: true) { id } }`,
+		`mutation($id: ID!) { deleteTodo(id: $id) }`,
+	} {
+		res := h.schema.Exec(userContext("bob"), q, "", vars)
+		if len(res.Errors) != 1 || res.Errors[0].Message != "not found" {
+			t.Errorf("%s: want not found for bob, got %v", q, res.Errors)
+		}
+	}
+	if res := h.schema.Exec(userContext("alice"), `query($id: ID!) { todo(id: $id) { text } }`, "", vars); len(res.Errors) != 0 {
+		t.Errorf("want alice to read her todo, got %v", res.Errors)
+	}
+	if res := h.schema.Exec(userContext("alice"), `mutation { deleteTodo(id: "missing") }`, "", nil); len(res.Errors) != 1 || res.Errors[0].Message != "not found" {
+		t.Errorf("want not found deleting a missing todo, got %v", res.Errors)
+	}
+
+	res := h.schema.Exec(userContext("bob"), `{ todos { text } }`, "", nil)
+	if string(res.Data) != `{"todos":[]}` {
+		t.Errorf("want no todos listed for bob, got %s %v", res.Data, res.Errors)
+	}
+
+	ctx, cancel := context.WithCancel(userContext("bob"))
+	defer cancel()
+	ch, err := h.schema.Subscribe(ctx, `subscription { todoChanged { todo { text } } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish(events.Event{Type: events.Updated, Todo: *private})
+	bus.Publish(events.Event{Type: events.Created, Todo: model.Todo{Title: "lunch"}})
+	select {
+	case r := <-ch:
+		data, _ := json.Marshal(r)
+		if want := `{"data":{"todoChanged":{"todo":{"text":"lunch"}}}}`; string(data) != want {
+			t.Errorf("want only the public event, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
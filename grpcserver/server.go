@@ -0,0 +1,121 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/router"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+const session = "x-session"
+
+type ctxKey struct{}
+
+type userKey struct{}
+
+type Server struct {
+	*grpc.Server
+	port string
+}
+
+// New builds a grpc server listening on port once Serve is called.
+func New(port string, log *slog.Logger) *Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(sessionInterceptor(log)))
+	reflection.Register(s)
+	return &Server{Server: s, port: port}
+}
+
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", ":"+s.port)
+	if err != nil {
+		return err
+	}
+	slog.Info("grpc server started", slog.String("port", s.port))
+	return s.Server.Serve(lis)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Server.Stop()
+		return ctx.Err()
+	}
+}
+
+// sessionInterceptor reads the session id from the incoming metadata, the
+// same way the http routers read the x-session header, and echoes it back.
+func sessionInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var id string
+		if v := md.Get(session); len(v) != 0 && v[0] != "" {
+			id = v[0]
+		} else if uuidV7, err := uuid.NewV7(); err != nil {
+			id = "unknown" + time.Now().String()
+		} else {
+			id = uuidV7.String()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(session, id))
+
+		var device string
+		if v := md.Get("user-agent"); len(v) != 0 {
+			device = v[0]
+		}
+
+		instance, err := os.Hostname()
+		if err != nil {
+			instance = "unknown"
+		}
+
+		var user string
+		if v := md.Get(router.UserHeader); len(v) != 0 {
+			user = v[0]
+		}
+
+		attribute := map[string]any{
+			"route":    info.FullMethod,
+			"method":   "grpc",
+			"device":   device,
+			"instance": instance,
+			"session":  id,
+			"user_id":  user,
+		}
+
+		ctx = context.WithValue(ctx, ctxKey{}, func(name string) logger.ILogDetail {
+			return logger.New(log.With(slog.String("session", id)), name, attribute)
+		})
+		ctx = context.WithValue(ctx, userKey{}, user)
+		return handler(ctx, req)
+	}
+}
+
+// caller returns the user the rpc was made for.
+func caller(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// Log returns the detail logger bound to the rpc session.
+func Log(ctx context.Context, name string) logger.ILogDetail {
+	if fn, ok := ctx.Value(ctxKey{}).(func(string) logger.ILogDetail); ok {
+		return fn(name)
+	}
+	return logger.New(slog.Default(), name, nil)
+}
@@ -0,0 +1,202 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"github.com/sing3demons/todoapi/todo"
+	"github.com/sing3demons/todoapi/todopb"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// node is what the RPCs are logged as, the inbound request of a handler like
+// the HTTP ones.
+const node = "client"
+
+// columns are the todo fields ListTodos sorts by and selects, they end up
+// in the query as they are so nothing else is let through.
+var columns = map[string]bool{
+	"id":           true,
+	"title":        true,
+	"user_id":      true,
+	"parent_id":    true,
+	"list_id":      true,
+	"rank":         true,
+	"completed":    true,
+	"completed_at": true,
+	"due_at":       true,
+	"time_zone":    true,
+	"recurrence":   true,
+	"series_id":    true,
+	"created_at":   true,
+	"updated_at":   true,
+}
+
+type TodoService struct {
+	todopb.UnimplementedTodoServiceServer
+	store  store.Storer
+	access todo.Access
+}
+
+func NewTodoService(store store.Storer) *TodoService {
+	return &TodoService{store: store, access: todo.NewAccess(store)}
+}
+
+func (t *TodoService) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.CreateTodoResponse, error) {
+	cmd := "create todo"
+	logger := Log(ctx, "new_task")
+	logger.AddInput(node, cmd, req)
+
+	if req.GetText() == "" {
+		err := fmt.Errorf("text is required")
+		logger.AddError(node, cmd, "output", req, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.GetText() == "sleep" {
+		err := fmt.Errorf("not allowed")
+		logger.AddError(node, cmd, "output", req, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	todo := model.Todo{Title: req.GetText(), UserID: caller(ctx)}
+	if err := t.store.Create(&todo, logger); err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &todopb.CreateTodoResponse{Id: todo.ID}
+	logger.AddOutput(node, cmd, res).End()
+	return res, nil
+}
+
+func (t *TodoService) GetTodo(ctx context.Context, req *todopb.GetTodoRequest) (*todopb.Todo, error) {
+	cmd := "get todo"
+	logger := Log(ctx, "find_task")
+	logger.AddInput(node, cmd, req)
+
+	todo, err := t.access.Find(t.store, req.GetId(), caller(ctx), model.RoleViewer, logger)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := toProto(todo)
+	logger.AddOutput(node, cmd, res).End()
+	return res, nil
+}
+
+func (t *TodoService) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	cmd := "list todos"
+	logger := Log(ctx, "tasks_list")
+	logger.AddInput(node, cmd, req)
+
+	if err := validateColumns(req.GetSort(), req.GetFields()); err != nil {
+		logger.AddError(node, cmd, "output", req, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	opt := store.FindOption{}
+	if req.GetSearch() != "" {
+		opt.SearchItem = map[string]interface{}{
+			"title": req.GetSearch(),
+		}
+	}
+
+	if req.GetSort() != "" {
+		opt.SortItem = map[string]any{
+			req.GetSort(): "asc",
+		}
+		if req.GetOrder() == "desc" {
+			opt.SortItem[req.GetSort()] = "desc"
+		}
+	}
+
+	if len(req.GetFields()) != 0 {
+		opt.SelectItem = req.GetFields()
+	}
+
+	scope, err := t.access.Scope(caller(ctx), logger)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	opt.Scope = scope
+
+	todos, err := t.store.List(opt, logger)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &todopb.ListTodosResponse{}
+	for i := range todos {
+		res.Todos = append(res.Todos, toProto(&todos[i]))
+	}
+	logger.AddOutput(node, cmd, res).End()
+	return res, nil
+}
+
+func (t *TodoService) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*todopb.DeleteTodoResponse, error) {
+	cmd := "delete todo"
+	logger := Log(ctx, "delete_task")
+	logger.AddInput(node, cmd, req)
+
+	if _, err := t.access.Find(t.store, req.GetId(), caller(ctx), model.RoleEditor, logger); err != nil {
+		return nil, toStatus(err)
+	}
+	if err := t.store.Delete(req.GetId(), logger); err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &todopb.DeleteTodoResponse{Id: req.GetId(), Status: "success"}
+	logger.AddOutput(node, cmd, res).End()
+	return res, nil
+}
+
+// validateColumns checks sort and fields only name columns of a todo.
+func validateColumns(sort string, fields []string) error {
+	if sort != "" && !columns[sort] {
+		return fmt.Errorf("unknown sort field %q", sort)
+	}
+	for _, f := range fields {
+		if !columns[f] {
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return nil
+}
+
+func toProto(todo *model.Todo) *todopb.Todo {
+	t := &todopb.Todo{
+		Id:         todo.ID,
+		Text:       todo.Title,
+		Href:       todo.Href,
+		Completed:  todo.Completed,
+		TimeZone:   todo.TimeZone,
+		Recurrence: todo.Recurrence,
+	}
+	if todo.DueAt != nil {
+		t.DueAt = timestamppb.New(*todo.DueAt)
+	}
+	if !todo.CreatedAt.IsZero() {
+		t.CreatedAt = timestamppb.New(todo.CreatedAt)
+	}
+	if !todo.UpdatedAt.IsZero() {
+		t.UpdatedAt = timestamppb.New(todo.UpdatedAt)
+	}
+	return t
+}
+
+func toStatus(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, todo.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, todo.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,173 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+	"github.com/sing3demons/todoapi/todopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type TestDB struct{}
+
+func (*TestDB) Create(todo *model.Todo, _ logger.ILogDetail) error {
+	todo.ID = "1"
+	return nil
+}
+func (*TestDB) List(store.FindOption, logger.ILogDetail) ([]model.Todo, error) {
+	return []model.Todo{{ID: "1", Title: "Learn Go"}}, nil
+}
+func (*TestDB) Delete(string, logger.ILogDetail) error      { return nil }
+func (*TestDB) Update(*model.Todo, logger.ILogDetail) error { return nil }
+func (*TestDB) FindOne(id string, _ logger.ILogDetail) (*model.Todo, error) {
+	return &model.Todo{ID: id, Title: "Learn Go"}, nil
+}
+
+func newClient(t *testing.T) todopb.TodoServiceClient {
+	return newStoreClient(t, &TestDB{})
+}
+
+func newStoreClient(t *testing.T, db store.Storer) todopb.TodoServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	s := New("", slog.Default())
+	todopb.RegisterTodoServiceServer(s, NewTodoService(db))
+	go s.Server.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return todopb.NewTodoServiceClient(conn)
+}
+
+func TestCreateTodo(t *testing.T) {
+	client := newClient(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), session, "test-create")
+
+	var header metadata.MD
+	res, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Text: "Learn Go"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.GetId() != "1" {
+		t.Errorf("want id 1, got %s", res.GetId())
+	}
+
+	if got := header.Get(session); len(got) == 0 || got[0] != "test-create" {
+		t.Errorf("want session test-create, got %v", got)
+	}
+}
+
+func TestCreateTodoNotAllowSleep(t *testing.T) {
+	client := newClient(t)
+
+	_, err := client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Text: "sleep"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("want %s, got %v", codes.InvalidArgument, err)
+	}
+}
+
+// TestRequestIsNotACall checks the RPC itself isn't counted as a call the
+// service made.
+func TestRequestIsNotACall(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	logger.ObserveCalls(func(node, cmd string, _ time.Duration, _ error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, node+"."+cmd)
+	})
+	t.Cleanup(func() { logger.ObserveCalls(nil) })
+	client := newClient(t)
+
+	client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Text: "Learn Go"})
+	client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Text: "sleep"})
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 0 {
+		t.Errorf("want no calls observed, got %v", calls)
+	}
+}
+
+func TestListTodos(t *testing.T) {
+	client := newClient(t)
+
+	res, err := client.ListTodos(context.Background(), &todopb.ListTodosRequest{Sort: "title", Order: "desc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.GetTodos()) != 1 || res.GetTodos()[0].GetText() != "Learn Go" {
+		t.Errorf("unexpected todos %v", res.GetTodos())
+	}
+}
+
+func TestListTodosRejectsUnknownColumns(t *testing.T) {
+	client := newClient(t)
+
+	for _, req := range []*todopb.ListTodosRequest{
+		{Sort: "title; DROP TABLE todos"},
+		{Fields: []string{"title", "(select password from users)"}},
+	} {
+		_, err := client.ListTodos(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: want %s, got %v", req, codes.InvalidArgument, err)
+		}
+	}
+}
+
+func TestTodoAccess(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	client := newStoreClient(t, store.NewGormStore(db))
+	as := func(user string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), router.UserHeader, user)
+	}
+
+	created, err := client.CreateTodo(as("alice"), &todopb.CreateTodoRequest{Text: "dentist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.GetId()
+
+	if _, err := client.GetTodo(as("bob"), &todopb.GetTodoRequest{Id: id}); status.Code(err) != codes.NotFound {
+		t.Errorf("other user gets the todo: want %s, got %v", codes.NotFound, err)
+	}
+	if _, err := client.DeleteTodo(as("bob"), &todopb.DeleteTodoRequest{Id: id}); status.Code(err) != codes.NotFound {
+		t.Errorf("other user deletes the todo: want %s, got %v", codes.NotFound, err)
+	}
+	if res, err := client.ListTodos(as("bob"), &todopb.ListTodosRequest{}); err != nil || len(res.GetTodos()) != 0 {
+		t.Errorf("want no todos listed for bob, got %v %v", res.GetTodos(), err)
+	}
+	if _, err := client.GetTodo(as("alice"), &todopb.GetTodoRequest{Id: id}); err != nil {
+		t.Errorf("owner gets the todo: %v", err)
+	}
+}
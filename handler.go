@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/sing3demons/todoapi/buildinfo"
+	"github.com/sing3demons/todoapi/metrics"
 	"github.com/sing3demons/todoapi/router"
 	"github.com/sing3demons/todoapi/utils"
 )
@@ -21,16 +25,40 @@ func PingHandler(c router.IContext) {
 }
 
 func X(c router.IContext) {
+	info := buildinfo.Read(buildcommit, buildtime)
 	c.JSON(200, map[string]any{
-		"buildcommit": buildcommit,
-		"buildtime":   buildtime,
+		"buildcommit": info.Revision,
+		"buildtime":   info.BuildTime,
 	})
 }
 
+// Version answers the build of the binary, the version of the stores of
+// conn, the features turned on and the uptime.
+func Version(conn *db) func(router.IContext) {
+	return func(c router.IContext) {
+		ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+		defer cancel()
+
+		info := version(ctx, conn)
+		info.SetUptime(time.Now())
+		c.JSON(http.StatusOK, info)
+	}
+}
+
 func Healthz(c router.IContext) {
 	c.JSON(http.StatusOK, map[string]any{"status": "ok"})
 }
 
+// Metrics answers the metrics of the service in the Prometheus text format.
+func Metrics(c router.IContext) {
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	c.SendReader(http.StatusOK, metrics.ContentType, int64(buf.Len()), &buf)
+}
+
 func Transfer(c router.IContext) {
 	logger := c.Log("transfer")
 	id := c.Param("id")
@@ -0,0 +1,168 @@
+// Package health answers the liveness and readiness probes of the service.
+// Liveness only tells the process is up, readiness checks each dependency
+// the service was configured with.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sing3demons/todoapi/router"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFailed       = "failed"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check fails when a dependency can't be used, it returns once ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a check.
+type Result struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_ms"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Report is the answer of the readiness probe.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name  string
+	check Check
+}
+
+// Checker runs the checks of the readiness probe. Once Drain is called it
+// is not ready anymore, without running them.
+type Checker struct {
+	// Timeout bounds each check, 2s by default.
+	Timeout time.Duration
+
+	checks   []check
+	draining atomic.Bool
+}
+
+func New() *Checker {
+	return &Checker{Timeout: 2 * time.Second}
+}
+
+// Add adds a check named name to the probe.
+func (h *Checker) Add(name string, c Check) {
+	h.checks = append(h.checks, check{name: name, check: c})
+}
+
+// Drain makes the probe answer not ready, it's called when the service
+// starts shutting down so that no new request is routed to it.
+func (h *Checker) Drain() {
+	h.draining.Store(true)
+}
+
+// Check runs every check at once, each within Timeout.
+func (h *Checker) Check(ctx context.Context) Report {
+	if h.draining.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(h.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			r := h.run(ctx, c.check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = r
+			if r.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	// a check ignoring ctx doesn't hold the probe past its timeout
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- c(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	r := Result{Status: StatusOK, Latency: float64(time.Since(start).Microseconds()) / 1000}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", h.Timeout)
+	}
+	if err != nil {
+		r.Status, r.Error = StatusFailed, err.Error()
+	}
+	return r
+}
+
+// Readyz answers the report of the checks, 503 unless all of them pass.
+func (h *Checker) Readyz(c router.IContext) {
+	report := h.Check(c.UserContext())
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+// Livez answers 200 as long as the process serves requests.
+func Livez(c router.IContext) {
+	c.JSON(http.StatusOK, map[string]any{"status": StatusOK})
+}
+
+// SQL pings db.
+func SQL(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// Mongo pings the primary of client.
+func Mongo(client *mongo.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}
+}
+
+// DiskSpace fails when the file system of dir has less than min bytes
+// free. It always passes where the free space can't be read.
+func DiskSpace(dir string, min uint64) Check {
+	return func(context.Context) error {
+		free, err := freeSpace(dir)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if free < min {
+			return fmt.Errorf("%d bytes free in %s, want at least %d", free, dir, min)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/router"
+)
+
+func readyz(t *testing.T, h *Checker) (int, Report) {
+	t.Helper()
+	r := router.NewFiberRouter(slog.Default())
+	r.GET("/readyz", h.Readyz)
+	res, err := r.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var report Report
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, report
+}
+
+func TestReadyz(t *testing.T) {
+	h := New()
+	h.Timeout = 50 * time.Millisecond
+	h.Add("sql", func(context.Context) error { return nil })
+	code, report := readyz(t, h)
+	if code != http.StatusOK || report.Status != StatusOK || report.Checks["sql"].Status != StatusOK {
+		t.Fatalf("want ready, got %d %+v", code, report)
+	}
+
+	h.Add("mongo", func(context.Context) error { return errors.New("connection refused") })
+	// a check ignoring its context is cut at the timeout
+	h.Add("slow", func(context.Context) error { time.Sleep(time.Second); return nil })
+	start := time.Now()
+	code, report = readyz(t, h)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("want the probe bounded by the timeout, took %s", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Status != StatusUnavailable {
+		t.Fatalf("want not ready, got %d %+v", code, report)
+	}
+	if r := report.Checks["mongo"]; r.Status != StatusFailed || r.Error != "connection refused" {
+		t.Errorf("want the error of the check, got %+v", r)
+	}
+	if r := report.Checks["slow"]; r.Status != StatusFailed || r.Error != "timed out after 50ms" || r.Latency < 50 {
+		t.Errorf("want the slow check timed out, got %+v", r)
+	}
+	if r := report.Checks["sql"]; r.Status != StatusOK {
+		t.Errorf("want the other checks still run, got %+v", r)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	h := New()
+	ran := false
+	h.Add("sql", func(context.Context) error { ran = true; return nil })
+	h.Drain()
+	code, report := readyz(t, h)
+	if code != http.StatusServiceUnavailable || report.Status != StatusShuttingDown || ran {
+		t.Errorf("want not ready without running the checks, got %d %+v", code, report)
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := DiskSpace(dir, 0)(context.Background()); err != nil {
+		t.Errorf("want enough space, got %v", err)
+	}
+	if _, err := freeSpace(dir); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("free space unsupported")
+	}
+	if err := DiskSpace(dir, math.MaxUint64)(context.Background()); err == nil {
+		t.Error("want too little space")
+	}
+	if err := DiskSpace(dir+"/missing", 0)(context.Background()); err == nil {
+		t.Error("want an error for a missing directory")
+	}
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// call is a call of a transaction to a node other than the client. It
+// starts with AddOutput and ends with the AddInput or AddError of the same
+// node and command, like the calls counted by the Summary.
+type call struct {
+	start time.Time
+	// span is nil when the transaction isn't traced.
+	span trace.Span
+}
+
+// CallObserver is told how long every call to a node took and how it
+// ended, err is nil for a success.
+type CallObserver func(node, cmd string, d time.Duration, err error)
+
+var callObserver atomic.Pointer[CallObserver]
+
+// ObserveCalls makes o the observer of the calls of every transaction,
+// nil stops observing.
+func ObserveCalls(o CallObserver) {
+	if o == nil {
+		callObserver.Store(nil)
+		return
+	}
+	callObserver.Store(&o)
+}
+
+// startCall starts a call of l to node, it returns the id of its span, ""
+// when the transaction isn't traced.
+func (l *Logger) startCall(node, cmd string) string {
+	if node == "client" {
+		return ""
+	}
+	c := &call{start: time.Now()}
+	if parent := l.traceContext(); parent != nil {
+		_, c.span = tracer(parent).Start(parent, l.name(node, cmd),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("node", node), attribute.String("cmd", cmd)))
+	}
+
+	l.tx.mu.Lock()
+	defer l.tx.mu.Unlock()
+	key := l.span + "|" + l.name(node, cmd)
+	l.tx.calls[key] = append(l.tx.calls[key], c)
+	return spanID(c.span)
+}
+
+// endCall ends the last call started of l to node, failed with err if not
+// nil. An error with no call started counts as a call of its own.
+func (l *Logger) endCall(node, cmd string, err error) string {
+	if node == "client" {
+		return ""
+	}
+	l.tx.mu.Lock()
+	key := l.span + "|" + l.name(node, cmd)
+	var c *call
+	if open := l.tx.calls[key]; len(open) > 0 {
+		c = open[len(open)-1]
+		l.tx.calls[key] = open[:len(open)-1]
+		if len(open) == 1 {
+			delete(l.tx.calls, key)
+		}
+	}
+	l.tx.mu.Unlock()
+
+	if c == nil {
+		if err == nil {
+			return ""
+		}
+		l.startCall(node, cmd)
+		return l.endCall(node, cmd, err)
+	}
+
+	if o := callObserver.Load(); o != nil {
+		(*o)(node, cmd, time.Since(c.start), err)
+	}
+	if c.span == nil {
+		return ""
+	}
+	if err != nil {
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+	}
+	c.span.End()
+	return spanID(c.span)
+}
+
+// endCalls ends the spans of the calls that never got an answer, the
+// caller holds mu. They aren't observed, they have no outcome.
+func (tx *Transaction) endCalls() {
+	for key, open := range tx.calls {
+		for _, c := range open {
+			if c.span != nil {
+				c.span.SetAttributes(attribute.Bool("unfinished", true))
+				c.span.End()
+			}
+		}
+		delete(tx.calls, key)
+	}
+}
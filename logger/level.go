@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// The components whose level is set on its own, see ParseLevels.
+const (
+	App   = "app"
+	Gorm  = "gorm"
+	Mongo = "mongo"
+	HTTP  = "http"
+)
+
+// levels are the levels of the components, Info until set.
+var levels = map[string]*slog.LevelVar{
+	App:   new(slog.LevelVar),
+	Gorm:  new(slog.LevelVar),
+	Mongo: new(slog.LevelVar),
+	HTTP:  new(slog.LevelVar),
+}
+
+// Level is the level of component, it may change at runtime.
+func Level(component string) *slog.LevelVar {
+	if l, ok := levels[component]; ok {
+		return l
+	}
+	return levels[App]
+}
+
+// SetLevel sets the level of component from a name such as "debug".
+func SetLevel(component, name string) error {
+	l, ok := levels[component]
+	if !ok {
+		return fmt.Errorf("unknown log component %q", component)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return err
+	}
+	l.Set(level)
+	return nil
+}
+
+// Levels returns the level of every component.
+func Levels() map[string]string {
+	out := make(map[string]string, len(levels))
+	for component, l := range levels {
+		out[component] = strings.ToLower(l.Level().String())
+	}
+	return out
+}
+
+// ParseLevels sets the levels of LOG_LEVEL, a level for every component
+// followed by levels for single ones, e.g. "warn,gorm=info,http=debug".
+func ParseLevels(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, name, ok := strings.Cut(part, "=")
+		if !ok {
+			for c := range levels {
+				if err := SetLevel(c, part); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := SetLevel(strings.TrimSpace(component), strings.TrimSpace(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type debugKey struct{}
+
+// ForceDebug marks ctx to be logged at every level, whatever the level of
+// the component.
+func ForceDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+func debugForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(debugKey{}).(bool)
+	return forced
+}
+
+// levelHandler drops the records below the level of its component.
+type levelHandler struct {
+	slog.Handler
+	component string
+}
+
+// NewHandler filters h by the level of component. h itself should let
+// every level through.
+func NewHandler(h slog.Handler, component string) slog.Handler {
+	if l, ok := h.(*levelHandler); ok {
+		h = l.Handler
+	}
+	return &levelHandler{Handler: h, component: component}
+}
+
+// Component returns l logging at the level of component instead.
+func Component(l *slog.Logger, component string) *slog.Logger {
+	return slog.New(NewHandler(l.Handler(), component))
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (level >= Level(h.component).Level() || debugForced(ctx)) && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), component: h.component}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), component: h.component}
+}
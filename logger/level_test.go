@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func resetLevels(t *testing.T) {
+	t.Cleanup(func() {
+		for _, l := range levels {
+			l.Set(slog.LevelInfo)
+		}
+	})
+}
+
+func TestParseLevels(t *testing.T) {
+	resetLevels(t)
+
+	if err := ParseLevels("warn, gorm=debug,http=error"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{App: "warn", Gorm: "debug", Mongo: "warn", HTTP: "error"}
+	if got := Levels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	for _, spec := range []string{"loud", "grpc=debug", "gorm=loud"} {
+		if err := ParseLevels(spec); err == nil {
+			t.Errorf("%q: want an error", spec)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	resetLevels(t)
+
+	var buf bytes.Buffer
+	base := slog.New(NewHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), App))
+	gorm := Component(base, Gorm)
+	SetLevel(Gorm, "debug")
+
+	base.Debug("app debug")
+	base.Info("app info")
+	gorm.With("session", "s-1").Debug("gorm debug")
+
+	// a request with debug forced logs below the level of its component
+	tx := NewTransaction(base)
+	l := tx.Logger("test", nil)
+	l.Debug("request debug")
+	if l.Debugging() {
+		t.Error("want debug off without forcing it")
+	}
+	tx.ForceDebug()
+	l.Debug("forced debug")
+	if !l.Debugging() {
+		t.Error("want debug on once forced")
+	}
+
+	out := buf.String()
+	for msg, want := range map[string]bool{
+		"app debug":     false,
+		"app info":      true,
+		"gorm debug":    true,
+		"request debug": false,
+		"forced debug":  true,
+	} {
+		if strings.Contains(out, `"msg":"`+msg+`"`) != want {
+			t.Errorf("%s: want logged %v, got %s", msg, want, out)
+		}
+	}
+}
@@ -1,19 +1,35 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Logger adds events to the DETAIL log of a transaction. The loggers of a
+// request share its transaction, which the router flushes once the
+// response is sent, so End does nothing for them. A logger made with New
+// has a transaction of its own that End flushes.
 type Logger struct {
 	*slog.Logger
-	event       string
-	attributes  []LogEvent
-	attribute   map[string]any
-	startTime   time.Time
-	ProcessTime time.Duration
+	tx    *Transaction
+	actor Actor
+	// span is the span the events are added to, "" for the transaction.
+	span string
+	// ctx carries the trace span of span, nil for the one of the
+	// transaction.
+	ctx context.Context
+}
+
+// Actor is who a request acts for, taken from the session and user_id
+// attributes the logger was created with.
+type Actor struct {
+	Session string `json:"session,omitempty" bson:"session,omitempty"`
+	UserID  string `json:"user_id,omitempty" bson:"user_id,omitempty"`
 }
 
 type ILogDetail interface {
@@ -25,12 +41,30 @@ type ILogDetail interface {
 	Error(msg string, fields ...any)
 	Debug(msg string, fields ...any)
 	Warn(msg string, fields ...any)
+	Actor() Actor
+	// Summary is the summary of the request logged, nil outside of one.
+	Summary() *Summary
+	// StartSpan starts a timed part of the work, the events added to the
+	// span are marked with its name.
+	StartSpan(name string) Span
+	// Debugging reports whether Debug is logged, either by the level or
+	// forced for the request.
+	Debugging() bool
+}
+
+// Span is a timed part of a transaction, Finish records when it ended.
+type Span interface {
+	ILogDetail
+	Finish()
 }
 
 type LogEvent struct {
 	Name string `json:"name"`
 	// Action     string            `json:"action"`
-	Timestamp  string            `json:"timestamp"`
+	Timestamp string `json:"timestamp"`
+	Span      string `json:"span,omitempty"`
+	// SpanID is the trace span of the call the event is part of.
+	SpanID     string            `json:"span_id,omitempty"`
 	Attributes interface{}       `json:"attributes,omitempty"`
 	Input      interface{}       `json:"input,omitempty"`
 	Output     interface{}       `json:"output,omitempty"`
@@ -38,39 +72,85 @@ type LogEvent struct {
 }
 
 func New(s *slog.Logger, name string, attribute map[string]any) ILogDetail {
-	return &Logger{Logger: s, attribute: attribute, startTime: time.Now()}
+	tx := newTransaction(s)
+	tx.standalone = true
+	return tx.Logger(name, attribute)
+}
+
+// Poll is a logger for a worker polling for work. Like one made with New
+// it has a transaction of its own, but End holds the events back until
+// Keep, so a pass finding nothing to do writes nothing. An error keeps them
+// as well.
+type Poll struct {
+	ILogDetail
+	tx *Transaction
+}
+
+func NewPoll(s *slog.Logger, name string, attribute map[string]any) *Poll {
+	tx := newTransaction(s)
+	tx.standalone = true
+	tx.held = true
+	return &Poll{ILogDetail: tx.Logger(name, attribute), tx: tx}
+}
+
+// Keep writes the events held so far, End writes them from then on.
+func (p *Poll) Keep() {
+	p.tx.keep()
+	p.tx.writeDetail(false)
+}
+
+func newLogger(tx *Transaction, attribute map[string]any) *Logger {
+	actor := Actor{}
+	actor.Session, _ = attribute["session"].(string)
+	actor.UserID, _ = attribute["user_id"].(string)
+	return &Logger{Logger: tx.logger, tx: tx, actor: actor}
+}
+
+func (l *Logger) Summary() *Summary {
+	return l.tx.summary
+}
+
+func (l *Logger) Debugging() bool {
+	return l.Logger.Enabled(l.tx.context(), slog.LevelDebug)
+}
+
+func (l *Logger) Actor() Actor {
+	return l.actor
 }
 
 func (l *Logger) addEvent(node, cmd, name string, data interface{}) {
-	l.event = fmt.Sprintf("%s.%s", node, cmd)
+	l.tx.summary.record(node, cmd, name, false)
 	attribute := LogEvent{
-		Name:       l.name(node, cmd),
-		Timestamp:  time.Now().Format(time.RFC3339),
+		Name:      l.name(node, cmd),
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Span:      l.span,
 	}
 
 	if name == "input" {
 		attribute.Input = data
+		attribute.SpanID = l.endCall(node, cmd, nil)
 	} else {
 		attribute.Output = data
+		attribute.SpanID = l.startCall(node, cmd)
 	}
 
-	l.attributes = append(l.attributes, attribute)
+	l.tx.add(fmt.Sprintf("%s.%s", node, cmd), attribute)
 }
 
 func (l *Logger) Info(msg string, fields ...any) {
-	l.Logger.Info(msg, fields...)
+	l.Logger.Log(l.tx.context(), slog.LevelInfo, msg, fields...)
 }
 
 func (l *Logger) Error(msg string, fields ...any) {
-	l.Logger.Error(msg, fields...)
+	l.Logger.Log(l.tx.context(), slog.LevelError, msg, fields...)
 }
 
 func (l *Logger) Debug(msg string, fields ...any) {
-	l.Logger.Debug(msg, fields...)
+	l.Logger.Log(l.tx.context(), slog.LevelDebug, msg, fields...)
 }
 
 func (l *Logger) Warn(msg string, fields ...any) {
-	l.Logger.Warn(msg, fields...)
+	l.Logger.Log(l.tx.context(), slog.LevelWarn, msg, fields...)
 }
 
 func (l *Logger) name(node, cmd string) string {
@@ -87,12 +167,14 @@ func (l *Logger) AddOutput(node, cmd string, custom interface{}) ILogDetail {
 }
 
 func (l *Logger) AddError(node, cmd, inOut string, data interface{}, err error) {
-	l.event = fmt.Sprintf("%s.%s", node, cmd)
+	l.tx.summary.record(node, cmd, inOut, true)
 
 	attribute := LogEvent{
-		Name:       l.name(node, cmd),
-		Timestamp:  time.Now().Format(time.RFC3339),
-		Msg:        map[string]string{"error": err.Error()},
+		Name:      l.name(node, cmd),
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Span:      l.span,
+		Msg:       map[string]string{"error": err.Error()},
+		SpanID:    l.endCall(node, cmd, err),
 	}
 
 	if inOut == "input" {
@@ -101,21 +183,43 @@ func (l *Logger) AddError(node, cmd, inOut string, data interface{}, err error)
 		attribute.Output = data
 	}
 
-	l.attributes = append(l.attributes, attribute)
+	l.tx.add(fmt.Sprintf("%s.%s", node, cmd), attribute)
 
+	l.tx.keep()
 	l.End()
 }
 
+// End writes the events added so far when the logger isn't part of a
+// request, the router flushes those of a request once it's answered.
 func (l *Logger) End() {
-	if len(l.attributes) > 0 {
-		l.Logger.Info(strings.ReplaceAll(l.event, " ", "_"),
-			slog.String("log_name", "DETAIL"),
-			slog.Any("startTime", l.startTime),
-			slog.Any("endTime", time.Now()),
-			slog.Any("processTime", time.Since(l.startTime)),
-			slog.Any("attribute", l.attribute),
-			slog.Any("events", l.attributes))
+	if l.tx.standalone && !l.tx.holding() {
+		l.tx.writeDetail(false)
+	}
+}
+
+func (l *Logger) StartSpan(name string) Span {
+	if l.span != "" {
+		name = l.span + "/" + name
 	}
-	l.attributes = nil
-	l.attribute = nil
+	s := &spanLogger{Logger: &Logger{Logger: l.Logger, tx: l.tx, actor: l.actor, span: name}}
+	var span trace.Span
+	if parent := l.traceContext(); parent != nil {
+		s.ctx, span = tracer(parent).Start(parent, name)
+	}
+	s.timing = l.tx.startSpan(name, span)
+	return s
+}
+
+type spanLogger struct {
+	*Logger
+	timing *SpanTiming
+}
+
+func (s *spanLogger) AddOutput(node, cmd string, custom interface{}) ILogDetail {
+	s.Logger.AddOutput(node, cmd, custom)
+	return s
+}
+
+func (s *spanLogger) Finish() {
+	s.tx.finishSpan(s.timing)
 }
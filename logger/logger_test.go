@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// TestStandalone checks a logger outside of a request writes on End and
+// keeps its attributes and open spans for the next line.
+func TestStandalone(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, nil)), "job", map[string]any{"route": "reminder"})
+
+	span := l.StartSpan("scan")
+	l.AddOutput("gorm", "due_todos", nil).End()
+	span.AddError("gorm", "due_todos", "input", nil, errors.New("locked"))
+	span.Finish()
+	l.End()
+
+	var lines []map[string]any
+	d := json.NewDecoder(&buf)
+	for d.More() {
+		var line map[string]any
+		if err := d.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("want a line per End with something to write, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if attribute, _ := line["attribute"].(map[string]any); attribute["route"] != "reminder" {
+			t.Errorf("line %d: want the attributes kept, got %v", i, line["attribute"])
+		}
+	}
+	if _, ok := lines[1]["spans"]; ok {
+		t.Errorf("want the open span left for later, got %v", lines[1]["spans"])
+	}
+	if spans, _ := lines[2]["spans"].([]any); len(spans) != 1 {
+		t.Errorf("want the finished span in the last line, got %v", lines[2]["spans"])
+	}
+	if l.Summary() != nil {
+		t.Error("want no summary outside of a request")
+	}
+}
+
+// TestAddAfterFlush checks a request still streaming once flushed doesn't
+// keep buffering what it logs.
+func TestAddAfterFlush(t *testing.T) {
+	tx := NewTransaction(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+	l := tx.Logger("stream", nil)
+	l.AddInput("client", "stream", nil)
+	tx.Flush("GET", "/stream", 200)
+
+	for i := 0; i < 10; i++ {
+		l.AddOutput("mongo", "member_role", nil)
+		l.AddInput("mongo", "member_role", "viewer")
+		l.StartSpan("event").Finish()
+	}
+	if len(tx.events) != 0 || len(tx.spans) != 0 || len(tx.calls) != 0 {
+		t.Errorf("want nothing kept after the flush, got %d events, %d spans, %d calls", len(tx.events), len(tx.spans), len(tx.calls))
+	}
+}
+
+func TestPoll(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		poll func(p *Poll)
+		want int
+	}{
+		{"idle", func(p *Poll) {
+			p.AddOutput("gorm", "pending_outbox", nil).End()
+			p.AddInput("gorm", "pending_outbox", 0)
+			p.End()
+		}, 0},
+		{"kept", func(p *Poll) {
+			p.AddOutput("gorm", "pending_outbox", nil).End()
+			p.AddInput("gorm", "pending_outbox", 1)
+			p.Keep()
+			p.AddOutput("outbox", "publish", nil).End()
+		}, 2},
+		{"failed", func(p *Poll) {
+			p.AddOutput("gorm", "pending_outbox", nil).End()
+			p.AddError("gorm", "pending_outbox", "input", nil, errors.New("locked"))
+		}, 1},
+	} {
+		var buf bytes.Buffer
+		tt.poll(NewPoll(slog.New(slog.NewJSONHandler(&buf, nil)), "outbox_relay", nil))
+		if got := bytes.Count(buf.Bytes(), []byte("\n")); got != tt.want {
+			t.Errorf("%s: want %d lines, got %d:\n%s", tt.name, tt.want, got, buf.String())
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Mask replaces redacted values in the log.
+const Mask = "[REDACTED]"
+
+// RedactConfig says what to mask in the events of a detail log.
+//
+// Paths are dot separated keys into the data of an event, such as
+// body.password for the body logged by Incoming. A * stands for any one
+// key and ** for any number of them, arrays don't add a key. Headers are
+// names masked in any object logged under a "headers" key, compared case
+// insensitively. Patterns are regular expressions masked wherever they
+// match a string.
+type RedactConfig struct {
+	Paths    []string `json:"paths,omitempty"`
+	Headers  []string `json:"headers,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	// Routes add rules for the requests of a route, keyed by "METHOD path"
+	// or by the path alone for every method, e.g. "POST /todo/:id/comments".
+	Routes map[string]RedactConfig `json:"routes,omitempty"`
+	// Override drops the default rules instead of adding to them.
+	Override bool `json:"override,omitempty"`
+}
+
+// DefaultRedactConfig masks credentials, emails, bearer tokens, JWTs and
+// card numbers.
+func DefaultRedactConfig() RedactConfig {
+	return RedactConfig{
+		Paths: []string{
+			"**.password",
+			"**.secret",
+			"**.token",
+			"**.access_token",
+			"**.refresh_token",
+		},
+		Headers: []string{
+			"Authorization",
+			"Cookie",
+			"Set-Cookie",
+			"X-Api-Key",
+		},
+		Patterns: []string{
+			`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+			`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`,
+			`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+			`\b(?:\d[ -]?){12,18}\d\b`,
+		},
+	}
+}
+
+// LoadRedactConfig reads a RedactConfig from the JSON file at path. The
+// rules of the file are added to the defaults unless it sets override.
+func LoadRedactConfig(path string) (RedactConfig, error) {
+	config := DefaultRedactConfig()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	var file RedactConfig
+	if err := json.Unmarshal(b, &file); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Override {
+		config = RedactConfig{}
+	}
+	config.Paths = append(config.Paths, file.Paths...)
+	config.Headers = append(config.Headers, file.Headers...)
+	config.Patterns = append(config.Patterns, file.Patterns...)
+	config.Routes = file.Routes
+	return config, nil
+}
+
+func (c RedactConfig) merge(o RedactConfig) RedactConfig {
+	if o.Override {
+		return RedactConfig{Paths: o.Paths, Headers: o.Headers, Patterns: o.Patterns}
+	}
+	return RedactConfig{
+		Paths:    append(append([]string{}, c.Paths...), o.Paths...),
+		Headers:  append(append([]string{}, c.Headers...), o.Headers...),
+		Patterns: append(append([]string{}, c.Patterns...), o.Patterns...),
+	}
+}
+
+// Redactor masks sensitive values in log events before they are written.
+type Redactor struct {
+	rules  *rules
+	routes map[string]*rules
+}
+
+type rules struct {
+	paths    [][]string
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor compiles config, failing on an invalid pattern.
+func NewRedactor(config RedactConfig) (*Redactor, error) {
+	base, err := compile(config)
+	if err != nil {
+		return nil, err
+	}
+	r := &Redactor{rules: base, routes: map[string]*rules{}}
+	for route, override := range config.Routes {
+		compiled, err := compile(config.merge(override))
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+		r.routes[route] = compiled
+	}
+	return r, nil
+}
+
+func compile(config RedactConfig) (*rules, error) {
+	r := &rules{headers: map[string]bool{}}
+	for _, path := range config.Paths {
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+	for _, header := range config.Headers {
+		r.headers[strings.ToLower(header)] = true
+	}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+var defaultRedactor atomic.Pointer[Redactor]
+
+func init() {
+	r, err := NewRedactor(DefaultRedactConfig())
+	if err != nil {
+		panic(err)
+	}
+	defaultRedactor.Store(r)
+}
+
+// SetRedactor makes r redact the loggers created by New from now on, nil
+// turns redaction off.
+func SetRedactor(r *Redactor) {
+	defaultRedactor.Store(r)
+}
+
+// forRoute picks the rules of the request made with method to route.
+func (r *Redactor) forRoute(method, route string) *rules {
+	if rules, ok := r.routes[method+" "+route]; ok {
+		return rules
+	}
+	if rules, ok := r.routes[route]; ok {
+		return rules
+	}
+	return r.rules
+}
+
+// Redact returns a copy of v, as it would be serialised, with what the
+// rules of the route match masked.
+func (r *Redactor) Redact(method, route string, v any) any {
+	if r == nil || v == nil {
+		return v
+	}
+	rules := r.forRoute(method, route)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var doc any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return v
+	}
+	return rules.walk(nil, doc)
+}
+
+func (r *rules) walk(path []string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		headers := len(path) > 0 && path[len(path)-1] == "headers"
+		for k, value := range v {
+			key := append(path[:len(path):len(path)], k)
+			if r.matchPath(key) || (headers && r.headers[strings.ToLower(k)]) {
+				v[k] = Mask
+				continue
+			}
+			v[k] = r.walk(key, value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = r.walk(path, value)
+		}
+		return v
+	case string:
+		for _, re := range r.patterns {
+			v = re.ReplaceAllString(v, Mask)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (r *rules) matchPath(path []string) bool {
+	for _, pattern := range r.paths {
+		if matchSegments(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path against pattern, where * is any one key and
+// ** any number of keys.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (pattern[0] != "*" && pattern[0] != path[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
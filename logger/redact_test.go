@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// emit logs the events of one request to route and returns the written
+// line as parsed JSON.
+func emit(t *testing.T, r *Redactor, method, route string, log func(ILogDetail)) map[string]any {
+	t.Helper()
+	SetRedactor(r)
+	t.Cleanup(func() {
+		d, _ := NewRedactor(DefaultRedactConfig())
+		SetRedactor(d)
+	})
+
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, nil)), "test", map[string]any{
+		"route":   route,
+		"method":  method,
+		"user_id": "alice",
+	})
+	log(l)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	return line
+}
+
+func event(line map[string]any, i int, field string) map[string]any {
+	events, _ := line["events"].([]any)
+	if i >= len(events) {
+		return nil
+	}
+	e, _ := events[i].(map[string]any)
+	data, _ := e[field].(map[string]any)
+	return data
+}
+
+func TestRedactDefaults(t *testing.T) {
+	r, err := NewRedactor(DefaultRedactConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type login struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	line := emit(t, r, "POST", "/todo", func(l ILogDetail) {
+		l.AddInput("client", "create todo", map[string]any{
+			"headers": map[string]string{
+				"Authorization": "Bearer abc.def",
+				"Content-Type":  "application/json",
+			},
+			"body": map[string]any{
+				"text":  "call bob@example.com about card 4111 1111 1111 1111",
+				"login": login{User: "alice", Password: "hunter2"},
+				"tags":  []any{map[string]any{"token": "t0k3n"}},
+				"count": 3,
+			},
+		})
+		l.AddError("mongo", "insert", "output", nil, errors.New("duplicate key carol@example.com"))
+	})
+
+	input := event(line, 0, "input")
+	headers := input["headers"].(map[string]any)
+	body := input["body"].(map[string]any)
+	for name, tt := range map[string]struct {
+		got, want any
+	}{
+		"authorization header": {headers["Authorization"], Mask},
+		"other header":         {headers["Content-Type"], "application/json"},
+		"patterns":             {body["text"], "call " + Mask + " about card " + Mask},
+		"nested password":      {body["login"].(map[string]any)["password"], Mask},
+		"user":                 {body["login"].(map[string]any)["user"], "alice"},
+		"token in an array":    {body["tags"].([]any)[0].(map[string]any)["token"], Mask},
+		"number":               {body["count"], float64(3)},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: want %v, got %v", name, tt.want, tt.got)
+		}
+	}
+
+	events := line["events"].([]any)
+	msg := events[1].(map[string]any)["msg"].(map[string]any)
+	if msg["error"] != "duplicate key "+Mask {
+		t.Errorf("want the email masked in the error, got %v", msg["error"])
+	}
+}
+
+func TestRedactRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redact.json")
+	os.WriteFile(path, []byte(`{
+		"paths": ["body.text"],
+		"routes": {
+			"POST /todo/:id/comments": {"paths": ["body.body"]},
+			"/healthz": {"override": true}
+		}
+	}`), 0o644)
+	config, err := LoadRedactConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRedactor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := func(method, route string) map[string]any {
+		line := emit(t, r, method, route, func(l ILogDetail) {
+			l.AddInput("client", "cmd", map[string]any{"body": map[string]any{
+				"text":  "secret plans",
+				"body":  "more plans",
+				"email": "bob@example.com",
+			}})
+			l.End()
+		})
+		return event(line, 0, "input")["body"].(map[string]any)
+	}
+
+	for _, tt := range []struct {
+		method, route    string
+		text, body, mail string
+	}{
+		{"POST", "/todo", Mask, "more plans", Mask},
+		{"POST", "/todo/:id/comments", Mask, Mask, Mask},
+		{"GET", "/todo/:id/comments", Mask, "more plans", Mask},
+		{"GET", "/healthz", "secret plans", "more plans", "bob@example.com"},
+	} {
+		body := input(tt.method, tt.route)
+		if body["text"] != tt.text || body["body"] != tt.body || body["email"] != tt.mail {
+			t.Errorf("%s %s: want %q %q %q, got %v", tt.method, tt.route, tt.text, tt.body, tt.mail, body)
+		}
+	}
+}
+
+func TestRedactOff(t *testing.T) {
+	line := emit(t, nil, "POST", "/todo", func(l ILogDetail) {
+		l.AddOutput("client", "cmd", map[string]any{"password": "hunter2"}).End()
+	})
+	if got := event(line, 0, "output")["password"]; got != "hunter2" {
+		t.Errorf("want nothing masked without a redactor, got %v", got)
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"body.password", "body.password", true},
+		{"body.password", "password", false},
+		{"*.password", "body.password", true},
+		{"*.password", "body.user.password", false},
+		{"**.password", "password", true},
+		{"**.password", "body.user.password", true},
+		{"body.**", "body.user.name", true},
+		{"body.**.name", "query.user.name", false},
+	} {
+		if got := matchSegments(strings.Split(tt.pattern, "."), strings.Split(tt.path, ".")); got != tt.want {
+			t.Errorf("%s ~ %s: want %v, got %v", tt.pattern, tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Summary collects one line about a whole request next to its DETAIL
+// events: the result and, per node and command, how the calls made on
+// behalf of the request went. It's flushed with the Transaction of the
+// request. A nil Summary ignores everything.
+type Summary struct {
+	mu       sync.Mutex
+	logger   *slog.Logger
+	start    time.Time
+	code     string
+	desc     string
+	nodes    []*NodeSummary
+	redactor *Redactor
+}
+
+// NodeSummary counts the calls to one command of a node. A call starts
+// with AddOutput and succeeds with AddInput or fails with AddError.
+type NodeSummary struct {
+	Node    string `json:"node"`
+	Cmd     string `json:"cmd"`
+	Count   int    `json:"count"`
+	Success int    `json:"success"`
+	Failed  int    `json:"failed"`
+}
+
+func newSummary(s *slog.Logger, r *Redactor, start time.Time) *Summary {
+	return &Summary{logger: s, start: start, redactor: r}
+}
+
+// SetResult sets the application result code and description, by default
+// they follow the HTTP status.
+func (s *Summary) SetResult(code, desc string) *Summary {
+	if s == nil {
+		return s
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code, s.desc = code, desc
+	return s
+}
+
+// Nodes returns the calls counted so far.
+func (s *Summary) Nodes() []NodeSummary {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]NodeSummary, len(s.nodes))
+	for i, n := range s.nodes {
+		out[i] = *n
+	}
+	return out
+}
+
+// node finds the counters of node and cmd, the caller holds mu.
+func (s *Summary) node(node, cmd string) *NodeSummary {
+	for _, n := range s.nodes {
+		if n.Node == node && n.Cmd == cmd {
+			return n
+		}
+	}
+	n := &NodeSummary{Node: node, Cmd: cmd}
+	s.nodes = append(s.nodes, n)
+	return n
+}
+
+// record counts an event of a detail log. The client node is the request
+// itself, its outcome is the status flushed with the summary.
+func (s *Summary) record(node, cmd, inOut string, failed bool) {
+	if s == nil || node == "client" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.node(node, cmd)
+	switch {
+	case failed:
+		n.Failed++
+	case inOut == "output":
+		n.Count++
+		return
+	default:
+		n.Success++
+	}
+	// a result without a logged request still was a call
+	n.Count = max(n.Count, n.Success+n.Failed)
+}
+
+// flush writes the summary of a request to method route answered with
+// status.
+func (s *Summary) flush(ctx context.Context, method, route string, status int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, desc := s.code, s.desc
+	if code == "" {
+		code = strconv.Itoa(status)
+	}
+	if desc == "" {
+		desc = http.StatusText(status)
+	}
+	nodes := make([]NodeSummary, len(s.nodes))
+	for i, n := range s.nodes {
+		nodes[i] = *n
+	}
+
+	s.logger.Log(ctx, slog.LevelInfo, method+" "+route,
+		slog.String("log_name", "SUMMARY"),
+		slog.String("method", method),
+		slog.String("route", route),
+		slog.Int("result_code", status),
+		slog.String("app_result_code", code),
+		slog.Any("app_result_desc", s.redactor.Redact(method, route, desc)),
+		slog.Any("startTime", s.start),
+		slog.Any("endTime", time.Now()),
+		slog.Int64("duration_ms", time.Since(s.start).Milliseconds()),
+		slog.Any("nodes", nodes))
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans under parent with the provider of the span of
+// parent, the one the router traces the request with.
+func tracer(parent context.Context) trace.Tracer {
+	return trace.SpanFromContext(parent).TracerProvider().Tracer("github.com/sing3demons/todoapi/logger")
+}
+
+// SetContext makes the span of ctx, the one of the request, the parent of
+// the spans of the transaction and adds its trace and span ids to the
+// records of the transaction. It's called before any logger is made.
+//
+// Every call to a node other than the client gets a span of its own, see
+// call.
+func (tx *Transaction) SetContext(ctx context.Context) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.ctx = ctx
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		tx.logger = tx.logger.With(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()))
+		if tx.summary != nil {
+			tx.summary.logger = tx.logger
+		}
+	}
+}
+
+// traceContext is the parent of the spans started by l, nil when the
+// transaction isn't traced.
+func (l *Logger) traceContext() context.Context {
+	if l.ctx != nil {
+		return l.ctx
+	}
+	l.tx.mu.Lock()
+	defer l.tx.mu.Unlock()
+	return l.tx.ctx
+}
+
+func spanID(span trace.Span) string {
+	if span == nil {
+		return ""
+	}
+	if sc := span.SpanContext(); sc.IsValid() {
+		return sc.SpanID().String()
+	}
+	return ""
+}
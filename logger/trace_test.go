@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceCalls(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ctx, request := tp.Tracer("test").Start(context.Background(), "GET /todo")
+
+	tx := NewTransaction(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	tx.SetContext(ctx)
+	l := tx.Logger("list", nil)
+	l.AddInput("client", "list", nil)
+	// the same command twice at once, the answers end the latest first
+	l.AddOutput("mongo", "count", nil).End()
+	l.AddOutput("mongo", "count", nil).End()
+	l.AddInput("mongo", "count", 1)
+	l.AddError("mongo", "count", "input", nil, errors.New("timeout"))
+	// failed before anything was sent
+	l.AddError("gorm", "begin", "output", nil, errors.New("locked"))
+	// never answered
+	l.AddOutput("webhook", "post", nil).End()
+	l.AddOutput("client", "list", nil)
+
+	if n := len(exp.GetSpans()); n != 3 {
+		t.Errorf("want the answered calls ended, got %d spans", n)
+	}
+	tx.Flush("GET", "/todo", 200)
+	request.End()
+
+	var failed, unfinished, children int
+	for _, s := range exp.GetSpans() {
+		if s.Name == "GET /todo" {
+			continue
+		}
+		if s.Parent.SpanID() == request.SpanContext().SpanID() {
+			children++
+		}
+		if s.Status.Code == codes.Error {
+			failed++
+		}
+		for _, a := range s.Attributes {
+			if a == attribute.Bool("unfinished", true) {
+				unfinished++
+			}
+		}
+	}
+	if children != 4 || failed != 2 || unfinished != 1 {
+		t.Errorf("want 4 calls of the request, 2 failed and 1 unfinished, got %d, %d and %d", children, failed, unfinished)
+	}
+}
+
+func TestStandaloneNotTraced(t *testing.T) {
+	l := New(slog.New(slog.NewJSONHandler(io.Discard, nil)), "job", nil).(*Logger)
+	l.AddOutput("mongo", "scan", nil).End()
+	l.AddInput("mongo", "scan", nil)
+	if len(l.tx.calls) != 0 || l.traceContext() != nil {
+		t.Error("want no spans outside of a traced request")
+	}
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transaction buffers the DETAIL events of one request, added by any of
+// the loggers made for it, and writes them as one line together with the
+// SUMMARY when the request is flushed.
+type Transaction struct {
+	mu         sync.Mutex
+	logger     *slog.Logger
+	redactor   *Redactor
+	start      time.Time
+	event      string
+	attribute  map[string]any
+	events     []LogEvent
+	spans      []*SpanTiming
+	summary    *Summary
+	flushed    bool
+	standalone bool
+	// held transactions of a Poll write nothing until kept.
+	held  bool
+	debug bool
+	// ctx carries the span of the request, see SetContext.
+	ctx   context.Context
+	calls map[string][]*call
+}
+
+// SpanTiming is how long a span of a transaction took.
+type SpanTiming struct {
+	Name        string        `json:"name"`
+	StartTime   time.Time     `json:"startTime"`
+	EndTime     time.Time     `json:"endTime"`
+	ProcessTime time.Duration `json:"processTime"`
+	// Unfinished spans were still open when the transaction was flushed.
+	Unfinished bool `json:"unfinished,omitempty"`
+
+	trace trace.Span
+}
+
+func newTransaction(s *slog.Logger) *Transaction {
+	if s == nil {
+		s = slog.Default()
+	}
+	return &Transaction{
+		logger:   s,
+		redactor: defaultRedactor.Load(),
+		start:    time.Now(),
+		calls:    map[string][]*call{},
+	}
+}
+
+// NewTransaction starts the transaction of a request, s carries its
+// session.
+func NewTransaction(s *slog.Logger) *Transaction {
+	tx := newTransaction(s)
+	tx.summary = newSummary(tx.logger, tx.redactor, tx.start)
+	return tx
+}
+
+// Logger makes a logger adding to the transaction. The attributes of the
+// first one, such as the route and method, describe the transaction.
+func (tx *Transaction) Logger(name string, attribute map[string]any) ILogDetail {
+	tx.mu.Lock()
+	if tx.attribute == nil {
+		tx.attribute = attribute
+	}
+	tx.mu.Unlock()
+	return newLogger(tx, attribute)
+}
+
+// ForceDebug logs everything of the transaction, whatever the levels.
+func (tx *Transaction) ForceDebug() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.debug = true
+}
+
+func (tx *Transaction) keep() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.held = false
+}
+
+func (tx *Transaction) holding() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.held
+}
+
+// context is what the records of the transaction are logged with.
+func (tx *Transaction) context() context.Context {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.debug {
+		return ForceDebug(context.Background())
+	}
+	return context.Background()
+}
+
+// Summary is the summary of the request of the transaction.
+func (tx *Transaction) Summary() *Summary {
+	return tx.summary
+}
+
+// add buffers e for the DETAIL line. Once flushed nothing is written
+// anymore, so the events of a request still streaming are dropped rather
+// than kept for as long as it lasts.
+func (tx *Transaction) add(event string, e LogEvent) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.flushed {
+		return
+	}
+	tx.event = event
+	tx.events = append(tx.events, e)
+}
+
+func (tx *Transaction) startSpan(name string, span trace.Span) *SpanTiming {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	s := &SpanTiming{Name: name, StartTime: time.Now(), trace: span}
+	if !tx.flushed {
+		tx.spans = append(tx.spans, s)
+	}
+	return s
+}
+
+func (tx *Transaction) finishSpan(s *SpanTiming) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if s.EndTime.IsZero() {
+		s.EndTime = time.Now()
+		s.ProcessTime = s.EndTime.Sub(s.StartTime)
+		if s.trace != nil {
+			s.trace.End()
+		}
+	}
+}
+
+// Panic adds the value a handler panicked with to the transaction.
+func (tx *Transaction) Panic(v any) {
+	tx.add("panic", LogEvent{
+		Name:      "panic",
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Msg:       map[string]string{"error": fmt.Sprint(v)},
+	})
+}
+
+// Flush writes the DETAIL and SUMMARY of a request to method route
+// answered with status. Only the first call writes anything.
+func (tx *Transaction) Flush(method, route string, status int) {
+	tx.mu.Lock()
+	if tx.flushed {
+		tx.mu.Unlock()
+		return
+	}
+	tx.flushed = true
+	tx.mu.Unlock()
+
+	tx.writeDetail(true)
+	tx.summary.flush(tx.context(), method, route, status)
+}
+
+// writeDetail writes the events buffered so far as one DETAIL line. Spans
+// still open are kept for the next line unless it's the final one.
+func (tx *Transaction) writeDetail(final bool) {
+	now := time.Now()
+	tx.mu.Lock()
+	events, event := tx.events, tx.event
+	var timings []SpanTiming
+	var open []*SpanTiming
+	for _, s := range tx.spans {
+		switch {
+		case !s.EndTime.IsZero():
+			timings = append(timings, *s)
+		case final:
+			if s.trace != nil {
+				s.trace.SetAttributes(attribute.Bool("unfinished", true))
+				s.trace.End()
+			}
+			timings = append(timings, SpanTiming{
+				Name:        s.Name,
+				StartTime:   s.StartTime,
+				EndTime:     now,
+				ProcessTime: now.Sub(s.StartTime),
+				Unfinished:  true,
+			})
+		default:
+			open = append(open, s)
+		}
+	}
+	tx.events, tx.spans = nil, open
+	if final {
+		tx.endCalls()
+	}
+	tx.mu.Unlock()
+	if len(events) == 0 && len(timings) == 0 {
+		return
+	}
+
+	method, _ := tx.attribute["method"].(string)
+	route, _ := tx.attribute["route"].(string)
+	redactEvents(tx.redactor, method, route, events)
+
+	attrs := []any{
+		slog.String("log_name", "DETAIL"),
+		slog.Any("startTime", tx.start),
+		slog.Any("endTime", now),
+		slog.Any("processTime", now.Sub(tx.start)),
+		slog.Any("attribute", tx.redactor.Redact(method, route, tx.attribute)),
+		slog.Any("events", events),
+	}
+	if len(timings) > 0 {
+		attrs = append(attrs, slog.Any("spans", timings))
+	}
+	tx.logger.Log(tx.context(), slog.LevelInfo, strings.ReplaceAll(event, " ", "_"), attrs...)
+}
+
+// redactEvents masks the sensitive values of events, see RedactConfig.
+func redactEvents(r *Redactor, method, route string, events []LogEvent) {
+	if r == nil {
+		return
+	}
+	for i, e := range events {
+		e.Input = r.Redact(method, route, e.Input)
+		e.Output = r.Redact(method, route, e.Output)
+		if e.Msg != nil {
+			msg, _ := r.Redact(method, route, e.Msg).(map[string]any)
+			e.Msg = map[string]string{}
+			for k, v := range msg {
+				e.Msg[k], _ = v.(string)
+			}
+		}
+		events[i] = e
+	}
+}
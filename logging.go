@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/router"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// gormLog logs through the default gorm logger at the level of the gorm
+// component, read on every call so that it can change at runtime.
+type gormLog struct{}
+
+func (gormLog) current() gormlogger.Interface {
+	level := gormlogger.Error
+	switch l := logger.Level(logger.Gorm).Level(); {
+	case l < slog.LevelInfo:
+		// every statement
+		level = gormlogger.Info
+	case l < slog.LevelError:
+		// slow statements and warnings
+		level = gormlogger.Warn
+	}
+	return gormlogger.Default.LogMode(level)
+}
+
+func (g gormLog) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return g
+}
+
+func (g gormLog) Info(ctx context.Context, msg string, data ...interface{}) {
+	g.current().Info(ctx, msg, data...)
+}
+
+func (g gormLog) Warn(ctx context.Context, msg string, data ...interface{}) {
+	g.current().Warn(ctx, msg, data...)
+}
+
+func (g gormLog) Error(ctx context.Context, msg string, data ...interface{}) {
+	g.current().Error(ctx, msg, data...)
+}
+
+func (g gormLog) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	g.current().Trace(ctx, begin, fc, err)
+}
+
+// mongoSink writes the log of the mongo driver to l, which filters it by
+// the level of the mongo component.
+type mongoSink struct {
+	l *slog.Logger
+}
+
+func (s mongoSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	// the driver logs V(1) for information and V(2) for debugging
+	l := slog.LevelInfo
+	if level > 1 {
+		l = slog.LevelDebug
+	}
+	s.l.Log(context.Background(), l, msg, keysAndValues...)
+}
+
+func (s mongoSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.l.Error(msg, append(keysAndValues, slog.Any("error", err))...)
+}
+
+// LogLevels answers the level of every log component.
+func LogLevels(c router.IContext) {
+	c.JSON(http.StatusOK, logger.Levels())
+}
+
+// SetLogLevels changes the levels of the components in the body, such as
+// {"gorm": "debug"}, until the next restart.
+func SetLogLevels(c router.IContext) {
+	cmd := "set log levels"
+	node := "client"
+	log := c.Log("set_log_levels")
+	log.AddInput(node, cmd, c.Incoming())
+
+	var body map[string]string
+	if err := c.Bind(&body); err != nil {
+		log.AddError(node, cmd, "output", map[string]any{"error": "bad_request"}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	// check every level before changing any
+	known := logger.Levels()
+	for component, level := range body {
+		var l slog.Level
+		err := l.UnmarshalText([]byte(level))
+		if _, ok := known[component]; !ok {
+			err = fmt.Errorf("unknown log component %q", component)
+		}
+		if err != nil {
+			log.AddError(node, cmd, "output", map[string]any{"error": "bad_request"}, err)
+			c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+	for component, level := range body {
+		logger.SetLevel(component, level)
+	}
+
+	levels := logger.Levels()
+	log.AddOutput(node, cmd, levels).End()
+	c.JSON(http.StatusOK, levels)
+}
+
+// LogSinks answers how the log exporters are doing.
+func LogSinks(c router.IContext) {
+	c.JSON(http.StatusOK, sinks.Stats())
+}
@@ -0,0 +1,225 @@
+// Package logquery finds the transactions of the detail log, in the
+// current files and the rotated ones, and lays them out as timelines.
+package logquery
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+)
+
+// ErrStop ends a query from its callback without an error.
+var ErrStop = errors.New("stop")
+
+// Filter selects transactions, its zero value selects all of them.
+type Filter struct {
+	Session string
+	// Event is the name of the transaction or of one of its events, e.g.
+	// mongo.list_todo.
+	Event string
+	// Node is the node of one of the events, e.g. mongo.
+	Node string
+	// Since and Until bound the start of the transaction.
+	Since time.Time
+	Until time.Time
+	// Errors only selects the transactions with an error.
+	Errors bool
+}
+
+// Transaction is one DETAIL record.
+type Transaction struct {
+	File        string              `json:"file"`
+	Event       string              `json:"event"`
+	Session     string              `json:"session,omitempty"`
+	Method      string              `json:"method,omitempty"`
+	Route       string              `json:"route,omitempty"`
+	StartTime   time.Time           `json:"startTime"`
+	EndTime     time.Time           `json:"endTime"`
+	ProcessTime time.Duration       `json:"processTime"`
+	Attribute   map[string]any      `json:"attribute,omitempty"`
+	Timeline    []Entry             `json:"timeline"`
+	Spans       []logger.SpanTiming `json:"spans,omitempty"`
+}
+
+// Entry is an event of a transaction placed on its timeline.
+type Entry struct {
+	logger.LogEvent
+	// Offset is the time from the start of the transaction.
+	Offset time.Duration `json:"offset"`
+	// Duration is the time until the next event, or the end of the
+	// transaction for the last one. An output to a node followed by its
+	// input is the time the node took.
+	Duration time.Duration `json:"duration"`
+}
+
+// Error is the error of the entry, if any.
+func (e Entry) Error() string {
+	return e.Msg["error"]
+}
+
+// Failed tells if one of the events of t is an error.
+func (t Transaction) Failed() bool {
+	return slices.ContainsFunc(t.Timeline, func(e Entry) bool { return e.Error() != "" })
+}
+
+// record is a DETAIL line as the logger writes it.
+type record struct {
+	LogName     string              `json:"log_name"`
+	Event       string              `json:"event"`
+	Session     string              `json:"session"`
+	StartTime   time.Time           `json:"startTime"`
+	EndTime     time.Time           `json:"endTime"`
+	ProcessTime time.Duration       `json:"processTime"`
+	Attribute   map[string]any      `json:"attribute"`
+	Events      []logger.LogEvent   `json:"events"`
+	Spans       []logger.SpanTiming `json:"spans"`
+}
+
+// Files lists the log files of dir, current and rotated, in the order they
+// were written.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && (strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Query calls fn with the transactions of the files of dir matching f, in
+// the order they were written. Lines that aren't DETAIL records are
+// skipped. fn returns ErrStop to end the query early.
+func Query(dir string, f Filter, fn func(Transaction) error) error {
+	files, err := Files(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := queryFile(file, f, fn); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func queryFile(file string, f Filter, fn func(Transaction) error) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var r io.Reader = fh
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, readErr := br.ReadBytes('\n')
+		// cheap test before decoding, most lines of a busy log aren't
+		// DETAIL
+		if bytes.Contains(line, []byte(`"log_name":"DETAIL"`)) {
+			var rec record
+			if json.Unmarshal(line, &rec) == nil && rec.LogName == "DETAIL" {
+				t := transaction(file, rec)
+				if f.Match(t) {
+					if err := fn(t); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func transaction(file string, rec record) Transaction {
+	t := Transaction{
+		File:        file,
+		Event:       rec.Event,
+		Session:     rec.Session,
+		StartTime:   rec.StartTime,
+		EndTime:     rec.EndTime,
+		ProcessTime: rec.ProcessTime,
+		Attribute:   rec.Attribute,
+		Spans:       rec.Spans,
+	}
+	t.Method, _ = rec.Attribute["method"].(string)
+	t.Route, _ = rec.Attribute["route"].(string)
+	if t.Session == "" {
+		t.Session, _ = rec.Attribute["session"].(string)
+	}
+
+	t.Timeline = make([]Entry, len(rec.Events))
+	times := make([]time.Time, len(rec.Events))
+	for i, e := range rec.Events {
+		t.Timeline[i].LogEvent = e
+		times[i], _ = time.Parse(time.RFC3339Nano, e.Timestamp)
+		if !times[i].IsZero() && !t.StartTime.IsZero() {
+			t.Timeline[i].Offset = max(times[i].Sub(t.StartTime), 0)
+		}
+	}
+	for i := range t.Timeline {
+		next := t.EndTime
+		if i+1 < len(times) {
+			next = times[i+1]
+		}
+		if !times[i].IsZero() && !next.IsZero() {
+			t.Timeline[i].Duration = max(next.Sub(times[i]), 0)
+		}
+	}
+	return t
+}
+
+// Match tells if f selects t.
+func (f Filter) Match(t Transaction) bool {
+	switch {
+	case f.Session != "" && t.Session != f.Session:
+		return false
+	case !f.Since.IsZero() && t.StartTime.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && t.StartTime.After(f.Until):
+		return false
+	case f.Errors && !t.Failed():
+		return false
+	}
+	if f.Event != "" && t.Event != f.Event && !slices.ContainsFunc(t.Timeline, func(e Entry) bool { return e.Name == f.Event }) {
+		return false
+	}
+	if f.Node != "" && !slices.ContainsFunc(t.Timeline, func(e Entry) bool { return strings.HasPrefix(e.Name, f.Node+".") }) {
+		return false
+	}
+	return true
+}
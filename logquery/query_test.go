@@ -0,0 +1,147 @@
+package logquery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/logsink"
+)
+
+// request logs a request of session the way the router does.
+func request(s *slog.Logger, session, route string, fail bool) {
+	tx := logger.NewTransaction(s.With("session", session))
+	log := tx.Logger("list", map[string]any{"method": "GET", "route": route, "session": session})
+	log.AddInput("client", "list todo", map[string]any{"query": "completed=true"})
+	log.AddOutput("mongo", "list_todo", map[string]any{"filter": "completed"}).End()
+	time.Sleep(5 * time.Millisecond)
+	if fail {
+		log.AddError("mongo", "list_todo", "input", nil, errors.New("connection reset"))
+	} else {
+		log.AddInput("mongo", "list_todo", 2)
+	}
+	log.AddOutput("client", "list todo", map[string]any{"total": 2})
+	tx.Flush("GET", route, 200)
+}
+
+// writeLogs writes three requests, the first two to a rotated archive.
+func writeLogs(t *testing.T) string {
+	dir := t.TempDir()
+	s, err := logsink.New([]logsink.Config{{Type: "file", Path: filepath.Join(dir, "todoapi.log")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(s)
+	request(l, "s-1", "/todo", false)
+	request(l, "s-2", "/todo", true)
+	s.Close()
+
+	// rotate what was written so far
+	current := filepath.Join(dir, "todoapi_"+time.Now().Format("2006-01-02")+".log")
+	b, err := os.ReadFile(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(b)
+	w.Close()
+	os.WriteFile(filepath.Join(dir, "todoapi_2000-01-01.log.gz"), gz.Bytes(), 0o644)
+	os.Remove(current)
+
+	s, _ = logsink.New([]logsink.Config{{Type: "file", Path: filepath.Join(dir, "todoapi.log")}})
+	request(slog.New(s), "s-3", "/lists", false)
+	s.Close()
+	return dir
+}
+
+func query(t *testing.T, dir string, f Filter) []Transaction {
+	t.Helper()
+	var found []Transaction
+	if err := Query(dir, f, func(tx Transaction) error {
+		found = append(found, tx)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func sessions(found []Transaction) string {
+	var s []string
+	for _, t := range found {
+		s = append(s, t.Session)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestQuery(t *testing.T) {
+	dir := writeLogs(t)
+
+	all := query(t, dir, Filter{})
+	if got := sessions(all); got != "s-1,s-2,s-3" {
+		t.Fatalf("want the transactions of the archive and the current file, got %s", got)
+	}
+	for name, f := range map[string]Filter{
+		"s-2":         {Session: "s-2"},
+		"s-1,s-2":     {Event: "mongo.list_todo", Since: all[0].StartTime, Until: all[1].StartTime},
+		"s-1,s-2,s-3": {Node: "mongo"},
+		"":            {Node: "redis"},
+	} {
+		if got := sessions(query(t, dir, f)); got != name {
+			t.Errorf("%+v: want %q, got %q", f, name, got)
+		}
+	}
+	failed := query(t, dir, Filter{Errors: true})
+	if sessions(failed) != "s-2" || failed[0].Timeline[2].Error() != "connection reset" {
+		t.Errorf("want the failed request, got %+v", failed)
+	}
+
+	var stopped int
+	Query(dir, Filter{}, func(Transaction) error {
+		stopped++
+		return ErrStop
+	})
+	if stopped != 1 {
+		t.Errorf("want the query stopped after one, got %d", stopped)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	tx := query(t, writeLogs(t), Filter{Session: "s-1"})[0]
+
+	if tx.Method != "GET" || tx.Route != "/todo" || len(tx.Timeline) != 4 {
+		t.Fatalf("want the 4 events of GET /todo, got %+v", tx)
+	}
+	var names []string
+	var total time.Duration
+	for _, e := range tx.Timeline {
+		names = append(names, e.Name)
+		total += e.Duration
+	}
+	if got := strings.Join(names, " "); got != "client.list_todo mongo.list_todo mongo.list_todo client.list_todo" {
+		t.Errorf("want the events in order, got %s", got)
+	}
+	if mongo := tx.Timeline[1]; mongo.Duration < 5*time.Millisecond || mongo.Offset > mongo.Duration {
+		t.Errorf("want the mongo call to take the time slept, got %+v", mongo)
+	}
+	span := tx.EndTime.Sub(tx.StartTime)
+	if last := tx.Timeline[3]; last.Offset+last.Duration != span || total > span {
+		t.Errorf("want the timeline to end with the transaction, got %+v of %v", tx.Timeline, span)
+	}
+
+	var out bytes.Buffer
+	Print(&out, tx)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[0], "GET /todo  session=s-1") ||
+		strings.Join(strings.Fields(lines[2])[2:], " ") != `mongo.list_todo output {"filter":"completed"}` {
+		t.Errorf("want a header and an event per line, got\n%s", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+package logquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxData is how much of the data of an event Print shows.
+const maxData = 160
+
+// Print writes t as a timeline: a header line, then an event per line with
+// its offset from the start, its duration, name, direction and data, and
+// the spans last.
+func Print(w io.Writer, t Transaction) error {
+	header := []string{t.Event}
+	if t.Method != "" || t.Route != "" {
+		header = append(header, strings.TrimSpace(t.Method+" "+t.Route))
+	}
+	if t.Session != "" {
+		header = append(header, "session="+t.Session)
+	}
+	header = append(header, t.StartTime.Format(time.RFC3339Nano), t.ProcessTime.String(), t.File)
+	if _, err := fmt.Fprintln(w, strings.Join(header, "  ")); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, e := range t.Timeline {
+		dir, data := "output", e.Output
+		if e.Input != nil {
+			dir, data = "input", e.Input
+		}
+		name := e.Name
+		if e.Span != "" {
+			name = e.Span + " " + name
+		}
+		fmt.Fprintf(tw, "  +%s\t%s\t%s\t%s\t%s\n", e.Offset, e.Duration, name, dir, compact(data))
+		if err := e.Error(); err != "" {
+			fmt.Fprintf(tw, "  \t\t\t\terror: %s\n", err)
+		}
+	}
+	for _, s := range t.Spans {
+		unfinished := ""
+		if s.Unfinished {
+			unfinished = "unfinished"
+		}
+		fmt.Fprintf(tw, "  +%s\t%s\tspan %s\t%s\t\n", max(s.StartTime.Sub(t.StartTime), 0), s.ProcessTime, s.Name, unfinished)
+	}
+	return tw.Flush()
+}
+
+func compact(v any) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if r := []rune(string(b)); len(r) > maxData {
+		return string(r[:maxData]) + "…"
+	}
+	return string(b)
+}
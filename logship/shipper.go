@@ -0,0 +1,304 @@
+// Package logship forwards the rotated archives of the log to a sink and
+// removes them once delivered.
+package logship
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+)
+
+const dayLayout = "2006-01-02"
+
+// ErrDelivery wraps the errors of the sink, the shipper stops its pass on
+// them since the archives after would fail the same way.
+var ErrDelivery = errors.New("delivery failed")
+
+var (
+	// dayFile is the file of a day written by logsink.DailyFile.
+	dayFile = regexp.MustCompile(`_(\d{4}-\d{2}-\d{2})\.log$`)
+	// backup is a file rotated by size, lumberjack adds the time it was
+	// rotated at.
+	backup = regexp.MustCompile(`-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}\.log$`)
+)
+
+// Shipper forwards the lines of the archives in a directory to a sink in
+// batches. The number of lines delivered of each archive is recorded in a
+// checkpoint file after every batch, so a shipper stopped halfway resumes
+// where it was, and an archive is only removed once all of it is
+// delivered. Lines that aren't JSON are skipped.
+//
+// Archives are the compressed files, the backups rotated by size and the
+// files of the days before today. The file of today is still written and
+// is left alone, and so are the backups while the log compresses them.
+type Shipper struct {
+	dir  string
+	sink Sink
+
+	// Checkpoint is the file recording the progress, .ship-checkpoint.json
+	// in the directory by default.
+	Checkpoint string
+	BatchSize  int
+	Interval   time.Duration
+	// Compressed tells the log compresses its backups: one not compressed
+	// yet is about to be replaced by its .gz and is left for the pass
+	// after.
+	Compressed bool
+
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+func NewShipper(dir string, sink Sink, log *slog.Logger) *Shipper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Shipper{
+		dir:        dir,
+		sink:       sink,
+		Checkpoint: filepath.Join(dir, ".ship-checkpoint.json"),
+		BatchSize:  500,
+		Interval:   5 * time.Minute,
+		log:        log,
+		ctx:        ctx,
+		cancel:     cancel,
+		now:        time.Now,
+	}
+}
+
+// Result counts what a pass did.
+type Result struct {
+	// Archives were delivered in full and removed.
+	Archives int `json:"archives"`
+	Lines    int `json:"lines"`
+	// Skipped lines weren't JSON.
+	Skipped int `json:"skipped"`
+}
+
+func (s *Shipper) Serve() error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if res, err := s.Ship(s.ctx); err != nil {
+			s.log.Error("log shipping failed", slog.Any("result", res), slog.Any("error", err))
+		} else if res.Archives > 0 {
+			s.log.Info("log archives shipped", slog.Any("result", res))
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Shipper) Shutdown(context.Context) error {
+	s.cancel()
+	return nil
+}
+
+func (s *Shipper) detailLog(name string) logger.ILogDetail {
+	return logger.New(s.log, name, map[string]any{"route": "logship", "method": "worker"})
+}
+
+// Archives lists the archives waiting in the directory, oldest first.
+func (s *Shipper) Archives() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	today := s.now().Format(dayLayout)
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && archive(e.Name(), today, s.Compressed) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func archive(name, today string, compressed bool) bool {
+	if strings.HasSuffix(name, ".log.gz") {
+		return true
+	}
+	if backup.MatchString(name) {
+		return !compressed
+	}
+	m := dayFile.FindStringSubmatch(name)
+	return m != nil && m[1] < today
+}
+
+// Ship makes one pass over the archives. An archive that can't be read is
+// left for the next pass and the others still go, a failing sink ends the
+// pass.
+func (s *Shipper) Ship(ctx context.Context) (Result, error) {
+	var res Result
+	log := s.detailLog("log_ship")
+	defer log.End()
+
+	names, err := s.Archives()
+	if err != nil {
+		return res, err
+	}
+	offsets, err := s.loadCheckpoint()
+	if err != nil {
+		return res, err
+	}
+	// archives removed meanwhile, e.g. by the retention of the log
+	for name := range offsets {
+		if !slices.Contains(names, name) {
+			delete(offsets, name)
+		}
+	}
+
+	var errs []error
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		lines, skipped, err := s.shipArchive(ctx, log, name, offsets)
+		res.Lines += lines
+		res.Skipped += skipped
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			if errors.Is(err, ErrDelivery) {
+				break
+			}
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(offsets, name)
+		if err := s.saveCheckpoint(offsets); err != nil {
+			errs = append(errs, err)
+		}
+		res.Archives++
+	}
+	return res, errors.Join(errs...)
+}
+
+// shipArchive delivers the lines of an archive after the ones recorded in
+// offsets.
+func (s *Shipper) shipArchive(ctx context.Context, log logger.ILogDetail, name string, offsets map[string]int) (shipped, skipped int, err error) {
+	node, cmd := "log_sink", "ship"
+
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	done := offsets[name]
+	line := 0
+	var batch []json.RawMessage
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		data := map[string]any{"archive": name, "from": line - len(batch), "lines": len(batch)}
+		log.AddOutput(node, cmd, data).End()
+		if err := s.sink.Ship(ctx, batch); err != nil {
+			log.AddError(node, cmd, "input", data, err)
+			return fmt.Errorf("%w: %w", ErrDelivery, err)
+		}
+		log.AddInput(node, cmd, data)
+		shipped += len(batch)
+		batch = nil
+		offsets[name] = line
+		return s.saveCheckpoint(offsets)
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		b, readErr := br.ReadBytes('\n')
+		if len(b) > 0 {
+			line++
+			b = bytes.TrimSpace(b)
+			switch {
+			case line <= done || len(b) == 0:
+			case !json.Valid(b):
+				skipped++
+			default:
+				batch = append(batch, json.RawMessage(b))
+			}
+			if len(batch) >= s.BatchSize {
+				if err := flush(); err != nil {
+					return shipped, skipped, err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			// a batch read before a broken end still goes
+			if err := flush(); err != nil {
+				return shipped, skipped, err
+			}
+			return shipped, skipped, readErr
+		}
+	}
+	return shipped, skipped, flush()
+}
+
+// loadCheckpoint reads the lines delivered of each archive.
+func (s *Shipper) loadCheckpoint() (map[string]int, error) {
+	offsets := map[string]int{}
+	b, err := os.ReadFile(s.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &offsets); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", s.Checkpoint, err)
+	}
+	return offsets, nil
+}
+
+// saveCheckpoint replaces the checkpoint in one step, so a crash leaves
+// either the old or the new one.
+func (s *Shipper) saveCheckpoint(offsets map[string]int) error {
+	b, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	tmp := s.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Checkpoint)
+}
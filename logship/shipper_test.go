@@ -0,0 +1,205 @@
+package logship
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordSink keeps the lines shipped, failing the batches after the first
+// ok ones when failAfter is set.
+type recordSink struct {
+	lines     []string
+	batches   int
+	failAfter int
+}
+
+func (s *recordSink) Ship(_ context.Context, lines []json.RawMessage) error {
+	if s.failAfter > 0 && s.batches >= s.failAfter {
+		return errors.New("collector unavailable")
+	}
+	s.batches++
+	for _, l := range lines {
+		s.lines = append(s.lines, string(l))
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeGz(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	io.WriteString(gz, content)
+	gz.Close()
+	f.Close()
+}
+
+func newTestShipper(dir string, sink Sink) *Shipper {
+	s := NewShipper(dir, sink, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local) }
+	return s
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestShip(t *testing.T) {
+	dir := t.TempDir()
+	writeGz(t, filepath.Join(dir, "todoapi_2026-10-18-2026-10-18T10-00-00.000.log.gz"), "{\"n\":1}\nnot json\n\n{\"n\":2}\n")
+	writeFile(t, filepath.Join(dir, "todoapi_2026-10-18.log"), "{\"n\":3}\n{\"n\":4}")
+	writeFile(t, filepath.Join(dir, "todoapi_2026-10-19.log"), "{\"n\":5}\n")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "{\"n\":6}\n")
+
+	sink := &recordSink{}
+	res, err := newTestShipper(dir, sink).Ship(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != (Result{Archives: 2, Lines: 4, Skipped: 1}) {
+		t.Errorf("want 2 archives of 4 lines and 1 skipped, got %+v", res)
+	}
+	if got := strings.Join(sink.lines, ","); got != `{"n":1},{"n":2},{"n":3},{"n":4}` {
+		t.Errorf("want the lines of the archives in the order written, got %s", got)
+	}
+	for name, want := range map[string]bool{
+		"todoapi_2026-10-18-2026-10-18T10-00-00.000.log.gz": false,
+		"todoapi_2026-10-18.log":                            false,
+		"todoapi_2026-10-19.log":                            true,
+		"notes.txt":                                         true,
+	} {
+		if got := exists(filepath.Join(dir, name)); got != want {
+			t.Errorf("%s: want kept %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestShipResumes(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "todoapi_2026-10-17.log")
+	writeFile(t, archive, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n{\"n\":4}\n{\"n\":5}\n")
+
+	failing := &recordSink{failAfter: 2}
+	s := newTestShipper(dir, failing)
+	s.BatchSize = 2
+	res, err := s.Ship(context.Background())
+	if !errors.Is(err, ErrDelivery) {
+		t.Fatalf("want a delivery error, got %v", err)
+	}
+	if res.Lines != 4 || res.Archives != 0 || !exists(archive) {
+		t.Errorf("want 4 lines shipped and the archive kept, got %+v", res)
+	}
+
+	sink := &recordSink{}
+	s = newTestShipper(dir, sink)
+	s.BatchSize = 2
+	if _, err := s.Ship(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(sink.lines, ","); got != `{"n":5}` {
+		t.Errorf("want only the line not delivered before, got %s", got)
+	}
+	if exists(archive) {
+		t.Error("want the archive removed once delivered")
+	}
+	if b, _ := os.ReadFile(s.Checkpoint); string(b) != "{}" {
+		t.Errorf("want the archive dropped from the checkpoint, got %s", b)
+	}
+}
+
+func TestShipWaitsForCompression(t *testing.T) {
+	dir := t.TempDir()
+	backup := filepath.Join(dir, "todoapi_2026-10-19-2026-10-19T10-00-00.000.log")
+	writeFile(t, backup, "{\"n\":1}\n{\"n\":2}\n")
+
+	sink := &recordSink{}
+	s := newTestShipper(dir, sink)
+	s.Compressed = true
+	if res, err := s.Ship(context.Background()); err != nil || res.Archives != 0 || !exists(backup) {
+		t.Fatalf("want the backup left to the compression, got %+v %v", res, err)
+	}
+
+	// lumberjack replaces it with its .gz
+	os.Remove(backup)
+	writeGz(t, backup+".gz", "{\"n\":1}\n{\"n\":2}\n")
+	if res, err := s.Ship(context.Background()); err != nil || res.Archives != 1 {
+		t.Fatalf("want the compressed backup shipped, got %+v %v", res, err)
+	}
+	if got := strings.Join(sink.lines, ","); got != `{"n":1},{"n":2}` {
+		t.Errorf("want the lines shipped once, got %s", got)
+	}
+}
+
+func TestShipCorruptArchive(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "a-2026-10-18T10-00-00.000.log.gz")
+	writeFile(t, corrupt, "not gzip")
+	writeFile(t, filepath.Join(dir, "b_2026-10-18.log"), "{\"n\":1}\n")
+
+	sink := &recordSink{}
+	res, err := newTestShipper(dir, sink).Ship(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "a-2026-10-18") {
+		t.Errorf("want the corrupt archive reported, got %v", err)
+	}
+	if res.Archives != 1 || len(sink.lines) != 1 || !exists(corrupt) {
+		t.Errorf("want the other archive shipped and the corrupt one kept, got %+v", res)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	lines := []json.RawMessage{json.RawMessage(`{"n":1}`), json.RawMessage(`{"n":2}`)}
+
+	out := filepath.Join(t.TempDir(), "shipped", "all.log")
+	f := NewFileSink(out)
+	f.Ship(context.Background(), lines)
+	if err := f.Ship(context.Background(), lines[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "{\"n\":1}\n{\"n\":2}\n{\"n\":2}\n" {
+		t.Errorf("want the lines appended, got %q", b)
+	}
+
+	status := http.StatusOK
+	var body, contentType, token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, contentType, token = string(b), r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	h := NewHTTPSink(srv.URL)
+	h.Headers["Authorization"] = "Bearer secret"
+	if err := h.Ship(context.Background(), lines); err != nil {
+		t.Fatal(err)
+	}
+	if body != "{\"n\":1}\n{\"n\":2}\n" || contentType != "application/x-ndjson" || token != "Bearer secret" {
+		t.Errorf("want ndjson with the headers, got %q %q %q", body, contentType, token)
+	}
+	status = http.StatusServiceUnavailable
+	if err := h.Ship(context.Background(), lines); err == nil {
+		t.Error("want an error when the endpoint refuses the lines")
+	}
+}
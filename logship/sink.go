@@ -0,0 +1,98 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Sink is where the shipper forwards the lines of the archives. A batch is
+// taken as delivered once Ship returns nil, so a sink must not return
+// before the lines are stored. A batch may reach a sink twice when the
+// shipper stops between delivering it and recording that it did.
+type Sink interface {
+	Ship(ctx context.Context, lines []json.RawMessage) error
+}
+
+// FileSink appends the lines to a file, one per line.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{Path: path}
+}
+
+func (s *FileSink) Ship(_ context.Context, lines []json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(ndjson(lines)); err != nil {
+		f.Close()
+		return err
+	}
+	// the archive is removed on the strength of this, make sure it's on disk
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HTTPSink posts the lines as newline delimited JSON to URL.
+type HTTPSink struct {
+	URL     string
+	Headers map[string]string
+	client  *http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{URL: url, Headers: map[string]string{}, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *HTTPSink) Ship(ctx context.Context, lines []json.RawMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(ndjson(lines)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func ndjson(lines []json.RawMessage) []byte {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,230 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultQueueSize     = 10000
+	defaultFlushInterval = 2 * time.Second
+	exportAttempts       = 3
+)
+
+// Stats counts the records of an exporter.
+type Stats struct {
+	// Queued are waiting to be sent.
+	Queued int   `json:"queued"`
+	Sent   int64 `json:"sent"`
+	// Dropped found the queue full.
+	Dropped int64 `json:"dropped"`
+	// Failed were in batches the collector didn't take.
+	Failed int64 `json:"failed"`
+}
+
+// Exporter posts the records written to it to a collector in batches, from
+// a goroutine of its own so that logging never waits on the network. The
+// queue is bounded: once full, writes wait up to the block timeout and
+// then drop the record, counting it.
+type Exporter struct {
+	url      string
+	headers  map[string]string
+	encode   func(lines [][]byte) (body []byte, contentType string, err error)
+	client   *http.Client
+	batch    int
+	interval time.Duration
+	block    time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan []byte
+	done   chan struct{}
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+func newExporter(c Config) (*Exporter, error) {
+	interval, err := duration(c.FlushInterval, defaultFlushInterval)
+	if err != nil {
+		return nil, fmt.Errorf("flush_interval: %w", err)
+	}
+	block, err := duration(c.BlockTimeout, 0)
+	if err != nil {
+		return nil, fmt.Errorf("block_timeout: %w", err)
+	}
+	e := &Exporter{
+		url:      c.URL,
+		headers:  c.Headers,
+		encode:   ndjson,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		batch:    c.BatchSize,
+		interval: interval,
+		block:    block,
+	}
+	if c.Type == "otlp" {
+		service := c.Service
+		if service == "" {
+			service = "todoapi"
+		}
+		e.encode = func(lines [][]byte) ([]byte, string, error) {
+			return otlpLogs(service, lines)
+		}
+	}
+	if e.batch <= 0 {
+		e.batch = defaultBatchSize
+	}
+	size := c.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	e.queue = make(chan []byte, size)
+	e.done = make(chan struct{})
+	go e.run()
+	return e, nil
+}
+
+// Write queues one record, as written by a slog handler.
+func (e *Exporter) Write(p []byte) (int, error) {
+	line := bytes.Clone(bytes.TrimSpace(p))
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		e.dropped.Add(1)
+		return len(p), nil
+	}
+
+	select {
+	case e.queue <- line:
+		return len(p), nil
+	default:
+	}
+	if e.block > 0 {
+		t := time.NewTimer(e.block)
+		defer t.Stop()
+		select {
+		case e.queue <- line:
+			return len(p), nil
+		case <-t.C:
+		}
+	}
+	e.dropped.Add(1)
+	return len(p), nil
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	for {
+		select {
+		case line, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= e.batch {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = nil
+		}
+	}
+}
+
+// export posts batch, retrying failures the collector may recover from.
+func (e *Exporter) export(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	body, contentType, err := e.encode(batch)
+	if err != nil {
+		e.failed.Add(int64(len(batch)))
+		return
+	}
+
+	for attempt := 0; attempt < exportAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+		}
+		retry, err := e.post(body, contentType)
+		if err == nil {
+			e.sent.Add(int64(len(batch)))
+			return
+		}
+		if !retry {
+			break
+		}
+	}
+	e.failed.Add(int64(len(batch)))
+}
+
+func (e *Exporter) post(body []byte, contentType string) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return retry, fmt.Errorf("log collector answered %s", res.Status)
+	}
+	return false, nil
+}
+
+// Stats reports the records of the exporter so far.
+func (e *Exporter) Stats() Stats {
+	return Stats{
+		Queued:  len(e.queue),
+		Sent:    e.sent.Load(),
+		Dropped: e.dropped.Load(),
+		Failed:  e.failed.Load(),
+	}
+}
+
+// Close sends what is queued and stops the exporter, later writes are
+// dropped.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+	<-e.done
+	return nil
+}
+
+// ndjson sends the records as they were written, one per line.
+func ndjson(lines [][]byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
@@ -0,0 +1,108 @@
+package logsink
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const dayLayout = "2006-01-02"
+
+// DailyFile writes to a file of the current day, starting the file of the
+// next day at midnight. Within a day lumberjack rotates the file by size.
+type DailyFile struct {
+	mu         sync.Mutex
+	dir        string
+	base       string
+	ext        string
+	maxSize    int
+	maxBackups int
+	maxAge     int
+	compress   bool
+	day        string
+	w          *lumberjack.Logger
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// NewDailyFile writes to path with the date added before the extension.
+// Files of days older than maxAge are removed, 0 keeps them.
+func NewDailyFile(path string, maxSize, maxBackups, maxAge int, compress bool) *DailyFile {
+	ext := filepath.Ext(path)
+	return &DailyFile{
+		dir:        filepath.Dir(path),
+		base:       strings.TrimSuffix(filepath.Base(path), ext),
+		ext:        ext,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+		compress:   compress,
+		now:        time.Now,
+	}
+}
+
+// Filename is the file of day.
+func (f *DailyFile) Filename(day time.Time) string {
+	return filepath.Join(f.dir, f.base+"_"+day.Format(dayLayout)+f.ext)
+}
+
+func (f *DailyFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	if day := now.Format(dayLayout); day != f.day {
+		if f.w != nil {
+			f.w.Close()
+		}
+		f.day = day
+		f.w = &lumberjack.Logger{
+			LocalTime:  true,
+			Compress:   f.compress,
+			Filename:   f.Filename(now),
+			MaxSize:    f.maxSize,
+			MaxBackups: f.maxBackups,
+			MaxAge:     f.maxAge,
+		}
+		f.prune(now)
+	}
+	return f.w.Write(p)
+}
+
+// prune removes the files, and their backups, of the days older than
+// maxAge.
+func (f *DailyFile) prune(now time.Time) {
+	if f.maxAge <= 0 {
+		return
+	}
+	oldest := now.AddDate(0, 0, -f.maxAge).Format(dayLayout)
+	matches, _ := filepath.Glob(filepath.Join(f.dir, f.base+"_*"))
+	for _, m := range matches {
+		name := strings.TrimPrefix(filepath.Base(m), f.base+"_")
+		if len(name) < len(dayLayout) {
+			continue
+		}
+		day := name[:len(dayLayout)]
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			continue
+		}
+		if day < oldest {
+			os.Remove(m)
+		}
+	}
+}
+
+func (f *DailyFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.w == nil {
+		return nil
+	}
+	err := f.w.Close()
+	f.w, f.day = nil, ""
+	return err
+}
@@ -0,0 +1,266 @@
+// Package logsink writes the log to several outputs at once: stdout, daily
+// rotated files and HTTP or OTLP collectors, each in its own format.
+package logsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+// Config describes one output of the log.
+type Config struct {
+	// Name tells the sink apart in Stats, its type by default.
+	Name string `json:"name,omitempty"`
+	// Type is stdout, file, http or otlp.
+	Type string `json:"type"`
+	// Format is json or text for stdout, file and http, otlp is always
+	// sent as OTLP/JSON.
+	Format string `json:"format,omitempty"`
+	// LogNames only keeps the records with one of these log_name values,
+	// http and otlp keep DETAIL and SUMMARY by default.
+	LogNames []string `json:"log_names,omitempty"`
+
+	// Path is the file written, the date is added before the extension:
+	// logs/todoapi.log is written to logs/todoapi_2006-01-02.log.
+	Path       string `json:"path,omitempty"`
+	MaxSizeMB  int    `json:"max_size_mb,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+	// MaxAgeDays removes the files of older days, 0 keeps them.
+	MaxAgeDays int  `json:"max_age_days,omitempty"`
+	Compress   bool `json:"compress,omitempty"`
+
+	// URL is where http and otlp sinks post batches of records.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Service is the service.name of otlp records.
+	Service       string `json:"service,omitempty"`
+	BatchSize     int    `json:"batch_size,omitempty"`
+	QueueSize     int    `json:"queue_size,omitempty"`
+	FlushInterval string `json:"flush_interval,omitempty"`
+	// BlockTimeout is how long a write waits for room in a full queue
+	// before the record is dropped, 0 drops it right away.
+	BlockTimeout string `json:"block_timeout,omitempty"`
+}
+
+// DefaultConfig writes JSON to stdout and to a daily file in logs/details.
+func DefaultConfig(service string) []Config {
+	return []Config{
+		{Type: "stdout", Format: "json"},
+		{
+			Type:       "file",
+			Format:     "json",
+			Path:       "logs/details/" + service + ".log",
+			MaxSizeMB:  10,
+			MaxBackups: 5,
+			MaxAgeDays: 1,
+			Compress:   true,
+		},
+	}
+}
+
+// Parse reads the JSON list of sinks of LOG_SINKS.
+func Parse(spec string) ([]Config, error) {
+	var configs []Config
+	if err := json.Unmarshal([]byte(spec), &configs); err != nil {
+		return nil, fmt.Errorf("log sinks: %w", err)
+	}
+	return configs, nil
+}
+
+// Sinks is the handler writing to every configured output.
+type Sinks struct {
+	slog.Handler
+	exporters map[string]*Exporter
+	closers   []io.Closer
+	files     []Config
+}
+
+// New opens the outputs of configs. The handler lets every level through,
+// filtering is left to the handler wrapping it.
+func New(configs []Config) (*Sinks, error) {
+	s := &Sinks{exporters: map[string]*Exporter{}}
+	var handlers []slog.Handler
+	for i, c := range configs {
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%s-%d", c.Type, i)
+		}
+		if (c.Type == "http" || c.Type == "otlp") && len(c.LogNames) == 0 {
+			c.LogNames = []string{"DETAIL", "SUMMARY"}
+		}
+		h, err := s.open(c)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("log sink %s: %w", c.Name, err)
+		}
+		if len(c.LogNames) > 0 {
+			h = &logNameHandler{Handler: h, names: c.LogNames}
+		}
+		handlers = append(handlers, h)
+	}
+	s.Handler = fanout(handlers)
+	return s, nil
+}
+
+func (s *Sinks) open(c Config) (slog.Handler, error) {
+	switch c.Type {
+	case "stdout":
+		return format(c.Format, os.Stdout)
+	case "file":
+		if c.Path == "" {
+			return nil, errors.New("path is required")
+		}
+		f := NewDailyFile(c.Path, c.MaxSizeMB, c.MaxBackups, c.MaxAgeDays, c.Compress)
+		s.closers = append(s.closers, f)
+		s.files = append(s.files, c)
+		return format(c.Format, f)
+	case "http", "otlp":
+		if c.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		e, err := newExporter(c)
+		if err != nil {
+			return nil, err
+		}
+		s.exporters[c.Name] = e
+		s.closers = append(s.closers, e)
+		if c.Type == "otlp" {
+			// the records are read back to build OTLP, keep the slog keys
+			return slog.NewJSONHandler(e, &slog.HandlerOptions{Level: slog.LevelDebug}), nil
+		}
+		return format(c.Format, e)
+	default:
+		return nil, fmt.Errorf("unknown type %q", c.Type)
+	}
+}
+
+// Stats reports how the exporters of the sinks are doing, by name.
+func (s *Sinks) Stats() map[string]Stats {
+	out := make(map[string]Stats, len(s.exporters))
+	for name, e := range s.exporters {
+		out[name] = e.Stats()
+	}
+	return out
+}
+
+// Files returns the configs of the file sinks.
+func (s *Sinks) Files() []Config {
+	return s.files
+}
+
+// Close flushes the exporters and closes the files.
+func (s *Sinks) Close() error {
+	var errs []error
+	for _, c := range s.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// format builds the handler of a format writing to w.
+func format(name string, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceAttr}
+	switch name {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+}
+
+// replaceAttr names the time and message the way the log has always been
+// read.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		return slog.Attr{Key: "@timestamp", Value: a.Value}
+	case slog.MessageKey:
+		return slog.Attr{Key: "event", Value: a.Value}
+	}
+	return a
+}
+
+// fanout hands every record to all of handlers.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// logNameHandler only keeps the records with one of names as log_name.
+type logNameHandler struct {
+	slog.Handler
+	names []string
+}
+
+func (h *logNameHandler) Handle(ctx context.Context, r slog.Record) error {
+	keep := false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "log_name" {
+			keep = slices.Contains(h.names, a.Value.String())
+			return false
+		}
+		return true
+	})
+	if !keep {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *logNameHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logNameHandler{Handler: h.Handler.WithAttrs(attrs), names: h.names}
+}
+
+func (h *logNameHandler) WithGroup(name string) slog.Handler {
+	return &logNameHandler{Handler: h.Handler.WithGroup(name), names: h.names}
+}
+
+func duration(s string, fallback time.Duration) (time.Duration, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(s)
+}
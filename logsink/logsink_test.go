@@ -0,0 +1,217 @@
+package logsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a stand-in log collector keeping the bodies posted to it.
+type collector struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	// hold, when set, keeps requests waiting until it's closed.
+	hold chan struct{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.hold != nil {
+		<-c.hold
+	}
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, body)
+	c.mu.Unlock()
+}
+
+func (c *collector) lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for _, b := range c.bodies {
+		s := bufio.NewScanner(strings.NewReader(string(b)))
+		for s.Scan() {
+			out = append(out, s.Text())
+		}
+	}
+	return out
+}
+
+func TestDailyFile(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2026, 10, 19, 23, 59, 0, 0, time.Local)
+	os.WriteFile(filepath.Join(dir, "todoapi_2026-10-16.log"), []byte("old\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "todoapi_2026-10-18.log"), []byte("yesterday\n"), 0o644)
+
+	f := NewDailyFile(filepath.Join(dir, "todoapi.log"), 10, 5, 1, false)
+	f.now = func() time.Time { return day }
+	f.Write([]byte("monday\n"))
+	// the day before is within the maximum age of one day
+	if _, err := os.Stat(filepath.Join(dir, "todoapi_2026-10-18.log")); err != nil {
+		t.Errorf("want the file of the day before kept, got %v", err)
+	}
+	day = day.Add(2 * time.Minute)
+	f.Write([]byte("tuesday\n"))
+	f.Close()
+
+	for name, want := range map[string]string{
+		"todoapi_2026-10-19.log": "monday\n",
+		"todoapi_2026-10-20.log": "tuesday\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: want %q, got %q %v", name, want, got, err)
+		}
+	}
+	for _, name := range []string{"todoapi_2026-10-16.log", "todoapi_2026-10-18.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s: want the file past the maximum age removed, got %v", name, err)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New([]Config{
+		{Type: "file", Format: "json", Path: filepath.Join(dir, "json.log")},
+		{Type: "file", Format: "text", Path: filepath.Join(dir, "text.log"), LogNames: []string{"SUMMARY"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(s).With("session", "s-1")
+	l.Info("POST /todo", slog.String("log_name", "SUMMARY"))
+	l.Info("gorm.create", slog.String("log_name", "DETAIL"))
+	s.Close()
+
+	today := time.Now().Format(dayLayout)
+	b, _ := os.ReadFile(filepath.Join(dir, "json_"+today+".log"))
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var first map[string]any
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first["event"] != "POST /todo" || first["@timestamp"] == nil || first["session"] != "s-1" {
+		t.Errorf("want both records as json, got %s", b)
+	}
+
+	b, _ = os.ReadFile(filepath.Join(dir, "text_"+today+".log"))
+	if text := string(b); strings.Count(text, "\n") != 1 || !strings.Contains(text, `event="POST /todo"`) {
+		t.Errorf("want only the summary as text, got %s", text)
+	}
+
+	for _, c := range []Config{{Type: "kafka"}, {Type: "stdout", Format: "xml"}, {Type: "file"}, {Type: "http"}} {
+		if _, err := New([]Config{c}); err == nil {
+			t.Errorf("%+v: want an error", c)
+		}
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := New([]Config{{Name: "collector", Type: "http", URL: srv.URL, BatchSize: 2, FlushInterval: "1h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(s)
+	l.Info("one", slog.String("log_name", "DETAIL"))
+	l.Info("two", slog.String("log_name", "SUMMARY"))
+	l.Info("not shipped")
+	l.Info("three", slog.String("log_name", "DETAIL"))
+	s.Close()
+
+	lines := c.lines()
+	if len(lines) != 3 || !strings.Contains(lines[2], `"event":"three"`) {
+		t.Errorf("want the detail and summary records, got %v", lines)
+	}
+	if len(c.bodies) != 2 {
+		t.Errorf("want two batches, got %d", len(c.bodies))
+	}
+	if got := s.Stats()["collector"]; got != (Stats{Sent: 3}) {
+		t.Errorf("want 3 sent, got %+v", got)
+	}
+}
+
+func TestExporterDrops(t *testing.T) {
+	c := &collector{hold: make(chan struct{})}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := New([]Config{{Name: "slow", Type: "http", URL: srv.URL, BatchSize: 1, QueueSize: 2, BlockTimeout: "10ms"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(s)
+	const total = 10
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		l.Info("detail", slog.String("log_name", "DETAIL"))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("want a stalled collector to hold writes back at most briefly, took %v", elapsed)
+	}
+
+	stats := s.Stats()["slow"]
+	if stats.Dropped == 0 || stats.Dropped > total-2 {
+		t.Errorf("want the records beyond the queue dropped, got %+v", stats)
+	}
+	close(c.hold)
+	s.Close()
+
+	stats = s.Stats()["slow"]
+	if stats.Sent+stats.Dropped != total || stats.Queued != 0 || len(c.lines()) != int(stats.Sent) {
+		t.Errorf("want every record sent or dropped, got %+v and %d received", stats, len(c.lines()))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := New([]Config{{Type: "otlp", URL: srv.URL, Service: "todoapi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(s).Warn("GET /todo",
+		slog.String("log_name", "SUMMARY"),
+		slog.Int("result_code", 200),
+		slog.Any("nodes", []map[string]any{{"node": "mongo", "count": 2}}))
+	s.Close()
+
+	if len(c.bodies) != 1 {
+		t.Fatalf("want one export, got %d", len(c.bodies))
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(c.bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	rl := req.ResourceLogs[0]
+	if *rl.Resource.Attributes[0].Value.StringValue != "todoapi" {
+		t.Errorf("want the service name, got %+v", rl.Resource)
+	}
+	r := rl.ScopeLogs[0].LogRecords[0]
+	if r.SeverityNumber != 13 || *r.Body.StringValue != "GET /todo" || r.TimeUnixNano == "" {
+		t.Errorf("want a warning about GET /todo, got %+v", r)
+	}
+	attrs := map[string]otlpAnyValue{}
+	for _, kv := range r.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if v := attrs["result_code"].IntValue; v == nil || *v != "200" {
+		t.Errorf("want result_code as an int, got %+v", attrs["result_code"])
+	}
+	nodes := attrs["nodes"].ArrayValue
+	if nodes == nil || *nodes.Values[0].KvlistValue.Values[0].Value.IntValue != "2" {
+		t.Errorf("want the nodes as a list of key values, got %+v", attrs["nodes"])
+	}
+}
@@ -0,0 +1,160 @@
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The OTLP/JSON encoding of logs, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           otlpAnyValue   `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string        `json:"stringValue,omitempty"`
+		BoolValue   *bool          `json:"boolValue,omitempty"`
+		IntValue    *string        `json:"intValue,omitempty"`
+		DoubleValue *float64       `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArray     `json:"arrayValue,omitempty"`
+		KvlistValue *otlpKeyValues `json:"kvlistValue,omitempty"`
+	}
+	otlpArray struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+	otlpKeyValues struct {
+		Values []otlpKeyValue `json:"values"`
+	}
+)
+
+// otlpLogs builds an OTLP export request of records written by a JSON
+// slog handler.
+func otlpLogs(service string, lines [][]byte) ([]byte, string, error) {
+	records := make([]otlpLogRecord, 0, len(lines))
+	for _, line := range lines {
+		var fields map[string]any
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if err := d.Decode(&fields); err != nil {
+			continue
+		}
+		records = append(records, otlpRecord(fields))
+	}
+
+	req := otlpRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpValue(service)},
+		}},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: service},
+			LogRecords: records,
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	return body, "application/json", err
+}
+
+func otlpRecord(fields map[string]any) otlpLogRecord {
+	var r otlpLogRecord
+	if s, ok := fields[slog.TimeKey].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			r.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+		}
+	}
+	var level slog.Level
+	if s, ok := fields[slog.LevelKey].(string); ok {
+		level.UnmarshalText([]byte(s))
+	}
+	r.SeverityNumber, r.SeverityText = severity(level)
+	msg, _ := fields[slog.MessageKey].(string)
+	r.Body = otlpValue(msg)
+
+	delete(fields, slog.TimeKey)
+	delete(fields, slog.LevelKey)
+	delete(fields, slog.MessageKey)
+	r.Attributes = otlpKeyValueList(fields)
+	return r
+}
+
+// severity maps a slog level to the OTLP severity number and text.
+func severity(level slog.Level) (int, string) {
+	switch {
+	case level >= slog.LevelError:
+		return 17, "ERROR"
+	case level >= slog.LevelWarn:
+		return 13, "WARN"
+	case level >= slog.LevelInfo:
+		return 9, "INFO"
+	default:
+		return 5, "DEBUG"
+	}
+}
+
+func otlpKeyValueList(m map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpValue(m[k])})
+	}
+	return out
+}
+
+func otlpValue(v any) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpAnyValue{IntValue: &s}
+		}
+		f, _ := v.Float64()
+		return otlpAnyValue{DoubleValue: &f}
+	case []any:
+		values := make([]otlpAnyValue, len(v))
+		for i, e := range v {
+			values[i] = otlpValue(e)
+		}
+		return otlpAnyValue{ArrayValue: &otlpArray{Values: values}}
+	case map[string]any:
+		return otlpAnyValue{KvlistValue: &otlpKeyValues{Values: otlpKeyValueList(v)}}
+	default:
+		// null
+		return otlpAnyValue{}
+	}
+}
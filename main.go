@@ -1,58 +1,125 @@
 package main
 
 import (
-	"io"
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sing3demons/todoapi/blob"
+	"github.com/sing3demons/todoapi/buildinfo"
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/gql"
+	"github.com/sing3demons/todoapi/grpcserver"
+	"github.com/sing3demons/todoapi/health"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/logsink"
+	"github.com/sing3demons/todoapi/metrics"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/openapi"
+	"github.com/sing3demons/todoapi/outbox"
+	"github.com/sing3demons/todoapi/reminder"
 	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
 	"github.com/sing3demons/todoapi/todo"
-	"gopkg.in/natefinch/lumberjack.v2"
+	"github.com/sing3demons/todoapi/todopb"
+	"github.com/sing3demons/todoapi/tracing"
+	"github.com/sing3demons/todoapi/webhook"
 )
 
+// set with -ldflags -X, see the Dockerfile
 var (
 	buildcommit = "dev"
-	buildtime   = time.Now().String()
+	buildtime   = ""
 )
 
+// features lists the optional parts of the service the environment turns
+// on. Addresses may carry credentials, only their presence is reported.
+func features() map[string]string {
+	f := map[string]string{}
+	for name, env := range map[string]string{
+		"openapi_validation": "OPENAPI_VALIDATION",
+		"outbox_sink":        "OUTBOX_SINK",
+		"blob_store":         "BLOB_STORE",
+		"traces_exporter":    "OTEL_TRACES_EXPORTER",
+		"grpc_port":          "GRPC_PORT",
+		"log_level":          "LOG_LEVEL",
+	} {
+		if v := os.Getenv(env); v != "" {
+			f[name] = v
+		}
+	}
+	for name, env := range map[string]string{
+		"log_sinks":        "LOG_SINKS",
+		"log_shipping":     "LOG_SHIP_URL",
+		"log_redaction":    "LOG_REDACT_CONFIG",
+		"reminder_webhook": "REMINDER_WEBHOOK_URL",
+		"reminder_smtp":    "REMINDER_SMTP_ADDR",
+	} {
+		if os.Getenv(env) != "" {
+			f[name] = "on"
+		}
+	}
+	return f
+}
+
+// version describes the binary and the stores of conn.
+func version(ctx context.Context, conn *db) buildinfo.Info {
+	info := buildinfo.Read(buildcommit, buildtime)
+	info.Stores = conn.Stores(ctx)
+	info.Features = features()
+	return info
+}
+
+// newSinks opens the outputs of the log, LOG_SINKS lists them as JSON and
+// stdout and a daily file in logs/details are used without it.
+func newSinks() *logsink.Sinks {
+	configs := logsink.DefaultConfig("todoapi")
+	if spec := os.Getenv("LOG_SINKS"); spec != "" {
+		c, err := logsink.Parse(spec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			configs = c
+		}
+	}
+	s, err := logsink.New(configs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		s, _ = logsink.New(logsink.DefaultConfig("todoapi"))
+	}
+	return s
+}
+
+var sinks = newSinks()
+
 func NewLogger() *slog.Logger {
-	serviceName := "todoapi"
-	time := time.Now().Format("2006-01-02")
-
-	fileName := "logs/details/" + serviceName + "_" + time + ".log"
-	f := &lumberjack.Logger{
-		LocalTime:  true,
-		Compress:   true,
-		Filename:   fileName,
-		MaxSize:    10, // megabytes
-		MaxBackups: 5,
-		MaxAge:     1, // days
-	}
-	return slog.New(slog.NewJSONHandler(io.MultiWriter(os.Stdout, f), &slog.HandlerOptions{
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			if a.Key == "time" {
-				return slog.Attr{
-					Key:   "@timestamp",
-					Value: a.Value,
-				}
-			}
-
-			if a.Key == "msg" {
-				return slog.Attr{
-					Key:   "event",
-					Value: a.Value,
-				}
-			}
-
-			return a
-		},
-	}))
+	// the level of each component is checked by logger.NewHandler
+	return slog.New(logger.NewHandler(sinks, logger.App))
 }
 
 var log *slog.Logger = NewLogger()
 
+// mongoLog is where the mongo driver logs, at the level of its component.
+var mongoLog = logger.Component(log, logger.Mongo)
+
+func setRedactor(path string) error {
+	config, err := logger.LoadRedactConfig(path)
+	if err != nil {
+		return err
+	}
+	r, err := logger.NewRedactor(config)
+	if err != nil {
+		return err
+	}
+	logger.SetRedactor(r)
+	return nil
+}
+
 func init() {
 	slog.SetDefault(log)
 
@@ -64,21 +131,219 @@ func main() {
 		log.Error("Error loading .env file")
 	}
 
+	// LOG_LEVEL sets the level of every component or of single ones, e.g.
+	// "info,gorm=debug"
+	if err := logger.ParseLevels(os.Getenv("LOG_LEVEL")); err != nil {
+		log.Error("invalid LOG_LEVEL", slog.Any("error", err))
+	}
+
+	if len(os.Args) > 1 {
+		os.Exit(command(os.Args[1:]))
+	}
+
+	// OTEL_TRACES_EXPORTER is otlp, stdout or none, the OTLP collector is set
+	// with the standard OTEL_EXPORTER_OTLP_* variables
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("OTEL_TRACES_EXPORTER"), "todoapi", nil)
+	if err != nil {
+		log.Error("failed to set up tracing", slog.Any("error", err))
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+
 	slog.Debug("Starting server...")
 
-	r := router.NewFiberRouter(log)
+	// LOG_REDACT_CONFIG adds masking rules to the defaults of the detail log
+	if path := os.Getenv("LOG_REDACT_CONFIG"); path != "" {
+		if err := setRedactor(path); err != nil {
+			log.Error("failed to load log redaction config", slog.Any("error", err))
+		}
+	}
+
+	// the calls of every detail log feed the store operation histograms
+	logger.ObserveCalls(metrics.ObserveCall)
+
+	r := router.NewFiberRouter(logger.Component(log, logger.HTTP))
+	// the /admin routes and the debug header need ADMIN_TOKEN as a bearer
+	// token, without one they are refused
+	r.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+	// WS_ALLOWED_ORIGINS lists the other origins browsers may open websockets from
+	if origins := os.Getenv("WS_ALLOWED_ORIGINS"); origins != "" {
+		r.AllowOrigins(strings.Split(origins, ",")...)
+	}
+
+	// OPENAPI_VALIDATION=request checks incoming requests, debug also checks responses
+	if mode := os.Getenv("OPENAPI_VALIDATION"); mode != "" {
+		v, err := openapi.New(mode == "debug")
+		if err != nil {
+			log.Error("failed to load openapi spec", slog.Any("error", err))
+		} else {
+			r.UseOpenAPI(v)
+		}
+	}
+
+	// readiness checks the dependencies opened below and fails from the
+	// shutdown signal on, HEALTH_DRAIN_DELAY leaves the load balancer the
+	// time to notice before the router stops taking requests
+	ready := health.New()
+	r.OnShutdown(func() {
+		ready.Drain()
+		if d, err := time.ParseDuration(os.Getenv("HEALTH_DRAIN_DELAY")); err == nil {
+			time.Sleep(d)
+		}
+	})
 	r.GET("/healthz", Healthz)
+	r.GET("/livez", health.Livez)
+	r.GET("/readyz", ready.Readyz)
+	r.GET("/metrics", Metrics)
 	r.GET("/x", X)
 	r.GET("/ping", PingHandler)
 	r.GET("/transfer/:id", Transfer)
 
+	// flushes what the exporters still have queued
+	defer sinks.Close()
+
 	conn := db{}
 	defer conn.Close()
-	todoHandler := todo.NewTodoHandler(conn.MongoStore())
+	bus := events.NewBus()
+	mongoStore := conn.MongoStore()
+	var todoStore store.Storer = mongoStore
+
+	// every change is written to the outbox with the change itself, the
+	// relay feeds the bus from it
+	var toBus outbox.Sink = outbox.NewBusSink(bus)
+	// on a replica set the change stream feeds the bus instead, including
+	// writes made by other instances
+	if feed, err := events.WatchMongo(mongoStore.Collection, bus); err != nil {
+		log.Info("mongo change stream unavailable", slog.Any("error", err))
+	} else {
+		// mentions change no todo, so they still reach the bus from here
+		toBus = outbox.NewEventSink(toBus, outbox.Discard, model.TodoMentioned)
+		r.Register(feed)
+	}
+	// the dispatcher records the webhook deliveries of every change before
+	// it counts as published, OUTBOX_SINK publishes the changes outside the
+	// service as well
+	webhookStore := conn.MongoWebhookStore()
+	dispatcher := webhook.NewDispatcher(webhookStore, log)
+	r.Register(dispatcher)
+	publishTo := []outbox.Sink{dispatcher, toBus}
+	switch os.Getenv("OUTBOX_SINK") {
+	case "log":
+		publishTo = append(publishTo, outbox.NewLogSink(log))
+	case "http":
+		publishTo = append(publishTo, outbox.NewHTTPSink(os.Getenv("OUTBOX_URL")))
+	}
+	sink := outbox.NewFanoutSink(publishTo...)
+	relay := outbox.NewRelay(conn.MongoOutboxStore(), sink, log)
+	r.Register(relay)
+	r.GET("/admin/outbox", relay.MetricsHandler)
+	r.GET("/admin/log-levels", LogLevels)
+	r.PUT("/admin/log-levels", SetLogLevels)
+	r.GET("/admin/log-sinks", LogSinks)
+	if url := os.Getenv("LOG_SHIP_URL"); url != "" {
+		r.Register(newShipper(url))
+	}
+
+	todoHandler := todo.NewTodoHandler(todoStore)
+	todoHandler.Blobs = newBlobStore()
+	if size, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64); err == nil {
+		todoHandler.MaxAttachmentSize = size
+	}
+	// uploads are the largest bodies taken, with room for the multipart framing
+	r.SetBodyLimit(todoHandler.MaxAttachmentSize + 1<<20)
 	r.POST("/todo", todoHandler.NewTask)
+	// browsers open the feeds with a token from /todo/events/token, the
+	// replicas have to share EVENTS_TOKEN_SECRET to accept each other's
+	tokens := events.NewTokens(os.Getenv("EVENTS_TOKEN_SECRET"))
+	eventsHandler := events.NewHandler(bus, tokens, todo.NewAccess(todoStore))
+	r.POST("/todo/events/token", eventsHandler.Token)
+	r.GET("/todo/events", eventsHandler.Stream)
+	r.WS("/todo/events/ws", eventsHandler.WebSocket)
+	r.GET("/todo/:id/children", todoHandler.Children)
+	r.POST("/todo/:id/move", todoHandler.Move)
+	r.GET("/todo/:id/blockers", todoHandler.Blockers)
+	r.POST("/todo/:id/blockers", todoHandler.AddBlocker)
+	r.DELETE("/todo/:id/blockers/:blocker", todoHandler.RemoveBlocker)
+	r.POST("/todo/:id/reorder", todoHandler.Reorder)
+	r.POST("/todo/:id/attachments", todoHandler.Upload)
+	r.GET("/todo/:id/attachments/:attachment", todoHandler.Download)
+	r.DELETE("/todo/:id/attachments/:attachment", todoHandler.DeleteAttachment)
+	r.GET("/todo/:id/comments", todoHandler.Comments)
+	r.POST("/todo/:id/comments", todoHandler.NewComment)
+	r.GET("/todo/:id/comments/:comment", todoHandler.FindComment)
+	r.PATCH("/todo/:id/comments/:comment", todoHandler.UpdateComment)
+	r.DELETE("/todo/:id/comments/:comment", todoHandler.DeleteComment)
+	r.GET("/todo/:id/history", todo.NewHistoryHandler(todoStore, conn.MongoAuditStore()).History)
 	r.GET("/todo/:id", todoHandler.FindOne)
 	r.GET("/todo", todoHandler.List)
+	r.PATCH("/todo/:id", todoHandler.Update)
 	r.DELETE("/todo/:id", todoHandler.Delete)
 
+	listHandler := todo.NewListHandler(mongoStore, mongoStore)
+	r.POST("/lists", listHandler.Create)
+	r.GET("/lists", listHandler.List)
+	r.GET("/lists/:id/todos", listHandler.Todos)
+	r.GET("/lists/:id", listHandler.FindOne)
+	r.PATCH("/lists/:id", listHandler.Update)
+	r.DELETE("/lists/:id", listHandler.Delete)
+	r.GET("/lists/:id/members", listHandler.Members)
+	r.PUT("/lists/:id/members/:user", listHandler.SetMember)
+	r.DELETE("/lists/:id/members/:user", listHandler.RemoveMember)
+	r.POST("/lists/:id/invitations", listHandler.Invite)
+	r.POST("/invitations/:token/accept", listHandler.Accept)
+
+	r.POST("/graphql", gql.NewHandler(todoStore, bus).Serve)
+
+	webhookHandler := webhook.NewHandler(webhookStore, dispatcher)
+	r.POST("/admin/webhooks", webhookHandler.Register)
+	r.GET("/admin/webhooks", webhookHandler.List)
+	r.DELETE("/admin/webhooks/:id", webhookHandler.Delete)
+	r.POST("/admin/webhooks/:id/test", webhookHandler.Test)
+	r.GET("/admin/webhooks/:id/deliveries", webhookHandler.Deliveries)
+	r.GET("/admin/deliveries", webhookHandler.Deliveries)
+	r.POST("/admin/deliveries/:id/retry", webhookHandler.Retry)
+
+	notifiers := []reminder.Notifier{reminder.NewLogNotifier(log)}
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, reminder.NewWebhookNotifier(url, os.Getenv("REMINDER_WEBHOOK_SECRET")))
+	}
+	if addr := os.Getenv("REMINDER_SMTP_ADDR"); addr != "" {
+		to := strings.Split(os.Getenv("REMINDER_SMTP_TO"), ",")
+		notifiers = append(notifiers, reminder.NewSMTPNotifier(addr, os.Getenv("REMINDER_SMTP_FROM"), to))
+	}
+	r.Register(reminder.NewScheduler(conn.MongoReminderStore(), log, notifiers...))
+
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		s := grpcserver.New(port, log)
+		todopb.RegisterTodoServiceServer(s, grpcserver.NewTodoService(todoStore))
+		r.Register(s)
+	}
+
+	conn.Checks(ready)
+	r.GET("/version", Version(&conn))
+	// HEALTH_MIN_FREE_MB is the space each directory the file sinks write
+	// to needs, 100MB by default
+	minFree := uint64(100)
+	if mb, err := strconv.ParseUint(os.Getenv("HEALTH_MIN_FREE_MB"), 10, 64); err == nil {
+		minFree = mb
+	}
+	for _, dir := range logDirs() {
+		ready.Add("disk "+dir, health.DiskSpace(dir, minFree<<20))
+	}
+
 	r.Run()
 }
+
+// newBlobStore keeps attachments in the bucket S3_BUCKET with
+// BLOB_STORE=s3, otherwise below ATTACHMENT_DIR.
+func newBlobStore() blob.BlobStore {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return blob.NewS3Store(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_REGION"), os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"))
+	}
+	dir := os.Getenv("ATTACHMENT_DIR")
+	if dir == "" {
+		dir = "data/attachments"
+	}
+	return blob.NewFSStore(dir)
+}
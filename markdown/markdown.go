@@ -0,0 +1,85 @@
+// Package markdown renders user written Markdown to HTML that is safe to
+// embed in a page and finds the users it @mentions.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// policy allows the formatting Markdown produces and drops scripts,
+	// event handlers, styles and javascript: links.
+	policy = bluemonday.UGCPolicy()
+	// mention is an @ that doesn't follow a word character, so e-mail
+	// addresses aren't mentions.
+	mention = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*\w|\w)`)
+)
+
+// Render converts src to sanitised HTML.
+func Render(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// Mentions returns the users @mentioned in src, each once in the order
+// they first appear. Mentions inside code and links don't count.
+func Mentions(src string) []string {
+	source := []byte(src)
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var users []string
+	seen := map[string]bool{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindCodeSpan, ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindLink, ast.KindAutoLink, ast.KindHTMLBlock, ast.KindRawHTML:
+			return ast.WalkSkipChildren, nil
+		case ast.KindParagraph, ast.KindHeading, ast.KindTextBlock:
+			for _, m := range mention.FindAllStringSubmatch(inlineText(n, source), -1) {
+				if !seen[m[1]] {
+					seen[m[1]] = true
+					users = append(users, m[1])
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return users
+}
+
+// inlineText joins the plain text of a block, with a space standing in
+// for code, links and line breaks so they split words.
+func inlineText(block ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(block, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeSpan, *ast.Link, *ast.AutoLink, *ast.RawHTML:
+			buf.WriteByte(' ')
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			buf.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
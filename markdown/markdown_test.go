@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		src      string
+		contains string
+		absent   string
+	}{
+		{"**done** when _green_", "<strong>done</strong>", ""},
+		{"<script>alert(1)</script>", "", "<script"},
+		{`<img src=x onerror="alert(1)">`, "", "onerror"},
+		{"[click](javascript:alert(1))", "", "javascript:"},
+		{"- [x] ship it", "<li>", ""},
+	} {
+		html, err := Render(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.contains != "" && !strings.Contains(html, tt.contains) {
+			t.Errorf("%q: want %q in %q", tt.src, tt.contains, html)
+		}
+		if tt.absent != "" && strings.Contains(html, tt.absent) {
+			t.Errorf("%q: want no %q in %q", tt.src, tt.absent, html)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"@alice can you look? cc @bob.", []string{"alice", "bob"}},
+		{"@alice and @alice again", []string{"alice"}},
+		{"mail bob@example.com", nil},
+		{"run `@carol` or\n\n    @dave", nil},
+		{"see [@erin](https://example.com) and **@frank**", []string{"frank"}},
+		{"line one\n@grace", []string{"grace"}},
+	} {
+		if got := Mentions(tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: want %v, got %v", tt.src, tt.want, got)
+		}
+	}
+}
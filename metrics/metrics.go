@@ -0,0 +1,125 @@
+// Package metrics holds the Prometheus metrics of the service: requests by
+// route, calls to the stores and other nodes, the connection pools and the
+// Go runtime.
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/common/expfmt"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Registry holds every metric of the service.
+var Registry = prometheus.NewRegistry()
+
+// ContentType is the type of what Write writes.
+var ContentType = string(expfmt.NewFormat(expfmt.TypeTextPlain))
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requests answered, by method, route and status.",
+	}, []string{"method", "route", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to answer requests, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	calls = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "store_operation_duration_seconds",
+		Help:    "Time taken by the calls to the stores and other nodes, by node, command and result.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"node", "cmd", "result"})
+
+	mongoOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mongo_pool_connections_open",
+		Help: "Connections of the mongo pool.",
+	})
+	mongoInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mongo_pool_connections_in_use",
+		Help: "Connections of the mongo pool checked out.",
+	})
+	mongoCheckouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_pool_checkouts_total",
+		Help: "Connections checked out of the mongo pool, by result.",
+	}, []string{"result"})
+	mongoCleared = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mongo_pool_cleared_total",
+		Help: "Times the mongo pool was cleared after an error.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration, calls,
+		mongoOpen, mongoInUse, mongoCheckouts, mongoCleared,
+	)
+}
+
+// ObserveRequest counts a request to route, the route as registered and
+// not the path requested so that the series stay few.
+func ObserveRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	requests.WithLabelValues(method, route, code).Inc()
+	requestDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveCall records a call to node, it's the logger.CallObserver of the
+// service.
+func ObserveCall(node, cmd string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	calls.WithLabelValues(node, cmd, result).Observe(d.Seconds())
+}
+
+// RegisterDB exports the pool statistics of db, labelled db_name=name.
+func RegisterDB(name string, db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// MongoPoolMonitor follows the connection pool of a mongo client.
+func MongoPoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		switch e.Type {
+		case event.ConnectionCreated:
+			mongoOpen.Inc()
+		case event.ConnectionClosed:
+			mongoOpen.Dec()
+		case event.GetSucceeded:
+			mongoInUse.Inc()
+			mongoCheckouts.WithLabelValues("success").Inc()
+		case event.GetFailed:
+			mongoCheckouts.WithLabelValues("error").Inc()
+		case event.ConnectionReturned:
+			mongoInUse.Dec()
+		case event.PoolCleared:
+			mongoCleared.Inc()
+		}
+	}}
+}
+
+// Write writes every metric in the Prometheus text format.
+func Write(w io.Writer) error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, f := range families {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
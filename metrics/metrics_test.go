@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/event"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestPools(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if err := RegisterDB("test", sqlDB); err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := MongoPoolMonitor()
+	for _, typ := range []string{
+		event.ConnectionCreated, event.ConnectionCreated, event.ConnectionClosed,
+		event.GetSucceeded, event.GetSucceeded, event.ConnectionReturned, event.GetFailed,
+	} {
+		monitor.Event(&event.PoolEvent{Type: typ})
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`go_sql_open_connections{db_name="test"} 1`,
+		"mongo_pool_connections_open 1",
+		"mongo_pool_connections_in_use 1",
+		`mongo_pool_checkouts_total{result="success"} 2`,
+		`mongo_pool_checkouts_total{result="error"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %s in\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Change is one field of a todo before and after a mutation.
+type Change struct {
+	From any `json:"from" bson:"from"`
+	To   any `json:"to" bson:"to"`
+}
+
+// AuditEntry records who changed a todo and how.
+type AuditEntry struct {
+	ID        string            `gorm:"primarykey" json:"id" bson:"id"`
+	TodoID    string            `gorm:"index" json:"todo_id" bson:"todo_id"`
+	Action    string            `json:"action" bson:"action"`
+	Session   string            `json:"session,omitempty" bson:"session,omitempty"`
+	UserID    string            `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Before    *Todo             `gorm:"serializer:json" json:"before,omitempty" bson:"before,omitempty"`
+	After     *Todo             `gorm:"serializer:json" json:"after,omitempty" bson:"after,omitempty"`
+	Diff      map[string]Change `gorm:"serializer:json" json:"diff" bson:"diff"`
+	CreatedAt time.Time         `gorm:"index" json:"created_at" bson:"created_at"`
+}
+
+func (AuditEntry) TableName() string {
+	return "todo_audit"
+}
+
+// TodoDiff compares the JSON form of two todos field by field. A nil todo
+// has no fields, so a create lists every field under To and a delete under
+// From.
+func TodoDiff(before, after *Todo) map[string]Change {
+	from, to := todoFields(before), todoFields(after)
+
+	diff := map[string]Change{}
+	for k, v := range from {
+		if w, ok := to[k]; !ok || !reflect.DeepEqual(v, w) {
+			diff[k] = Change{From: v, To: to[k]}
+		}
+	}
+	for k, w := range to {
+		if _, ok := from[k]; !ok {
+			diff[k] = Change{To: w}
+		}
+	}
+	return diff
+}
+
+func todoFields(todo *Todo) map[string]any {
+	fields := map[string]any{}
+	if todo == nil {
+		return fields
+	}
+	b, _ := json.Marshal(todo)
+	json.Unmarshal(b, &fields)
+	// derived from the id, not state
+	delete(fields, "href")
+	return fields
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Comment is a message in the discussion of a todo. Body is the Markdown
+// as written, HTML its sanitised rendering.
+type Comment struct {
+	ID     string `gorm:"primarykey" json:"id" bson:"id"`
+	TodoID string `gorm:"index:idx_comments_todo_created" json:"todo_id" bson:"todo_id"`
+	UserID string `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Body   string `json:"body" bson:"body"`
+	HTML   string `json:"html" bson:"html"`
+	// Mentions are the users the body @mentions.
+	Mentions  []string  `gorm:"serializer:json" json:"mentions,omitempty" bson:"mentions,omitempty"`
+	CreatedAt time.Time `gorm:"index:idx_comments_todo_created" json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (Comment) TableName() string {
+	return "todo_comments"
+}
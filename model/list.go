@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// List groups todos, which keep a manual order inside it by Todo.Rank.
+type List struct {
+	ID        string    `gorm:"primarykey" json:"id" bson:"id"`
+	Name      string    `json:"name" bson:"name" binding:"required"`
+	UserID    string    `gorm:"index" json:"user_id,omitempty" bson:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (List) TableName() string {
+	return "lists"
+}
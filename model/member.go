@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Roles on a list, each allowing what the one before it does.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// ValidRole reports whether role is one of the list roles.
+func ValidRole(role string) bool {
+	return roleLevels[role] > 0
+}
+
+// RoleAllows reports whether role grants at least want.
+func RoleAllows(role, want string) bool {
+	return ValidRole(role) && roleLevels[role] >= roleLevels[want]
+}
+
+// Membership gives a user a role on a list.
+type Membership struct {
+	ListID    string    `gorm:"primarykey" json:"list_id" bson:"list_id"`
+	UserID    string    `gorm:"primarykey;index" json:"user_id" bson:"user_id"`
+	Role      string    `json:"role" bson:"role"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (Membership) TableName() string {
+	return "list_members"
+}
+
+// Invitation lets whoever holds its token join a list once. Only a hash of
+// the token is stored, the token itself is shown when it is created.
+type Invitation struct {
+	ID         string     `gorm:"primarykey" json:"-" bson:"id"`
+	Token      string     `gorm:"-" json:"token,omitempty" bson:"-"`
+	ListID     string     `gorm:"index" json:"list_id" bson:"list_id"`
+	Role       string     `json:"role" bson:"role"`
+	InvitedBy  string     `json:"invited_by" bson:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+	AcceptedBy string     `json:"accepted_by,omitempty" bson:"accepted_by,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "list_invitations"
+}
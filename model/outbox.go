@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kinds of todo change, shared by the outbox and the event bus.
+const (
+	TodoCreated   = "created"
+	TodoUpdated   = "updated"
+	TodoCompleted = "completed"
+	TodoDeleted   = "deleted"
+	// TodoMentioned is a comment on the todo mentioning users, it
+	// changes nothing and only goes to the outbox.
+	TodoMentioned = "mentioned"
+)
+
+// UpdateKind tells a plain update from one that completed the todo.
+func UpdateKind(before, after *Todo) string {
+	if before != nil && !before.Completed && after.Completed {
+		return TodoCompleted
+	}
+	return TodoUpdated
+}
+
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+)
+
+// OutboxEntry is a todo change written in the same transaction as the change
+// itself, waiting to be relayed.
+type OutboxEntry struct {
+	ID          string     `gorm:"primarykey" json:"id" bson:"id"`
+	Event       string     `json:"event" bson:"event"`
+	TodoID      string     `gorm:"index" json:"todo_id" bson:"todo_id"`
+	Payload     string     `json:"payload" bson:"payload"`
+	Status      string     `gorm:"index" json:"status" bson:"status"`
+	Attempts    int        `json:"attempts" bson:"attempts"`
+	LastError   string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at" bson:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty" bson:"published_at,omitempty"`
+}
+
+func (OutboxEntry) TableName() string {
+	return "outbox"
+}
+
+// outboxTodo keeps the timestamps Todo leaves out of its JSON. Mention
+// entries carry the comment as well.
+type outboxTodo struct {
+	Todo
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Comment   *Comment  `json:"comment,omitempty"`
+}
+
+// OutboxPayload encodes todo for an outbox entry.
+func OutboxPayload(todo Todo) string {
+	b, _ := json.Marshal(outboxTodo{Todo: todo, CreatedAt: todo.CreatedAt, UpdatedAt: todo.UpdatedAt})
+	return string(b)
+}
+
+// MentionPayload encodes a comment mentioning users and its todo for a
+// TodoMentioned entry. The comment's Mentions are the users to notify.
+func MentionPayload(todo Todo, comment Comment) string {
+	b, _ := json.Marshal(outboxTodo{Todo: todo, CreatedAt: todo.CreatedAt, UpdatedAt: todo.UpdatedAt, Comment: &comment})
+	return string(b)
+}
+
+// Comment decodes the comment carried by a mention entry, nil for other
+// entries.
+func (e OutboxEntry) Comment() (*Comment, error) {
+	var t outboxTodo
+	if err := json.Unmarshal([]byte(e.Payload), &t); err != nil {
+		return nil, err
+	}
+	return t.Comment, nil
+}
+
+// Todo decodes the todo carried by the entry.
+func (e OutboxEntry) Todo() (Todo, error) {
+	var t outboxTodo
+	if err := json.Unmarshal([]byte(e.Payload), &t); err != nil {
+		return Todo{}, err
+	}
+	t.Todo.CreatedAt = t.CreatedAt
+	t.Todo.UpdatedAt = t.UpdatedAt
+	return t.Todo, nil
+}
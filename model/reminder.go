@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	ReminderDueSoon = "due_soon"
+	ReminderOverdue = "overdue"
+)
+
+// Reminder is a notification about a todo's due date. Its id is derived
+// from the todo, kind and due date, so each fires once.
+type Reminder struct {
+	ID     string    `gorm:"primarykey" json:"id" bson:"id"`
+	TodoID string    `gorm:"index" json:"todo_id" bson:"todo_id"`
+	Kind   string    `json:"kind" bson:"kind"`
+	DueAt  time.Time `json:"due_at" bson:"due_at"`
+	SentAt time.Time `json:"sent_at" bson:"sent_at"`
+}
+
+func (Reminder) TableName() string {
+	return "reminders"
+}
+
+// Lease is a named lock held by one replica until it expires.
+type Lease struct {
+	Name      string    `gorm:"primarykey" json:"name" bson:"name"`
+	Holder    string    `json:"holder" bson:"holder"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+func (Lease) TableName() string {
+	return "leases"
+}
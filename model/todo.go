@@ -3,14 +3,61 @@ package model
 import "time"
 
 type Todo struct {
-	ID        string     `gorm:"primarykey" json:"id,omitempty" bson:"id"`
-	Title     string     `json:"text,omitempty" binding:"required"`
-	Href      string     `json:"href,omitempty"`
-	CreatedAt time.Time  `json:"-" bson:"created_at,omitempty"`
-	UpdatedAt time.Time  `json:"-" bson:"updated_at,omitempty"`
-	DeletedAt *time.Time `gorm:"index" json:"-" bson:"deleted_at,omitempty"`
+	ID       string `gorm:"primarykey" json:"id,omitempty" bson:"id"`
+	Title    string `json:"text,omitempty" binding:"required"`
+	Href     string `json:"href,omitempty"`
+	UserID   string `gorm:"index" json:"user_id,omitempty" bson:"user_id,omitempty"`
+	ParentID string `gorm:"index" json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	ListID   string `gorm:"index:idx_todos_list_rank" json:"list_id,omitempty" bson:"list_id,omitempty"`
+	// Rank orders the todos of a list, see package rank.
+	Rank        string     `gorm:"index:idx_todos_list_rank" json:"rank,omitempty" bson:"rank,omitempty"`
+	Completed   bool       `json:"completed" bson:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	DueAt       *time.Time `gorm:"index" json:"due_at,omitempty" bson:"due_at,omitempty"`
+	// TimeZone is the IANA zone recurring due dates keep their wall clock
+	// time in, UTC when empty.
+	TimeZone   string `json:"time_zone,omitempty" bson:"time_zone,omitempty"`
+	Recurrence string `json:"recurrence,omitempty" bson:"recurrence,omitempty"`
+	// SeriesID is the id of the first todo of a recurrence, SeriesStart its
+	// due date.
+	SeriesID    string       `gorm:"index" json:"series_id,omitempty" bson:"series_id,omitempty"`
+	SeriesStart *time.Time   `json:"series_start,omitempty" bson:"series_start,omitempty"`
+	Attachments []Attachment `gorm:"serializer:json" json:"attachments,omitempty" bson:"attachments,omitempty"`
+	CreatedAt   time.Time    `json:"-" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time    `json:"-" bson:"updated_at,omitempty"`
+	DeletedAt   *time.Time   `gorm:"index" json:"-" bson:"deleted_at,omitempty"`
 }
 
 func (Todo) TableName() string {
 	return "todos"
 }
+
+// Location is the time zone of the todo's due dates.
+func (t Todo) Location() (*time.Location, error) {
+	if t.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(t.TimeZone)
+}
+
+// Attachment describes a file attached to a todo, the content is kept in a
+// blob store.
+type Attachment struct {
+	ID          string    `json:"id" bson:"id"`
+	Name        string    `json:"name" bson:"name"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	UserID      string    `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Dependency says TodoID can't be done before BlockedByID.
+type Dependency struct {
+	TodoID      string    `gorm:"primarykey" json:"todo_id" bson:"todo_id"`
+	BlockedByID string    `gorm:"primarykey;index" json:"blocked_by_id" bson:"blocked_by_id"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (Dependency) TableName() string {
+	return "todo_dependencies"
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+type Webhook struct {
+	ID        string    `gorm:"primarykey" json:"id" bson:"id"`
+	URL       string    `json:"url" bson:"url" binding:"required"`
+	Secret    string    `json:"secret,omitempty" bson:"secret"`
+	Events    []string  `gorm:"serializer:json" json:"events,omitempty" bson:"events,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Wants reports whether the webhook subscribed to event, no events means all.
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type Delivery struct {
+	ID          string    `gorm:"primarykey" json:"id" bson:"id"`
+	WebhookID   string    `gorm:"index" json:"webhook_id" bson:"webhook_id"`
+	Event       string    `json:"event" bson:"event"`
+	Payload     string    `json:"payload" bson:"payload"`
+	Status      string    `gorm:"index" json:"status" bson:"status"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	NextAttempt time.Time `gorm:"index" json:"next_attempt" bson:"next_attempt"`
+	// ClaimedBy is the claim of the replica sending the delivery, see
+	// store.WebhookStorer.ClaimDeliveries.
+	ClaimedBy    string    `gorm:"index" json:"claimed_by,omitempty" bson:"claimed_by,omitempty"`
+	ResponseCode int       `json:"response_code,omitempty" bson:"response_code,omitempty"`
+	LastError    string    `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Spec returns the raw OpenAPI document served by the api.
+func Spec() []byte {
+	return spec
+}
+
+// attachments are checked as opaque files, like octet streams.
+func init() {
+	for _, contentType := range []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf"} {
+		openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+	}
+}
+
+type Validator struct {
+	router routers.Router
+	debug  bool
+}
+
+// New loads the embedded document. When debug is true responses are
+// validated as well as requests.
+func New(debug bool) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{router: router, debug: debug}, nil
+}
+
+func (v *Validator) Debug() bool {
+	return v.debug
+}
+
+// ValidateRequest checks r against the document. The returned input is nil
+// when the route is not described by the document, in which case the
+// request is let through untouched.
+func (v *Validator) ValidateRequest(r *http.Request) (*openapi3filter.RequestValidationInput, error) {
+	route, pathParams, err := v.router.FindRoute(r)
+	if err != nil {
+		return nil, nil
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+
+	if err := openapi3filter.ValidateRequest(context.Background(), input); err != nil {
+		return input, err
+	}
+
+	return input, nil
+}
+
+func (v *Validator) ValidateResponse(input *openapi3filter.RequestValidationInput, status int, header http.Header, body []byte) error {
+	return openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			MultiError:            true,
+			IncludeResponseStatus: true,
+		},
+	})
+}
+
+type Violation struct {
+	In     string `json:"in"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Violations flattens the errors returned by ValidateRequest and
+// ValidateResponse into one entry per failed check.
+func Violations(err error) []Violation {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var out []Violation
+		for _, e := range multi {
+			out = append(out, Violations(e)...)
+		}
+		return out
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		v := Violation{In: "body", Reason: reqErr.Reason}
+		if reqErr.Parameter != nil {
+			v.In = reqErr.Parameter.In
+			v.Name = reqErr.Parameter.Name
+		}
+		if reqErr.Err != nil {
+			if inner := Violations(reqErr.Err); len(inner) != 0 {
+				for i := range inner {
+					inner[i].In = v.In
+					if v.Name != "" {
+						inner[i].Name = v.Name
+					}
+				}
+				return inner
+			}
+			v.Reason = strings.TrimSpace(strings.Join([]string{v.Reason, reqErr.Err.Error()}, " "))
+		}
+		return []Violation{v}
+	}
+
+	var resErr *openapi3filter.ResponseError
+	if errors.As(err, &resErr) {
+		if resErr.Err != nil {
+			if inner := Violations(resErr.Err); len(inner) != 0 {
+				for i := range inner {
+					inner[i].In = "response"
+				}
+				return inner
+			}
+		}
+		return []Violation{{In: "response", Reason: resErr.Error()}}
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return []Violation{{
+			Name:   strings.Join(schemaErr.JSONPointer(), "."),
+			Reason: schemaErr.Reason,
+		}}
+	}
+
+	return nil
+}
+
+// BadRequest is the body returned when a request breaks the contract.
+func BadRequest(err error) map[string]any {
+	violations := Violations(err)
+	if len(violations) == 0 {
+		violations = []Violation{{Reason: err.Error()}}
+	}
+	return map[string]any{
+		"error":      "bad_request",
+		"violations": violations,
+	}
+}
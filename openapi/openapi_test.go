@@ -0,0 +1,75 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	v, err := New(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		wantErr bool
+		wantIn  string
+	}{
+		{name: "list", method: http.MethodGet, target: "/todo?sort=title&order=desc&fields=id,title"},
+		{name: "bad order", method: http.MethodGet, target: "/todo?sort=title&order=up", wantErr: true, wantIn: "query"},
+		{name: "bad fields", method: http.MethodGet, target: "/todo?fields=id,DROP", wantErr: true, wantIn: "query"},
+		{name: "create", method: http.MethodPost, target: "/todo", body: `{"text":"Learn Go"}`},
+		{name: "missing text", method: http.MethodPost, target: "/todo", body: `{}`, wantErr: true, wantIn: "body"},
+		{name: "unknown route", method: http.MethodGet, target: "/unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+
+			_, err := v.ValidateRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+
+			if err != nil {
+				violations := Violations(err)
+				if len(violations) == 0 || violations[0].In != tt.wantIn {
+					t.Errorf("want violation in %s, got %+v", tt.wantIn, violations)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	v, err := New(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{"text":"Learn Go"}`))
+	req.Header.Set("Content-Type", "application/json")
+	input, err := v.ValidateRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if err := v.ValidateResponse(input, http.StatusCreated, header, []byte(`{"ID":"1"}`)); err != nil {
+		t.Errorf("want valid response, got %v", err)
+	}
+
+	if err := v.ValidateResponse(input, http.StatusOK, header, []byte(`{"ID":"1"}`)); err == nil {
+		t.Error("want error for undocumented status")
+	}
+}
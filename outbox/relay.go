@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+// Relay publishes pending outbox entries to a sink in the order they were
+// written. An entry is marked published only after the sink accepted it, so
+// a crash in between publishes it again: delivery is at least once. A
+// failing entry holds back the ones after it until it goes through.
+type Relay struct {
+	store store.OutboxStorer
+	sink  Sink
+
+	BatchSize int
+	Interval  time.Duration
+
+	published atomic.Int64
+	failed    atomic.Int64
+
+	mu          sync.Mutex
+	lastRun     time.Time
+	lastError   string
+	oldestSince time.Time
+
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewRelay(store store.OutboxStorer, sink Sink, log *slog.Logger) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		store:     store,
+		sink:      sink,
+		BatchSize: 100,
+		Interval:  time.Second,
+		log:       log,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (r *Relay) Serve() error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.relay()
+		}
+	}
+}
+
+func (r *Relay) Shutdown(context.Context) error {
+	r.cancel()
+	return nil
+}
+
+func (r *Relay) pollLog(name string) *logger.Poll {
+	return logger.NewPoll(r.log, name, map[string]any{"route": "outbox", "method": "worker"})
+}
+
+// relay makes one pass over the pending entries. It keeps going while full
+// batches publish cleanly so a backlog drains without waiting for ticks. A
+// pass finding nothing pending logs nothing.
+func (r *Relay) relay() {
+	log := r.pollLog("outbox_relay")
+	for r.ctx.Err() == nil {
+		entries, err := r.store.PendingOutbox(r.BatchSize, log)
+		if err != nil {
+			r.record(time.Time{}, err)
+			return
+		}
+		if len(entries) > 0 {
+			log.Keep()
+		}
+
+		var oldest time.Time
+		if len(entries) > 0 {
+			oldest = entries[0].CreatedAt
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if err := r.sink.Publish(r.ctx, *entry); err != nil {
+				r.failed.Add(1)
+				log.AddError("outbox", "publish", "output", map[string]any{"id": entry.ID, "attempts": entry.Attempts + 1}, err)
+				r.store.MarkFailed(entry, err, log)
+				r.record(entry.CreatedAt, err)
+				return
+			}
+			r.published.Add(1)
+			if err := r.store.MarkPublished(entry, log); err != nil {
+				// it goes out again on the next pass
+				r.record(entry.CreatedAt, err)
+				return
+			}
+			if i+1 < len(entries) {
+				oldest = entries[i+1].CreatedAt
+			} else {
+				oldest = time.Time{}
+			}
+		}
+
+		r.record(oldest, nil)
+		if len(entries) < r.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) record(oldest time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastRun = time.Now()
+	r.oldestSince = oldest
+	r.lastError = ""
+	if err != nil {
+		r.lastError = err.Error()
+	}
+}
+
+type Metrics struct {
+	Published  int64   `json:"published"`
+	Failed     int64   `json:"failed"`
+	Pending    int64   `json:"pending"`
+	LagSeconds float64 `json:"lag_seconds"`
+	LastRun    string  `json:"last_run,omitempty"`
+	LastError  string  `json:"last_error,omitempty"`
+}
+
+// Metrics reports what the relay published and failed to publish since it
+// started, and how far behind it is. Pending is read from the store.
+func (r *Relay) Metrics(log logger.ILogDetail) (Metrics, error) {
+	r.mu.Lock()
+	m := Metrics{
+		Published: r.published.Load(),
+		Failed:    r.failed.Load(),
+		LastError: r.lastError,
+	}
+	if !r.lastRun.IsZero() {
+		m.LastRun = r.lastRun.Format(time.RFC3339)
+	}
+	if !r.oldestSince.IsZero() {
+		m.LagSeconds = time.Since(r.oldestSince).Seconds()
+	}
+	r.mu.Unlock()
+
+	pending, err := r.store.CountOutbox(model.OutboxPending, log)
+	if err != nil {
+		return m, err
+	}
+	m.Pending = pending
+	return m, nil
+}
+
+func (r *Relay) MetricsHandler(c router.IContext) {
+	cmd := "outbox metrics"
+	logger := c.Log("outbox_metrics")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	m, err := r.Metrics(logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	logger.AddOutput("client", cmd, m).End()
+	c.JSON(http.StatusOK, m)
+}
@@ -0,0 +1,191 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.OutboxEntry{}, &model.AuditEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testLog() logger.ILogDetail {
+	return logger.New(slog.Default(), "", nil)
+}
+
+type recordSink struct {
+	entries []model.OutboxEntry
+	fail    error
+}
+
+func (s *recordSink) Publish(_ context.Context, entry model.OutboxEntry) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestStoreWritesOutbox(t *testing.T) {
+	db := newDB(t)
+	todos := store.NewGormStore(db)
+
+	todo := &model.Todo{Title: "write tests", UserID: "alice"}
+	if err := todos.Create(todo, testLog()); err != nil {
+		t.Fatal(err)
+	}
+	todo.Completed = true
+	if err := todos.Update(todo, testLog()); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Delete(todo.ID, testLog()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.NewGormOutboxStore(db).PendingOutbox(10, testLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{model.TodoCreated, model.TodoCompleted, model.TodoDeleted}
+	if len(entries) != len(want) {
+		t.Fatalf("want %d entries, got %d", len(want), len(entries))
+	}
+	for i, e := range entries {
+		if e.Event != want[i] {
+			t.Errorf("entry %d: want %s, got %s", i, want[i], e.Event)
+		}
+		got, err := e.Todo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != todo.ID || got.UserID != "alice" || got.UpdatedAt.IsZero() {
+			t.Errorf("entry %d: unexpected todo %+v", i, got)
+		}
+	}
+}
+
+func TestStoreRollsBackOutbox(t *testing.T) {
+	db := newDB(t)
+	todos := store.NewGormStore(db)
+
+	if err := todos.Update(&model.Todo{ID: "missing", Title: "x"}, testLog()); err == nil {
+		t.Fatal("want error updating a missing todo")
+	}
+
+	// a failed outbox write undoes the change
+	db.Migrator().DropTable(&model.OutboxEntry{})
+	if err := todos.Create(&model.Todo{Title: "lost"}, testLog()); err == nil {
+		t.Fatal("want error without an outbox table")
+	}
+
+	var n int64
+	db.Model(&model.Todo{}).Count(&n)
+	if n != 0 {
+		t.Errorf("want no todos, got %d", n)
+	}
+}
+
+func TestRelayAtLeastOnce(t *testing.T) {
+	db := newDB(t)
+	todos := store.NewGormStore(db)
+	for _, title := range []string{"a", "b", "c"} {
+		todos.Create(&model.Todo{Title: title}, testLog())
+	}
+
+	sink := &recordSink{fail: errors.New("broker down")}
+	relay := NewRelay(store.NewGormOutboxStore(db), sink, slog.Default())
+	relay.BatchSize = 2
+
+	relay.relay()
+	m, _ := relay.Metrics(testLog())
+	if m.Failed != 1 || m.Published != 0 || m.Pending != 3 || m.LastError != "broker down" {
+		t.Errorf("unexpected metrics after failure %+v", m)
+	}
+
+	sink.fail = nil
+	relay.relay()
+	m, _ = relay.Metrics(testLog())
+	if m.Published != 3 || m.Pending != 0 || m.LagSeconds != 0 {
+		t.Errorf("unexpected metrics after recovery %+v", m)
+	}
+
+	for i, title := range []string{"a", "b", "c"} {
+		todo, _ := sink.entries[i].Todo()
+		if todo.Title != title {
+			t.Errorf("entry %d: want %s, got %s", i, title, todo.Title)
+		}
+	}
+
+	var first model.OutboxEntry
+	db.First(&first, "id = ?", sink.entries[0].ID)
+	if first.Status != model.OutboxPublished || first.Attempts != 2 || first.PublishedAt == nil {
+		t.Errorf("unexpected bookkeeping %+v", first)
+	}
+}
+
+func TestIdleRelayLogsNothing(t *testing.T) {
+	db := newDB(t)
+	var buf bytes.Buffer
+	relay := NewRelay(store.NewGormOutboxStore(db), &recordSink{}, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	relay.relay()
+	if buf.Len() != 0 {
+		t.Errorf("want nothing logged without entries, got:\n%s", buf.String())
+	}
+
+	store.NewGormStore(db).Create(&model.Todo{Title: "a"}, testLog())
+	relay.relay()
+	if !bytes.Contains(buf.Bytes(), []byte("pending_outbox")) {
+		t.Errorf("want the pass publishing an entry logged, got:\n%s", buf.String())
+	}
+}
+
+func TestFanoutSink(t *testing.T) {
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	// wired as with a change stream: only mentions go to the bus, every
+	// entry goes out
+	out := &recordSink{}
+	sink := NewFanoutSink(NewEventSink(NewBusSink(bus), Discard, model.TodoMentioned), out)
+	for _, event := range []string{model.TodoCreated, model.TodoMentioned} {
+		if err := sink.Publish(context.Background(), model.OutboxEntry{ID: event, Event: event, Payload: `{"id":"1"}`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(out.entries) != 2 {
+		t.Errorf("want every entry published out, got %d", len(out.entries))
+	}
+	if e := <-ch; e.Type != model.TodoMentioned || len(ch) != 0 {
+		t.Errorf("want only the mention on the bus, got %s and %d more", e.Type, len(ch))
+	}
+
+	out.fail = errors.New("broker down")
+	if err := sink.Publish(context.Background(), model.OutboxEntry{Event: model.TodoCreated, Payload: `{}`}); err == nil {
+		t.Error("want the failure of a sink reported")
+	}
+}
@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// Sink is where the relay publishes outbox entries. An entry may reach a
+// sink more than once, consumers should dedupe on its id.
+type Sink interface {
+	Publish(ctx context.Context, entry model.OutboxEntry) error
+}
+
+// Message is the form an entry leaves the service in.
+type Message struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func NewMessage(entry model.OutboxEntry) Message {
+	return Message{
+		ID:    entry.ID,
+		Event: "todo." + entry.Event,
+		Time:  entry.CreatedAt,
+		Data:  json.RawMessage(entry.Payload),
+	}
+}
+
+// LogSink writes entries to the log, for when nothing consumes them yet.
+type LogSink struct {
+	log *slog.Logger
+}
+
+func NewLogSink(log *slog.Logger) *LogSink {
+	return &LogSink{log: log}
+}
+
+func (s *LogSink) Publish(_ context.Context, entry model.OutboxEntry) error {
+	s.log.Info("outbox", slog.Any("message", NewMessage(entry)))
+	return nil
+}
+
+// BusSink feeds the in-process event bus, and through it the SSE,
+// WebSocket and GraphQL consumers.
+type BusSink struct {
+	bus *events.Bus
+}
+
+func NewBusSink(bus *events.Bus) *BusSink {
+	return &BusSink{bus: bus}
+}
+
+func (s *BusSink) Publish(_ context.Context, entry model.OutboxEntry) error {
+	todo, err := entry.Todo()
+	if err != nil {
+		return err
+	}
+	comment, err := entry.Comment()
+	if err != nil {
+		return err
+	}
+	s.bus.Publish(events.Event{Type: entry.Event, Todo: todo, Time: entry.CreatedAt, Comment: comment})
+	return nil
+}
+
+// EventSink publishes the entries of some events to one sink and all the
+// others to another.
+type EventSink struct {
+	events map[string]bool
+	match  Sink
+	rest   Sink
+}
+
+func NewEventSink(match, rest Sink, events ...string) *EventSink {
+	s := &EventSink{events: map[string]bool{}, match: match, rest: rest}
+	for _, e := range events {
+		s.events[e] = true
+	}
+	return s
+}
+
+func (s *EventSink) Publish(ctx context.Context, entry model.OutboxEntry) error {
+	if s.events[entry.Event] {
+		return s.match.Publish(ctx, entry)
+	}
+	return s.rest.Publish(ctx, entry)
+}
+
+// FanoutSink publishes every entry to each of its sinks in turn. An entry
+// one of them fails is published to all of them again on the next try.
+type FanoutSink struct {
+	sinks []Sink
+}
+
+func NewFanoutSink(sinks ...Sink) *FanoutSink {
+	return &FanoutSink{sinks: sinks}
+}
+
+func (s *FanoutSink) Publish(ctx context.Context, entry model.OutboxEntry) error {
+	for _, sink := range s.sinks {
+		if err := sink.Publish(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard drops the entries, for the events of an EventSink no one needs.
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) Publish(context.Context, model.OutboxEntry) error { return nil }
+
+// HTTPSink posts each message as JSON to URL.
+type HTTPSink struct {
+	URL    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{URL: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, entry model.OutboxEntry) error {
+	body, err := json.Marshal(NewMessage(entry))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Broker is the part of a message broker client the relay needs, e.g. a
+// Kafka or NATS producer.
+type Broker interface {
+	Publish(ctx context.Context, topic, key string, body []byte) error
+}
+
+// BrokerSink publishes messages to topic keyed by todo id, so a
+// partitioned broker keeps the changes of a todo in order.
+type BrokerSink struct {
+	broker Broker
+	topic  string
+}
+
+func NewBrokerSink(broker Broker, topic string) *BrokerSink {
+	return &BrokerSink{broker: broker, topic: topic}
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, entry model.OutboxEntry) error {
+	body, err := json.Marshal(NewMessage(entry))
+	if err != nil {
+		return err
+	}
+	return s.broker.Publish(ctx, s.topic, entry.TodoID, body)
+}
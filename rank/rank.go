@@ -0,0 +1,91 @@
+// Package rank generates lexicographically sortable keys for manual
+// ordering. A key between two neighbours can always be made without
+// touching any other key, so moving an item writes only that item.
+package rank
+
+import (
+	"fmt"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// Between returns a key that sorts strictly after prev and before next. An
+// empty prev stands for the start of the list and an empty next for its
+// end. Keys are read as base 36 fractions, so they never end in "0" and
+// there is always room for another one in between.
+func Between(prev, next string) (string, error) {
+	if err := valid(prev); err != nil {
+		return "", err
+	}
+	if err := valid(next); err != nil {
+		return "", err
+	}
+	if next != "" && prev >= next {
+		return "", fmt.Errorf("rank %q is not before %q", prev, next)
+	}
+	return midpoint(prev, next), nil
+}
+
+func valid(key string) error {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return fmt.Errorf("malformed rank %q", key)
+		}
+	}
+	if strings.HasSuffix(key, "0") {
+		return fmt.Errorf("malformed rank %q", key)
+	}
+	return nil
+}
+
+// midpoint works digit by digit, an empty next being one past the largest
+// digit.
+func midpoint(prev, next string) string {
+	if next != "" {
+		// keep the common prefix, prev is padded with zeros
+		n := 0
+		for n < len(next) && digitAt(prev, n) == next[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(prev) {
+				rest = prev[n:]
+			}
+			return next[:n] + midpoint(rest, next[n:])
+		}
+	}
+
+	lo := 0
+	if prev != "" {
+		lo = strings.IndexByte(digits, prev[0])
+	}
+	hi := base
+	if next != "" {
+		hi = strings.IndexByte(digits, next[0])
+	}
+
+	if hi-lo > 1 {
+		return string(digits[(lo+hi)/2])
+	}
+	// the first digits are neighbours: next's first digit alone sorts
+	// between them if next goes on, otherwise look after prev's
+	if len(next) > 1 {
+		return next[:1]
+	}
+	rest := ""
+	if len(prev) > 1 {
+		rest = prev[1:]
+	}
+	return string(digits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return '0'
+}
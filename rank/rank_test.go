@@ -0,0 +1,69 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	for _, tt := range []struct{ prev, next, want string }{
+		{"", "", "i"},
+		{"i", "", "r"},
+		{"", "i", "9"},
+		{"a", "b", "ai"},
+		{"a", "a1", "a0i"},
+		{"az", "b", "azi"},
+		{"zz", "", "zzi"},
+		{"", "01", "00i"},
+	} {
+		got, err := Between(tt.prev, tt.next)
+		if err != nil {
+			t.Errorf("Between(%q, %q): %v", tt.prev, tt.next, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Between(%q, %q): want %q, got %q", tt.prev, tt.next, tt.want, got)
+		}
+	}
+
+	for _, tt := range []struct{ prev, next string }{
+		{"b", "a"},
+		{"a", "a"},
+		{"a0", ""},
+		{"A", ""},
+	} {
+		if _, err := Between(tt.prev, tt.next); err == nil {
+			t.Errorf("Between(%q, %q): want error", tt.prev, tt.next)
+		}
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 1000; i++ {
+		// insert at a random position, including both ends
+		at := r.Intn(len(keys) + 1)
+		prev, next := "", ""
+		if at > 0 {
+			prev = keys[at-1]
+		}
+		if at < len(keys) {
+			next = keys[at]
+		}
+
+		key, err := Between(prev, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key <= prev || (next != "" && key >= next) {
+			t.Fatalf("Between(%q, %q) = %q is out of order", prev, next, key)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+
+	if !sort.StringsAreSorted(keys) {
+		t.Error("keys are not sorted")
+	}
+}
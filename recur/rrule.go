@@ -0,0 +1,335 @@
+// Package recur implements the subset of RFC 5545 recurrence rules todos
+// use: FREQ=DAILY, WEEKLY or MONTHLY with INTERVAL, BYDAY, COUNT and UNTIL.
+package recur
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// the zones of recurring todos must resolve in images without zoneinfo
+	_ "time/tzdata"
+)
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// maxPeriods bounds the search for the next occurrence, a rule like the
+// 31st of every 12th month skips many periods but never this many.
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Day is a BYDAY entry. N picks the nth weekday of the month, counting from
+// the end when negative. It is only allowed in monthly rules, 0 means every
+// such weekday.
+type Day struct {
+	N       int
+	Weekday time.Weekday
+}
+
+func (d Day) String() string {
+	s := strings.ToUpper(d.Weekday.String()[:2])
+	if d.N != 0 {
+		s = strconv.Itoa(d.N) + s
+	}
+	return s
+}
+
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []Day
+	Count    int
+	Until    time.Time
+}
+
+// Parse reads a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE". A leading
+// "RRULE:" is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty rule")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("malformed part %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s given twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly {
+				err = fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = positive(name, value)
+		case "COUNT":
+			r.Count, err = positive(name, value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				err = fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL are exclusive")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly {
+			return nil, fmt.Errorf("BYDAY %s needs FREQ=MONTHLY", d)
+		}
+	}
+	return r, nil
+}
+
+func positive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+	return n, nil
+}
+
+// parseUntil takes the date and UTC date-time forms. A floating local time
+// is read as UTC.
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// a date includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("malformed UNTIL %s", value)
+}
+
+func parseByDay(value string) ([]Day, error) {
+	var days []Day
+	for _, v := range strings.Split(strings.ToUpper(value), ",") {
+		if len(v) < 2 {
+			return nil, fmt.Errorf("malformed BYDAY %s", v)
+		}
+		wd, ok := weekdays[v[len(v)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("malformed BYDAY %s", v)
+		}
+		d := Day{Weekday: wd}
+		if n := v[:len(v)-2]; n != "" {
+			var err error
+			d.N, err = strconv.Atoi(n)
+			if err != nil || d.N == 0 || d.N < -5 || d.N > 5 {
+				return nil, fmt.Errorf("malformed BYDAY %s", v)
+			}
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after after of the series starting at
+// start, false once the series is over. Occurrences keep the wall clock
+// time of start in its location across DST changes. A time that falls in a
+// spring-forward gap moves forward by the length of the gap, and one that
+// happens twice when clocks fall back is the first of the two.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	n := 0
+	for p := 0; p < maxPeriods; p++ {
+		for _, t := range r.period(start, p) {
+			if t.Before(start) {
+				continue
+			}
+			n++
+			if r.Count > 0 && n > r.Count {
+				return time.Time{}, false
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return time.Time{}, false
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// period returns the occurrences of the pth interval after start, in order.
+func (r *Rule) period(start time.Time, p int) []time.Time {
+	y, m, d := start.Date()
+	var dates []time.Time
+	switch r.Freq {
+	case Daily:
+		day := civil(y, m, d+p*r.Interval)
+		if r.matchesWeekday(day.Weekday()) {
+			dates = append(dates, day)
+		}
+	case Weekly:
+		// weeks start on monday
+		monday := civil(y, m, d-(int(start.Weekday())+6)%7+p*7*r.Interval)
+		if len(r.ByDay) == 0 {
+			dates = append(dates, civil(y, m, d+p*7*r.Interval))
+			break
+		}
+		for i := 0; i < 7; i++ {
+			day := monday.AddDate(0, 0, i)
+			if r.matchesWeekday(day.Weekday()) {
+				dates = append(dates, day)
+			}
+		}
+	case Monthly:
+		first := civil(y, m+time.Month(p*r.Interval), 1)
+		if len(r.ByDay) == 0 {
+			// months without the day are skipped, not clamped
+			if day := civil(first.Year(), first.Month(), d); day.Month() == first.Month() {
+				dates = append(dates, day)
+			}
+			break
+		}
+		dates = r.monthDays(first)
+	}
+
+	times := make([]time.Time, len(dates))
+	for i, day := range dates {
+		times[i] = wallClock(day, start)
+	}
+	return times
+}
+
+func (r *Rule) matchesWeekday(wd time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// monthDays returns the days of the month starting at first picked by
+// BYDAY.
+func (r *Rule) monthDays(first time.Time) []time.Time {
+	var all []time.Time
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		all = append(all, day)
+	}
+
+	picked := map[int]bool{}
+	for _, d := range r.ByDay {
+		var matching []int
+		for i, day := range all {
+			if day.Weekday() == d.Weekday {
+				matching = append(matching, i)
+			}
+		}
+		switch {
+		case d.N == 0:
+			for _, i := range matching {
+				picked[i] = true
+			}
+		case d.N > 0 && d.N <= len(matching):
+			picked[matching[d.N-1]] = true
+		case d.N < 0 && -d.N <= len(matching):
+			picked[matching[len(matching)+d.N]] = true
+		}
+	}
+
+	var days []int
+	for i := range picked {
+		days = append(days, i)
+	}
+	sort.Ints(days)
+
+	dates := make([]time.Time, len(days))
+	for i, d := range days {
+		dates[i] = all[d]
+	}
+	return dates
+}
+
+// civil is a calendar date, normalized the way time.Date does.
+func civil(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// wallClock puts day at the time of day of start, in start's location.
+func wallClock(day, start time.Time) time.Time {
+	loc := start.Location()
+	h, min, s := start.Clock()
+	t := time.Date(day.Year(), day.Month(), day.Day(), h, min, s, start.Nanosecond(), loc)
+
+	// time.Date doesn't promise which offset it picks in a gap or overlap
+	for _, offset := range []time.Duration{-time.Hour, -30 * time.Minute} {
+		earlier := t.Add(offset)
+		if eh, emin, es := earlier.Clock(); eh == h && emin == min && es == s && earlier.Day() == day.Day() {
+			return earlier
+		}
+	}
+	if th, tmin, _ := t.Clock(); th != h || tmin != min {
+		// in a gap: keep the instant the clock would read had it not jumped
+		_, before := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).Zone()
+		return time.Date(day.Year(), day.Month(), day.Day(), h, min, s, start.Nanosecond(), time.FixedZone("", before)).In(loc)
+	}
+	return t
+}
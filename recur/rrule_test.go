@@ -0,0 +1,194 @@
+package recur
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func mustParse(t *testing.T, s string) *Rule {
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return r
+}
+
+// occurrences lists up to n occurrences of rule from start.
+func occurrences(r *Rule, start time.Time, n int) []time.Time {
+	var got []time.Time
+	after := start.Add(-time.Nanosecond)
+	for len(got) < n {
+		next, ok := r.Next(start, after)
+		if !ok {
+			break
+		}
+		got = append(got, next)
+		after = next
+	}
+	return got
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]string{
+		"FREQ=DAILY": "FREQ=DAILY",
+		"RRULE:freq=weekly;interval=2;byday=mo,we":     "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+		"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3":              "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+		"FREQ=DAILY;INTERVAL=1;UNTIL=20261231T000000Z": "FREQ=DAILY;UNTIL=20261231T000000Z",
+	} {
+		if got := mustParse(t, in).String(); got != want {
+			t.Errorf("%s: want %s, got %s", in, want, got)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("%q: want error", in)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 9, 0, 0, 0, utc)
+	}
+
+	tests := []struct {
+		rule  string
+		start time.Time
+		want  []time.Time
+		// the series has no occurrences after want
+		ends bool
+	}{
+		{
+			rule:  "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start: date(2026, 1, 30),
+			want:  []time.Time{date(2026, 1, 30), date(2026, 2, 1), date(2026, 2, 3)},
+			ends:  true,
+		},
+		{
+			// weekdays only, starting on a saturday
+			rule:  "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			start: date(2026, 10, 17),
+			want:  []time.Time{date(2026, 10, 19), date(2026, 10, 20), date(2026, 10, 21)},
+		},
+		{
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,FR",
+			start: date(2026, 10, 16),
+			want:  []time.Time{date(2026, 10, 16), date(2026, 10, 27), date(2026, 10, 30), date(2026, 11, 10)},
+		},
+		{
+			rule:  "FREQ=WEEKLY",
+			start: date(2026, 12, 28),
+			want:  []time.Time{date(2026, 12, 28), date(2027, 1, 4)},
+		},
+		{
+			// months without a 31st are skipped
+			rule:  "FREQ=MONTHLY",
+			start: date(2026, 1, 31),
+			want:  []time.Time{date(2026, 1, 31), date(2026, 3, 31), date(2026, 5, 31)},
+		},
+		{
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: date(2026, 10, 1),
+			want:  []time.Time{date(2026, 10, 30), date(2026, 11, 27), date(2026, 12, 25)},
+		},
+		{
+			rule:  "FREQ=MONTHLY;INTERVAL=3;BYDAY=1MO,3MO",
+			start: date(2026, 1, 1),
+			want:  []time.Time{date(2026, 1, 5), date(2026, 1, 19), date(2026, 4, 6), date(2026, 4, 20)},
+		},
+		{
+			rule:  "FREQ=DAILY;UNTIL=20260103",
+			start: date(2026, 1, 1),
+			want:  []time.Time{date(2026, 1, 1), date(2026, 1, 2), date(2026, 1, 3)},
+			ends:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		got := occurrences(mustParse(t, tt.rule), tt.start, len(tt.want)+1)
+		if tt.ends && len(got) > len(tt.want) {
+			t.Errorf("%s: series should have ended, got %v", tt.rule, got[len(tt.want)])
+		}
+		for i, want := range tt.want {
+			if i >= len(got) || !got[i].Equal(want) {
+				t.Errorf("%s: want %v, got %v", tt.rule, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestNextKeepsWallClockAcrossDST(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	r := mustParse(t, "FREQ=DAILY")
+
+	// clocks go forward on 29 march 2026 and back on 25 october 2026
+	start := time.Date(2026, 3, 28, 9, 0, 0, 0, berlin)
+	got := occurrences(r, start, 3)
+	for i, want := range []string{"2026-03-28T09:00:00+01:00", "2026-03-29T09:00:00+02:00", "2026-03-30T09:00:00+02:00"} {
+		if got[i].Format(time.RFC3339) != want {
+			t.Errorf("spring %d: want %s, got %s", i, want, got[i].Format(time.RFC3339))
+		}
+	}
+
+	start = time.Date(2026, 10, 24, 9, 0, 0, 0, berlin)
+	got = occurrences(r, start, 2)
+	if d := got[1].Sub(got[0]); d != 25*time.Hour {
+		t.Errorf("fall: want 25h between occurrences, got %s", d)
+	}
+}
+
+func TestNextInDSTGap(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	r := mustParse(t, "FREQ=WEEKLY")
+
+	// 02:30 doesn't exist on 8 march 2026, it moves to 03:30 EDT
+	start := time.Date(2026, 3, 1, 2, 30, 0, 0, newYork)
+	got := occurrences(r, start, 3)
+	for i, want := range []string{"2026-03-01T02:30:00-05:00", "2026-03-08T03:30:00-04:00", "2026-03-15T02:30:00-04:00"} {
+		if got[i].Format(time.RFC3339) != want {
+			t.Errorf("%d: want %s, got %s", i, want, got[i].Format(time.RFC3339))
+		}
+	}
+}
+
+func TestNextInDSTOverlap(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	r := mustParse(t, "FREQ=WEEKLY")
+
+	// 01:30 happens twice on 1 november 2026, the first one counts
+	start := time.Date(2026, 10, 25, 1, 30, 0, 0, newYork)
+	got := occurrences(r, start, 2)
+	if want := "2026-11-01T01:30:00-04:00"; got[1].Format(time.RFC3339) != want {
+		t.Errorf("want %s, got %s", want, got[1].Format(time.RFC3339))
+	}
+}
+
+func TestNextAfterEnd(t *testing.T) {
+	r := mustParse(t, "FREQ=DAILY;COUNT=2")
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	if _, ok := r.Next(start, start.AddDate(0, 0, 1)); ok {
+		t.Error("want the series to be over after two occurrences")
+	}
+}
@@ -0,0 +1,125 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/webhook"
+)
+
+type Notification struct {
+	ID    string     `json:"id"`
+	Kind  string     `json:"kind"`
+	DueAt time.Time  `json:"due_at"`
+	Todo  model.Todo `json:"todo"`
+}
+
+func (n Notification) subject() string {
+	if n.Kind == model.ReminderOverdue {
+		return fmt.Sprintf("Overdue: %s", n.Todo.Title)
+	}
+	return fmt.Sprintf("Due soon: %s", n.Todo.Title)
+}
+
+// Notifier delivers reminders somewhere a person will see them.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (l *LogNotifier) Notify(_ context.Context, n Notification) error {
+	l.log.Info("reminder", slog.Any("reminder", n))
+	return nil
+}
+
+// WebhookNotifier posts reminders to URL, signed like webhook deliveries.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(webhook.Payload{ID: n.ID, Event: "todo." + n.Kind, Time: time.Now(), Data: n})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.EventHeader, "todo."+n.Kind)
+	req.Header.Set(webhook.DeliveryHeader, n.ID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(w.Secret, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// SMTPNotifier mails reminders through the server at Addr. Locally that is
+// the mailpit container, which accepts anything without auth.
+type SMTPNotifier struct {
+	Addr string
+	From string
+	To   []string
+	Auth smtp.Auth
+}
+
+func NewSMTPNotifier(addr, from string, to []string) *SMTPNotifier {
+	return &SMTPNotifier{Addr: addr, From: from, To: to}
+}
+
+func (s *SMTPNotifier) Notify(_ context.Context, n Notification) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", header(n.subject()))
+	fmt.Fprintf(&msg, "Message-ID: <%s@todoapi>\r\n", n.ID)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	due := n.DueAt
+	if loc, err := n.Todo.Location(); err == nil {
+		due = due.In(loc)
+	}
+	fmt.Fprintf(&msg, "%s is due %s.\r\n", n.Todo.Title, due.Format(time.RFC1123))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, []byte(msg.String()))
+}
+
+// header makes v, which carries the title of the todo, safe as the value of
+// a mail header: line breaks would start headers of their own.
+func header(v string) string {
+	v = strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+	return mime.QEncoding.Encode("utf-8", v)
+}
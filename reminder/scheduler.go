@@ -0,0 +1,139 @@
+package reminder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+)
+
+// lease is the name of the lock that elects the replica doing the scans.
+const lease = "reminders"
+
+// Scheduler periodically looks for todos due within Lead or overdue by at
+// most Lookback and sends one reminder of each kind per due date. Only the
+// replica holding the lease scans, and every reminder is claimed in the
+// store before it is sent, so a reminder fires once even when the lease
+// changes hands.
+type Scheduler struct {
+	store     store.ReminderStorer
+	notifiers []Notifier
+
+	Interval  time.Duration
+	Lead      time.Duration
+	Lookback  time.Duration
+	BatchSize int
+
+	holder string
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewScheduler(store store.ReminderStorer, log *slog.Logger, notifiers ...Notifier) *Scheduler {
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		store:     store,
+		notifiers: notifiers,
+		Interval:  30 * time.Second,
+		Lead:      15 * time.Minute,
+		Lookback:  24 * time.Hour,
+		BatchSize: 100,
+		holder:    host + "/" + uuid.New().String(),
+		log:       log,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (s *Scheduler) Serve() error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.scan(time.Now())
+
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops scanning and hands the lease over to the other replicas.
+func (s *Scheduler) Shutdown(context.Context) error {
+	s.cancel()
+	return s.store.ReleaseLease(lease, s.holder, s.detailLog("reminder_shutdown"))
+}
+
+func (s *Scheduler) detailLog(name string) logger.ILogDetail {
+	return logger.New(s.log, name, map[string]any{"route": "reminder", "method": "worker"})
+}
+
+func (s *Scheduler) scan(now time.Time) {
+	log := s.detailLog("reminder_scan")
+
+	// the lease outlives a few missed ticks before another replica takes over
+	ok, err := s.store.AcquireLease(lease, s.holder, 3*s.Interval, log)
+	if err != nil || !ok {
+		return
+	}
+
+	todos, err := s.store.DueTodos(now, now.Add(-s.Lookback), now.Add(s.Lead), s.BatchSize, log)
+	if err != nil {
+		return
+	}
+
+	for i := range todos {
+		if s.ctx.Err() != nil {
+			return
+		}
+		s.remind(&todos[i], now, log)
+	}
+}
+
+func (s *Scheduler) remind(todo *model.Todo, now time.Time, log logger.ILogDetail) {
+	kind := model.ReminderDueSoon
+	if !todo.DueAt.After(now) {
+		kind = model.ReminderOverdue
+	}
+
+	r := &model.Reminder{
+		ID:     reminderID(todo.ID, kind, *todo.DueAt),
+		TodoID: todo.ID,
+		Kind:   kind,
+		DueAt:  *todo.DueAt,
+		SentAt: now,
+	}
+	claimed, err := s.store.ClaimReminder(r, log)
+	if err != nil || !claimed {
+		return
+	}
+
+	n := Notification{ID: r.ID, Kind: kind, DueAt: r.DueAt, Todo: *todo}
+	for _, notifier := range s.notifiers {
+		if err := notifier.Notify(s.ctx, n); err != nil {
+			log.AddError("reminder", "notify", "output", map[string]any{"id": r.ID, "notifier": fmt.Sprintf("%T", notifier)}, err)
+			// try again on the next scan, notifiers that already went
+			// through will repeat
+			s.store.ReleaseReminder(r.ID, log)
+			return
+		}
+	}
+	log.AddOutput("reminder", "notify", map[string]any{"id": r.ID, "todo_id": todo.ID, "kind": kind}).End()
+}
+
+func reminderID(todoID, kind string, due time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", todoID, kind, due.UnixMilli())))
+	return hex.EncodeToString(sum[:16])
+}
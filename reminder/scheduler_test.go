@@ -0,0 +1,272 @@
+package reminder
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newStore(t *testing.T) (*gorm.DB, *store.GormReminderStore) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Todo{}, &model.Reminder{}, &model.Lease{}); err != nil {
+		t.Fatal(err)
+	}
+	return db, store.NewGormReminderStore(db)
+}
+
+func testLog() logger.ILogDetail {
+	return logger.New(slog.Default(), "", nil)
+}
+
+type recorder struct {
+	mu   sync.Mutex
+	sent []Notification
+	fail error
+}
+
+func (r *recorder) Notify(_ context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail != nil {
+		return r.fail
+	}
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func TestLease(t *testing.T) {
+	db, s := newStore(t)
+
+	if ok, _ := s.AcquireLease("job", "a", time.Minute, testLog()); !ok {
+		t.Fatal("want a to take the free lease")
+	}
+	if ok, _ := s.AcquireLease("job", "b", time.Minute, testLog()); ok {
+		t.Fatal("want b kept out while a holds the lease")
+	}
+	if ok, _ := s.AcquireLease("job", "a", time.Hour, testLog()); !ok {
+		t.Fatal("want a to renew its lease")
+	}
+	var lease model.Lease
+	db.Take(&lease, "name = ?", "job")
+	if time.Until(lease.ExpiresAt) < time.Minute {
+		t.Errorf("want the renewal stored, lease expires at %s", lease.ExpiresAt)
+	}
+	if ok, _ := s.AcquireLease("job", "a", -time.Second, testLog()); !ok {
+		t.Fatal("want a to renew its lease")
+	}
+	if ok, _ := s.AcquireLease("job", "b", time.Minute, testLog()); !ok {
+		t.Fatal("want b to take the expired lease")
+	}
+
+	s.ReleaseLease("job", "b", testLog())
+	if ok, _ := s.AcquireLease("job", "a", time.Minute, testLog()); !ok {
+		t.Fatal("want a to take the released lease")
+	}
+}
+
+func TestRemindersFireOnceAcrossReplicas(t *testing.T) {
+	db, s := newStore(t)
+	now := time.Now()
+
+	soon := now.Add(5 * time.Minute)
+	late := now.Add(-time.Hour)
+	later := now.Add(2 * time.Hour)
+	done := now.Add(time.Minute)
+	db.Create(&model.Todo{ID: "soon", Title: "soon", DueAt: &soon})
+	db.Create(&model.Todo{ID: "late", Title: "late", DueAt: &late})
+	db.Create(&model.Todo{ID: "later", Title: "later", DueAt: &later})
+	db.Create(&model.Todo{ID: "done", Title: "done", DueAt: &done, Completed: true})
+
+	sent := &recorder{}
+	a := NewScheduler(s, slog.Default(), sent)
+	b := NewScheduler(s, slog.Default(), sent)
+
+	a.scan(now)
+	b.scan(now)
+	a.Shutdown(context.Background())
+	// b takes over and must not repeat what a sent
+	b.scan(now)
+
+	if len(sent.sent) != 2 {
+		t.Fatalf("want 2 reminders, got %+v", sent.sent)
+	}
+	kinds := map[string]string{}
+	for _, n := range sent.sent {
+		kinds[n.Todo.ID] = n.Kind
+	}
+	if kinds["soon"] != model.ReminderDueSoon || kinds["late"] != model.ReminderOverdue {
+		t.Errorf("unexpected reminders %v", kinds)
+	}
+
+	// once due, soon gets its overdue reminder
+	b.scan(soon.Add(time.Second))
+	if len(sent.sent) != 3 || sent.sent[2].Kind != model.ReminderOverdue {
+		t.Errorf("want an overdue reminder for soon, got %+v", sent.sent)
+	}
+}
+
+func TestScanReachesPastRemindedTodos(t *testing.T) {
+	db, s := newStore(t)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		due := now.Add(time.Duration(i+1) * time.Minute)
+		db.Create(&model.Todo{ID: fmt.Sprint(i), Title: "batch", DueAt: &due})
+	}
+
+	sent := &recorder{}
+	sched := NewScheduler(s, slog.Default(), sent)
+	sched.BatchSize = 2
+
+	for i := 0; i < 3; i++ {
+		sched.scan(now)
+	}
+	if len(sent.sent) != 5 {
+		t.Fatalf("want all 5 todos reminded over 3 scans, got %d", len(sent.sent))
+	}
+
+	// an occurrence already reminded of doesn't come back
+	todos, err := s.DueTodos(now, now.Add(-time.Hour), now.Add(time.Hour), 10, testLog())
+	if err != nil || len(todos) != 0 {
+		t.Errorf("want no due todos left, got %d, %v", len(todos), err)
+	}
+}
+
+func TestFailedReminderIsRetried(t *testing.T) {
+	db, s := newStore(t)
+	now := time.Now()
+	due := now.Add(time.Minute)
+	db.Create(&model.Todo{ID: "1", Title: "retry", DueAt: &due})
+
+	sent := &recorder{fail: errors.New("smtp down")}
+	sched := NewScheduler(s, slog.Default(), sent)
+
+	sched.scan(now)
+	sent.fail = nil
+	sched.scan(now)
+
+	if len(sent.sent) != 1 {
+		t.Errorf("want the reminder sent on the second scan, got %d", len(sent.sent))
+	}
+}
+
+// fakeSMTP accepts one message and hands its data back.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		var body strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					data <- body.String()
+					reply("250 ok")
+					continue
+				}
+				body.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n := NewSMTPNotifier(addr, "todoapi@localhost", []string{"team@localhost"})
+
+	due := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	err := n.Notify(context.Background(), Notification{
+		ID:    "r1",
+		Kind:  model.ReminderOverdue,
+		DueAt: due,
+		Todo:  model.Todo{Title: "file taxes", TimeZone: "Europe/Berlin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-data
+	for _, want := range []string{"Subject: Overdue: file taxes", "To: team@localhost", "09:00:00 CEST"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("want %q in message:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSMTPNotifierEncodesTitle(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n := NewSMTPNotifier(addr, "todoapi@localhost", []string{"team@localhost"})
+
+	err := n.Notify(context.Background(), Notification{
+		ID:    "r1",
+		Kind:  model.ReminderDueSoon,
+		DueAt: time.Now(),
+		Todo:  model.Todo{Title: "taxes\r\nBcc: victim@example.com ✓"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-data
+	headers, _, _ := strings.Cut(msg, "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("want the title kept out of the headers, got:\n%s", headers)
+		}
+	}
+	if !strings.Contains(headers, "Subject: =?utf-8?q?") {
+		t.Errorf("want the subject encoded, got:\n%s", headers)
+	}
+}
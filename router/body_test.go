@@ -0,0 +1,63 @@
+package router
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	echo := func(c IContext) {
+		var body map[string]any
+		if err := c.Bind(&body); err != nil {
+			c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	// a real listener, fiber's Test hands back the error fasthttp answers
+	// 413 for
+	fiberRouter := NewFiberRouter(log)
+	fiberRouter.SetBodyLimit(32)
+	fiberRouter.POST("/echo", echo)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go fiberRouter.Listener(ln)
+	t.Cleanup(func() { fiberRouter.Shutdown() })
+
+	ginRouter := NewMyRouter(log)
+	ginRouter.SetBodyLimit(32)
+	ginRouter.POST("/echo", echo)
+	ginServer := httptest.NewServer(ginRouter)
+	t.Cleanup(ginServer.Close)
+
+	for name, url := range map[string]string{"fiber": "http://" + ln.Addr().String(), "gin": ginServer.URL} {
+		for _, tt := range []struct {
+			body string
+			want int
+		}{
+			{`{"text":"short"}`, http.StatusOK},
+			{`{"text":"` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		} {
+			res, err := http.Post(url+"/echo", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Errorf("%s: %d byte body: want %d, got %d", name, len(tt.body), tt.want, res.StatusCode)
+			}
+		}
+	}
+}
@@ -1,6 +1,29 @@
 package router
 
-import "github.com/sing3demons/todoapi/logger"
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/sing3demons/todoapi/logger"
+)
+
+// UserHeader carries the id of the caller, set by the upstream gateway.
+const UserHeader = "x-user-id"
+
+// DebugHeader set to true logs everything of the request, whatever the log
+// levels. Like the routes under AdminPrefix it needs the admin token.
+const DebugHeader = "x-debug"
+
+// AdminPrefix is where the routes needing the admin token, sent as an
+// Authorization bearer token, are registered.
+const AdminPrefix = "/admin/"
+
+// transactionKey keeps the logger.Transaction of a request in its context.
+const transactionKey = "log_transaction"
 
 type IContext interface {
 	Bind(interface{}) error
@@ -11,4 +34,50 @@ type IContext interface {
 	Param(string) string
 	Query(string) string
 	Incoming() map[string]any
+	Header(string) string
+	SetHeader(key, value string)
+	// UserContext is cancelled once the client goes away.
+	UserContext() context.Context
+	// Stream writes the body incrementally, calling step until it returns
+	// false or the client disconnects. It may return before the body is
+	// written, so step must not depend on the handler still running.
+	Stream(step func(w io.Writer) bool)
+	// FormFile returns the file uploaded in the multipart form field name.
+	FormFile(name string) (*multipart.FileHeader, error)
+	// SendReader answers code with size bytes read from r, closing r once
+	// written when it is an io.Closer.
+	SendReader(code int, contentType string, size int64, r io.Reader)
+}
+
+func debugRequested(v string) bool {
+	debug, _ := strconv.ParseBool(v)
+	return debug
+}
+
+// isAdmin reports whether authorization carries token. Without a token
+// nobody is.
+func isAdmin(token, authorization string) bool {
+	bearer, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// adminPath reports whether path is under AdminPrefix. Fiber matches
+// routes whatever their case, so the prefix is too.
+func adminPath(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), AdminPrefix)
+}
+
+// adminError answers requests needing the admin token that lack it.
+var adminError = map[string]any{
+	"error": "admin token required",
+}
+
+// headers flattens the request headers for Incoming, the logger masks the
+// sensitive ones.
+func headers(h map[string][]string) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
 }
@@ -1,24 +1,35 @@
 package router
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"mime/multipart"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/metrics"
 )
 
 type FiberContext struct {
 	*fiber.Ctx
 }
 
+const (
+	userContext       = "user_context"
+	userContextCancel = "user_context_cancel"
+)
+
 func logSessionID(c *fiber.Ctx, logger *slog.Logger) *slog.Logger {
 	session := string(c.Request().Header.Peek("x-session"))
 	if session == "" {
@@ -37,12 +48,65 @@ func logSessionID(c *fiber.Ctx, logger *slog.Logger) *slog.Logger {
 }
 
 func NewFiberRouter(logger *slog.Logger) *FiberRouter {
-	r := fiber.New()
-	r.Use(func(c *fiber.Ctx) error {
+	r := &FiberRouter{App: fiber.New(fiber.Config{BodyLimit: DefaultBodyLimit})}
+	r.App.Use(func(c *fiber.Ctx) error {
 		c.Locals("logger", logSessionID(c, logger))
-		return c.Next()
+		return summarize(c)
 	})
-	return &FiberRouter{r}
+	r.App.Use(r.checkAdmin)
+	return r
+}
+
+// checkAdmin answers 401 to admin routes and debug requests made without
+// the admin token.
+func (r *FiberRouter) checkAdmin(c *fiber.Ctx) error {
+	debug := debugRequested(c.Get(DebugHeader))
+	if (debug || adminPath(c.Path())) && !isAdmin(r.adminToken, c.Get(fiber.HeaderAuthorization)) {
+		return c.Status(fiber.StatusUnauthorized).JSON(adminError)
+	}
+	if debug {
+		c.Locals(transactionKey).(*logger.Transaction).ForceDebug()
+	}
+	return c.Next()
+}
+
+// SetAdminToken sets the token the admin routes and DebugHeader need.
+// Until it is set they are refused.
+func (r *FiberRouter) SetAdminToken(token string) {
+	r.adminToken = token
+}
+
+// summarize runs the rest of the chain and flushes the log transaction of
+// the request with the status it was answered with. A panic is answered
+// with 500 instead of taking the server down.
+func summarize(c *fiber.Ctx) (err error) {
+	l, _ := c.Locals("logger").(*slog.Logger)
+	tx := logger.NewTransaction(l)
+	c.Locals(transactionKey, tx)
+
+	start := time.Now()
+	method := strings.Clone(c.Method())
+	ctx, span := startTrace(c.UserContext(), fiberCarrier{c}, method, strings.Clone(c.Path()))
+	c.SetUserContext(ctx)
+	tx.SetContext(ctx)
+
+	defer func() {
+		if v := recover(); v != nil {
+			tx.Panic(v)
+			err = fiber.ErrInternalServerError
+		}
+		status := c.Response().StatusCode()
+		var e *fiber.Error
+		if errors.As(err, &e) {
+			status = e.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+		tx.Flush(method, c.Route().Path, status)
+		endTrace(span, method, c.Route().Path, status)
+		metrics.ObserveRequest(method, c.Route().Path, status, time.Since(start))
+	}()
+	return c.Next()
 }
 
 func NewFiberContext(c *fiber.Ctx) *FiberContext {
@@ -54,7 +118,7 @@ func (c *FiberContext) Bind(v any) error {
 }
 
 func (c *FiberContext) JSON(code int, v any) {
-	c.Ctx.JSON(v)
+	c.Ctx.Status(code).JSON(v)
 }
 
 func (c *FiberContext) Query(key string) string {
@@ -80,6 +144,8 @@ func (c *FiberContext) Incoming() map[string]any {
 		data["body"] = body
 	}
 
+	data["headers"] = headers(c.Ctx.GetReqHeaders())
+
 	return data
 }
 
@@ -99,8 +165,13 @@ func (c *FiberContext) Log(name string) logger.ILogDetail {
 		"method":   method,
 		"device":   device,
 		"instance": instance,
+		"session":  c.Ctx.Locals("session"),
+		"user_id":  c.Ctx.Get(UserHeader),
 	}
 
+	if tx, ok := c.Ctx.Locals(transactionKey).(*logger.Transaction); ok {
+		return tx.Logger(name, attribute)
+	}
 	switch l := c.Ctx.Locals("logger").(type) {
 	case *slog.Logger:
 		return logger.New(l, name, attribute)
@@ -121,6 +192,54 @@ func (c *FiberContext) Param(key string) string {
 	return c.Ctx.Params(key)
 }
 
+func (c *FiberContext) Header(key string) string {
+	return c.Ctx.Get(key)
+}
+
+func (c *FiberContext) SetHeader(key, value string) {
+	c.Ctx.Set(key, value)
+}
+
+// UserContext is cancelled when a stream started with Stream ends, fasthttp
+// gives no other signal that the client went away.
+func (c *FiberContext) UserContext() context.Context {
+	if ctx, ok := c.Ctx.Locals(userContext).(context.Context); ok {
+		return ctx
+	}
+
+	ctx, cancel := context.WithCancel(c.Ctx.UserContext())
+	c.Ctx.Locals(userContext, ctx)
+	c.Ctx.Locals(userContextCancel, cancel)
+	return ctx
+}
+
+func (c *FiberContext) Stream(step func(w io.Writer) bool) {
+	c.UserContext()
+	cancel := c.Ctx.Locals(userContextCancel).(context.CancelFunc)
+
+	c.Ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		for step(w) {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	})
+}
+
+func (c *FiberContext) FormFile(name string) (*multipart.FileHeader, error) {
+	return c.Ctx.FormFile(name)
+}
+
+// SendReader leaves r to fasthttp, which reads it after the handler
+// returns and closes it.
+func (c *FiberContext) SendReader(code int, contentType string, size int64, r io.Reader) {
+	c.Ctx.Status(code)
+	c.Ctx.Set(fiber.HeaderContentType, contentType)
+	c.Ctx.Context().SetBodyStream(r, int(size))
+}
+
 func NewFiberHandler(handler func(IContext)) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		handler(NewFiberContext(c))
@@ -130,6 +249,28 @@ func NewFiberHandler(handler func(IContext)) fiber.Handler {
 
 type FiberRouter struct {
 	*fiber.App
+	servers []Server
+	hooks   []func()
+	origins []string
+
+	adminToken string
+}
+
+// Register adds a server that is started and stopped together with Run.
+func (r *FiberRouter) Register(s Server) {
+	r.servers = append(r.servers, s)
+}
+
+// SetBodyLimit sets the largest request body read, larger ones are
+// answered with 413.
+func (r *FiberRouter) SetBodyLimit(n int64) {
+	r.App.Server().MaxRequestBodySize = int(n)
+}
+
+// OnShutdown adds f to what Run calls once the shutdown signal is received,
+// before it stops taking requests.
+func (r *FiberRouter) OnShutdown(f func()) {
+	r.hooks = append(r.hooks, f)
 }
 
 // func (r *FiberRouter) Run(addr string) error {
@@ -194,13 +335,16 @@ func (r *FiberRouter) Run() {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
+	serve(r.servers)
 
 	<-ctx.Done()
 	stop()
 
 	fmt.Println("shutting down gracefully, press Ctrl+C again to force")
+	draining(r.hooks)
 
 	if err := r.Shutdown(); err != nil {
 		fmt.Println(err)
 	}
+	shutdown(r.servers)
 }
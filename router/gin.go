@@ -3,7 +3,9 @@ package router
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,8 +13,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/metrics"
 	"github.com/sing3demons/todoapi/mlog"
 )
 
@@ -24,8 +29,10 @@ func NewMyContext(c *gin.Context) *MyContext {
 	return &MyContext{c}
 }
 
+// Bind reads the body cached by Incoming, the request body itself can only
+// be read once.
 func (c *MyContext) Bind(v any) error {
-	return c.ShouldBindJSON(v)
+	return c.ShouldBindBodyWith(v, binding.JSON)
 }
 
 func (c *MyContext) JSON(code int, v any) {
@@ -47,6 +54,11 @@ func (c *MyContext) Log(name string) logger.ILogDetail {
 		"method":   method,
 		"device":   device,
 		"instants": instance,
+		"session":  c.GetString(mlog.Session),
+		"user_id":  c.GetHeader(UserHeader),
+	}
+	if tx, ok := c.Value(transactionKey).(*logger.Transaction); ok {
+		return tx.Logger(name, attribute)
 	}
 	switch l := c.Value("logger").(type) {
 	case *slog.Logger:
@@ -59,7 +71,11 @@ func (c *MyContext) Log(name string) logger.ILogDetail {
 func (c *MyContext) Incoming() map[string]any {
 	var data = make(map[string]any)
 	body := make(map[string]any)
-	c.Context.BindJSON(&body)
+	// only JSON bodies are logged, BindJSON would answer 400 for anything
+	// else and reading a multipart body would leave nothing for FormFile
+	if c.ContentType() == binding.MIMEJSON {
+		c.Context.ShouldBindBodyWith(&body, binding.JSON)
+	}
 	params := c.Context.Keys
 	query := c.Context.Request.URL.Query()
 
@@ -75,6 +91,8 @@ func (c *MyContext) Incoming() map[string]any {
 		data["body"] = body
 	}
 
+	data["headers"] = headers(c.Request.Header)
+
 	return data
 }
 
@@ -95,6 +113,41 @@ func (c *MyContext) Query(key string) string {
 	return c.Context.Query(key)
 }
 
+func (c *MyContext) Header(key string) string {
+	return c.Context.GetHeader(key)
+}
+
+func (c *MyContext) SetHeader(key, value string) {
+	c.Context.Header(key, value)
+}
+
+func (c *MyContext) UserContext() context.Context {
+	return c.Request.Context()
+}
+
+func (c *MyContext) Stream(step func(w io.Writer) bool) {
+	noWriteDeadline(c.Writer)
+	c.Context.Stream(step)
+}
+
+func (c *MyContext) FormFile(name string) (*multipart.FileHeader, error) {
+	return c.Context.FormFile(name)
+}
+
+func (c *MyContext) SendReader(code int, contentType string, size int64, r io.Reader) {
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	noWriteDeadline(c.Writer)
+	c.Context.DataFromReader(code, size, contentType, r, nil)
+}
+
+// noWriteDeadline lifts the WriteTimeout of the server for a response that
+// takes as long as the client keeps reading it.
+func noWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
 func NewGinHandler(handler func(IContext)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		handler(NewMyContext(c))
@@ -103,13 +156,99 @@ func NewGinHandler(handler func(IContext)) gin.HandlerFunc {
 
 type MyRouter struct {
 	*gin.Engine
+	servers []Server
+	hooks   []func()
+	origins []string
+
+	bodyLimit  int64
+	adminToken string
+}
+
+// Register adds a server that is started and stopped together with Run.
+func (r *MyRouter) Register(s Server) {
+	r.servers = append(r.servers, s)
+}
+
+// checkAdmin answers 401 to admin routes and debug requests made without
+// the admin token.
+func (r *MyRouter) checkAdmin(c *gin.Context) {
+	debug := debugRequested(c.GetHeader(DebugHeader))
+	if (debug || adminPath(c.Request.URL.Path)) && !isAdmin(r.adminToken, c.GetHeader("Authorization")) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, adminError)
+		return
+	}
+	if debug {
+		c.MustGet(transactionKey).(*logger.Transaction).ForceDebug()
+	}
+	c.Next()
+}
+
+// SetAdminToken sets the token the admin routes and DebugHeader need.
+// Until it is set they are refused.
+func (r *MyRouter) SetAdminToken(token string) {
+	r.adminToken = token
+}
+
+// SetBodyLimit sets the largest request body read, larger ones are
+// answered with 413.
+func (r *MyRouter) SetBodyLimit(n int64) {
+	r.bodyLimit = n
+}
+
+// limitBody answers 413 to a body declared over the limit and stops
+// reading one that turns out to be.
+func (r *MyRouter) limitBody(c *gin.Context) {
+	if c.Request.ContentLength > r.bodyLimit {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, map[string]any{
+			"error": "request body too large",
+		})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, r.bodyLimit)
+	c.Next()
+}
+
+// OnShutdown adds f to what Run calls once the shutdown signal is received,
+// before it stops taking requests.
+func (r *MyRouter) OnShutdown(f func()) {
+	r.hooks = append(r.hooks, f)
+}
+
+// summarizeGin flushes the log transaction of the request once the rest
+// of the chain has answered it. A panic is logged and passed on to
+// gin.Recovery, which answers 500.
+func summarizeGin(c *gin.Context) {
+	tx := logger.NewTransaction(mlog.L(c))
+	c.Set(transactionKey, tx)
+
+	start := time.Now()
+	ctx, span := startTrace(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header), c.Request.Method, c.Request.URL.Path)
+	c.Request = c.Request.WithContext(ctx)
+	tx.SetContext(ctx)
+
+	defer func() {
+		if v := recover(); v != nil {
+			tx.Panic(v)
+			tx.Flush(c.Request.Method, c.FullPath(), http.StatusInternalServerError)
+			endTrace(span, c.Request.Method, c.FullPath(), http.StatusInternalServerError)
+			metrics.ObserveRequest(c.Request.Method, c.FullPath(), http.StatusInternalServerError, time.Since(start))
+			panic(v)
+		}
+		tx.Flush(c.Request.Method, c.FullPath(), c.Writer.Status())
+		endTrace(span, c.Request.Method, c.FullPath(), c.Writer.Status())
+		metrics.ObserveRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}()
+	c.Next()
 }
 
 func NewMyRouter(logger *slog.Logger) *MyRouter {
-	r := gin.New()
+	r := &MyRouter{Engine: gin.New(), bodyLimit: DefaultBodyLimit}
 	r.Use(gin.Recovery())
 	r.Use(mlog.Middleware(logger))
-	return &MyRouter{r}
+	r.Use(summarizeGin)
+	r.Use(r.checkAdmin)
+	r.Use(r.limitBody)
+	return r
 }
 
 func (r *MyRouter) GET(path string, handler func(IContext)) {
@@ -151,10 +290,12 @@ func (r *MyRouter) Run() error {
 			os.Exit(1)
 		}
 	}()
+	serve(r.servers)
 
 	<-ctx.Done()
 	stop()
 	fmt.Println("shutting down gracefully, press Ctrl+C again to force")
+	draining(r.hooks)
 
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -162,6 +303,7 @@ func (r *MyRouter) Run() error {
 	if err := s.Shutdown(timeoutCtx); err != nil {
 		fmt.Println(err)
 	}
+	shutdown(r.servers)
 
 	return nil
 }
@@ -0,0 +1,246 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/todoapi/logger"
+)
+
+// lines returns the lines of logName written to buf.
+func lines(t *testing.T, buf *bytes.Buffer, logName string) []map[string]any {
+	var out []map[string]any
+	d := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for d.More() {
+		var line map[string]any
+		if err := d.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		if line["log_name"] == logName {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+type adapter struct {
+	name string
+	// do serves req with handler on POST /todo/:id, logging to buf
+	do func(buf *bytes.Buffer, req *http.Request) int
+}
+
+func adapters(t *testing.T, handler func(IContext)) []adapter {
+	gin.SetMode(gin.TestMode)
+	return []adapter{
+		{"fiber", func(buf *bytes.Buffer, req *http.Request) int {
+			r := NewFiberRouter(slog.New(logger.NewHandler(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), logger.HTTP)))
+			r.SetAdminToken("secret")
+			r.POST("/todo/:id", handler)
+			res, err := r.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.StatusCode
+		}},
+		{"gin", func(buf *bytes.Buffer, req *http.Request) int {
+			r := NewMyRouter(slog.New(logger.NewHandler(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), logger.HTTP)))
+			r.SetAdminToken("secret")
+			r.POST("/todo/:id", handler)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}},
+	}
+}
+
+func TestSummary(t *testing.T) {
+	handler := func(c IContext) {
+		l := c.Log("create")
+		l.AddInput("client", "create", c.Incoming())
+		l.AddOutput("mongo", "insert", nil).End()
+		l.AddInput("mongo", "insert", nil)
+		l.AddOutput("mongo", "insert", nil).End()
+		l.AddError("mongo", "insert", "input", nil, errors.New("duplicate key"))
+		// a second logger of the same request counts into the same summary
+		c.Log("notify").AddOutput("webhook", "post", nil).End()
+		l.Summary().SetResult("40901", "duplicate")
+		c.JSON(http.StatusConflict, map[string]any{"error": "duplicate key"})
+	}
+
+	for _, adapter := range adapters(t, handler) {
+		t.Run(adapter.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			req := httptest.NewRequest(http.MethodPost, "/todo/1", nil)
+			req.Header.Set("x-session", "s-1")
+			adapter.do(&buf, req)
+
+			lines := lines(t, &buf, "SUMMARY")
+			if len(lines) != 1 {
+				t.Fatalf("want one summary, got %v", lines)
+			}
+			line := lines[0]
+			for key, want := range map[string]any{
+				"session":         "s-1",
+				"method":          "POST",
+				"route":           "/todo/:id",
+				"result_code":     float64(http.StatusConflict),
+				"app_result_code": "40901",
+				"app_result_desc": "duplicate",
+			} {
+				if line[key] != want {
+					t.Errorf("%s: want %v, got %v", key, want, line[key])
+				}
+			}
+
+			b, _ := json.Marshal(line["nodes"])
+			var nodes []logger.NodeSummary
+			json.Unmarshal(b, &nodes)
+			want := []logger.NodeSummary{
+				{Node: "mongo", Cmd: "insert", Count: 2, Success: 1, Failed: 1},
+				{Node: "webhook", Cmd: "post", Count: 1},
+			}
+			if !reflect.DeepEqual(nodes, want) {
+				t.Errorf("want %+v, got %+v", want, nodes)
+			}
+		})
+	}
+}
+
+func TestSummaryStatus(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewFiberRouter(slog.New(slog.NewJSONHandler(&buf, nil)))
+	r.GET("/ping", func(c IContext) { c.JSON(http.StatusOK, "pong") })
+
+	r.Test(httptest.NewRequest(http.MethodGet, "/missing", nil), -1)
+	lines := lines(t, &buf, "SUMMARY")
+	if len(lines) != 1 || lines[0]["result_code"] != float64(http.StatusNotFound) || lines[0]["app_result_desc"] != "Not Found" {
+		t.Errorf("want a 404 summary for an unknown route, got %v", lines)
+	}
+}
+
+func TestDetail(t *testing.T) {
+	handler := func(c IContext) {
+		l := c.Log("create")
+		l.AddInput("client", "create", map[string]any{"id": c.Param("id")})
+		l.AddOutput("mongo", "find", nil).End()
+		l.AddError("mongo", "find", "input", nil, errors.New("not found"))
+
+		span := l.StartSpan("notify")
+		span.AddOutput("webhook", "post", nil).End()
+		inner := span.StartSpan("retry")
+		inner.AddInput("webhook", "post", nil)
+		inner.Finish()
+		span.Finish()
+		// never finished, closed by the flush
+		l.StartSpan("cleanup")
+
+		if c.Query("panic") != "" {
+			panic("boom")
+		}
+		c.Log("respond").AddOutput("client", "create", "ok").End()
+		c.JSON(http.StatusCreated, "ok")
+	}
+
+	for _, adapter := range adapters(t, handler) {
+		for _, panics := range []bool{false, true} {
+			var buf bytes.Buffer
+			target := "/todo/1"
+			if panics {
+				target += "?panic=1"
+			}
+			status := adapter.do(&buf, httptest.NewRequest(http.MethodPost, target, nil))
+
+			name := adapter.name
+			want := http.StatusCreated
+			wantEvents := []string{"client.create", "mongo.find", "mongo.find", "webhook.post", "webhook.post", "client.create"}
+			if panics {
+				name += " panic"
+				want = http.StatusInternalServerError
+				wantEvents = append(wantEvents[:5], "panic")
+			}
+			if status != want {
+				t.Errorf("%s: want %d, got %d", name, want, status)
+			}
+
+			details := lines(t, &buf, "DETAIL")
+			if len(details) != 1 {
+				t.Fatalf("%s: want one DETAIL line, got %d", name, len(details))
+			}
+			detail := details[0]
+			if attribute, _ := detail["attribute"].(map[string]any); attribute["route"] != "/todo/:id" {
+				t.Errorf("%s: want the route in the attributes, got %v", name, detail["attribute"])
+			}
+
+			var got []string
+			var spans []string
+			for _, e := range detail["events"].([]any) {
+				e := e.(map[string]any)
+				got = append(got, e["name"].(string))
+				if span, ok := e["span"].(string); ok {
+					spans = append(spans, span)
+				}
+			}
+			if !reflect.DeepEqual(got, wantEvents) {
+				t.Errorf("%s: want events %v, got %v", name, wantEvents, got)
+			}
+			if !reflect.DeepEqual(spans, []string{"notify", "notify/retry"}) {
+				t.Errorf("%s: want events marked with their span, got %v", name, spans)
+			}
+
+			var timings []logger.SpanTiming
+			b, _ := json.Marshal(detail["spans"])
+			json.Unmarshal(b, &timings)
+			if len(timings) != 3 || timings[0].Name != "notify" || timings[1].Name != "notify/retry" || timings[0].ProcessTime < timings[1].ProcessTime {
+				t.Errorf("%s: want the notify and retry spans timed, got %+v", name, timings)
+			} else if !timings[2].Unfinished || timings[0].Unfinished {
+				t.Errorf("%s: want only cleanup unfinished, got %+v", name, timings)
+			}
+
+			summaries := lines(t, &buf, "SUMMARY")
+			if len(summaries) != 1 || summaries[0]["result_code"] != float64(want) {
+				t.Errorf("%s: want one summary with %d, got %v", name, want, summaries)
+			}
+		}
+	}
+}
+
+func TestDebugHeader(t *testing.T) {
+	handler := func(c IContext) {
+		c.Log("debug").Debug("staging")
+		c.JSON(http.StatusOK, "ok")
+	}
+
+	for _, adapter := range adapters(t, handler) {
+		for _, tt := range []struct {
+			debug         bool
+			authorization string
+			want          int
+		}{
+			{false, "", http.StatusOK},
+			{true, "Bearer secret", http.StatusOK},
+			{true, "", http.StatusUnauthorized},
+			{true, "Bearer guess", http.StatusUnauthorized},
+		} {
+			var buf bytes.Buffer
+			req := httptest.NewRequest(http.MethodPost, "/todo/1", nil)
+			if tt.debug {
+				req.Header.Set(DebugHeader, "true")
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			code := adapter.do(&buf, req)
+			logged := bytes.Contains(buf.Bytes(), []byte(`"msg":"staging"`))
+			if code != tt.want || logged != (tt.want == http.StatusOK && tt.debug) {
+				t.Errorf("%s with %s=%v and %q: got %d, debug logged %v", adapter.name, DebugHeader, tt.debug, tt.authorization, code, logged)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/sing3demons/todoapi/openapi"
+)
+
+const openapiNode = "openapi"
+
+// UseOpenAPI validates every request described by the document and answers
+// 400 on contract violations. Must be called before registering routes.
+func (r *FiberRouter) UseOpenAPI(v *openapi.Validator) {
+	r.App.Use(func(c *fiber.Ctx) error {
+		req, err := adaptor.ConvertRequest(c, false)
+		if err != nil {
+			return c.Next()
+		}
+
+		input, err := v.ValidateRequest(req)
+		if err != nil {
+			ctx := NewFiberContext(c)
+			ctx.Log("openapi_validation").AddError(openapiNode, "validate_request", "input", ctx.Incoming(), err)
+			return c.Status(http.StatusBadRequest).JSON(openapi.BadRequest(err))
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if input == nil || !v.Debug() {
+			return nil
+		}
+
+		header := http.Header{}
+		c.Response().Header.VisitAll(func(key, value []byte) {
+			header.Add(string(key), string(value))
+		})
+		body := c.Response().Body()
+		if err := v.ValidateResponse(input, c.Response().StatusCode(), header, body); err != nil {
+			NewFiberContext(c).Log("openapi_validation").AddError(openapiNode, "validate_response", "output", map[string]any{
+				"status":     c.Response().StatusCode(),
+				"body":       string(body),
+				"violations": openapi.Violations(err),
+			}, err)
+		}
+		return nil
+	})
+}
+
+type bodyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// UseOpenAPI validates every request described by the document and answers
+// 400 on contract violations. Must be called before registering routes.
+func (r *MyRouter) UseOpenAPI(v *openapi.Validator) {
+	r.Engine.Use(func(c *gin.Context) {
+		input, err := v.ValidateRequest(c.Request)
+		if err != nil {
+			ctx := NewMyContext(c)
+			ctx.Log("openapi_validation").AddError(openapiNode, "validate_request", "input", ctx.Incoming(), err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, openapi.BadRequest(err))
+			return
+		}
+
+		if input == nil || !v.Debug() {
+			c.Next()
+			return
+		}
+
+		w := &bodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+		c.Next()
+
+		if err := v.ValidateResponse(input, w.Status(), w.Header(), w.body.Bytes()); err != nil {
+			NewMyContext(c).Log("openapi_validation").AddError(openapiNode, "validate_response", "output", map[string]any{
+				"status":     w.Status(),
+				"body":       w.body.String(),
+				"violations": openapi.Violations(err),
+			}, err)
+		}
+	})
+}
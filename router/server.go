@@ -0,0 +1,47 @@
+package router
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Server is a long running component served next to the http router, it is
+// started by Run and stopped on the same shutdown signal.
+type Server interface {
+	Serve() error
+	Shutdown(ctx context.Context) error
+}
+
+// DefaultBodyLimit is the largest request body the routers read until
+// SetBodyLimit is called.
+const DefaultBodyLimit = 16 << 20
+
+// draining runs the functions given to OnShutdown, before the router stops
+// taking requests.
+func draining(hooks []func()) {
+	for _, f := range hooks {
+		f()
+	}
+}
+
+func serve(servers []Server) {
+	for _, s := range servers {
+		go func(s Server) {
+			if err := s.Serve(); err != nil {
+				slog.Error("serve", slog.Any("error", err))
+			}
+		}(s)
+	}
+}
+
+func shutdown(servers []Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			slog.Error("shutdown", slog.Any("error", err))
+		}
+	}
+}
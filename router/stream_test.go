@@ -0,0 +1,61 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamOutlivesWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewMyRouter(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	r.GET("/stream", func(c IContext) {
+		n := 0
+		c.Stream(func(w io.Writer) bool {
+			time.Sleep(20 * time.Millisecond)
+			n++
+			fmt.Fprintf(w, "%d\n", n)
+			return n < 10
+		})
+	})
+	r.GET("/download", func(c IContext) {
+		c.SendReader(http.StatusOK, "text/plain", 10, slowReader{strings.NewReader("0123456789")})
+	})
+
+	s := httptest.NewUnstartedServer(r)
+	s.Config.WriteTimeout = 50 * time.Millisecond
+	s.Start()
+	t.Cleanup(s.Close)
+
+	for path, want := range map[string]string{
+		"/stream":   "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"/download": "0123456789",
+	} {
+		res, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || string(body) != want {
+			t.Errorf("%s: want the whole body past the write timeout, got %q, %v", path, body, err)
+		}
+	}
+}
+
+// slowReader reads a byte at a time, taking its time.
+type slowReader struct {
+	r io.Reader
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.r.Read(p[:1])
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every use, the global provider only hands a
+// tracer taken earlier to the first provider set.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/sing3demons/todoapi/router")
+}
+
+// startTrace starts the span of a request, continuing the trace of its
+// traceparent header if any. The route is only known once the request is
+// routed, endTrace names the span after it.
+func startTrace(ctx context.Context, carrier propagation.TextMapCarrier, method, path string) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.URLPath(path)))
+}
+
+func endTrace(span trace.Span, method, route string, status int) {
+	if route != "" {
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+	span.End()
+}
+
+// fiberCarrier reads the trace headers of a fiber request.
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+func (f fiberCarrier) Get(key string) string {
+	// the value outlives the request in the trace state
+	return strings.Clone(f.c.Get(key))
+}
+
+func (f fiberCarrier) Set(key, value string) {
+	f.c.Request().Header.Set(key, value)
+}
+
+func (f fiberCarrier) Keys() []string {
+	var keys []string
+	f.c.Request().Header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sing3demons/todoapi/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// traced records the spans of the test in memory.
+func traced(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider("todoapi", sdktrace.WithSyncer(exp))
+	prev, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exp
+}
+
+func TestTrace(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00f067aa0ba902b7"
+	)
+	handler := func(c IContext) {
+		l := c.Log("find")
+		l.AddInput("client", "find", c.Incoming())
+		l.AddOutput("mongo", "find_todo", nil).End()
+		l.AddInput("mongo", "find_todo", nil)
+		s := l.StartSpan("notify")
+		s.AddOutput("webhook", "post", nil).End()
+		s.AddError("webhook", "post", "input", nil, errors.New("timeout"))
+		s.Finish()
+		c.JSON(http.StatusOK, nil)
+	}
+
+	for _, adapter := range adapters(t, handler) {
+		t.Run(adapter.name, func(t *testing.T) {
+			exp := traced(t)
+			var buf bytes.Buffer
+			req := httptest.NewRequest(http.MethodPost, "/todo/1", nil)
+			req.Header.Set("traceparent", "00-"+traceID+"-"+parent+"-01")
+			adapter.do(&buf, req)
+
+			spans := map[string]tracetest.SpanStub{}
+			for _, s := range exp.GetSpans() {
+				spans[s.Name] = s
+			}
+			server, ok := spans["POST /todo/:id"]
+			if !ok {
+				t.Fatalf("want the span of the request, got %v", exp.GetSpans())
+			}
+			if server.SpanContext.TraceID().String() != traceID || server.Parent.SpanID().String() != parent {
+				t.Errorf("want the trace of traceparent continued, got %v under %v", server.SpanContext, server.Parent)
+			}
+			mongo, notify, webhook := spans["mongo.find_todo"], spans["notify"], spans["webhook.post"]
+			if mongo.Parent.SpanID() != server.SpanContext.SpanID() || notify.Parent.SpanID() != server.SpanContext.SpanID() {
+				t.Errorf("want the store call and the span children of the request")
+			}
+			if webhook.Parent.SpanID() != notify.SpanContext.SpanID() || webhook.Status.Code != codes.Error {
+				t.Errorf("want the failed call a child of its span, got %+v", webhook)
+			}
+			if len(spans) != 4 {
+				t.Errorf("want 4 spans, got %d", len(spans))
+			}
+
+			detail := lines(t, &buf, "DETAIL")
+			summary := lines(t, &buf, "SUMMARY")
+			if len(detail) != 1 || len(summary) != 1 {
+				t.Fatalf("want a DETAIL and a SUMMARY line, got %d and %d", len(detail), len(summary))
+			}
+			for _, line := range []map[string]any{detail[0], summary[0]} {
+				if line["trace_id"] != traceID || line["span_id"] != server.SpanContext.SpanID().String() {
+					t.Errorf("want the ids of the request span, got %v %v", line["trace_id"], line["span_id"])
+				}
+			}
+			events := detail[0]["events"].([]any)
+			if id := events[1].(map[string]any)["span_id"]; id != mongo.SpanContext.SpanID().String() {
+				t.Errorf("want the store call marked with its span, got %v", id)
+			}
+		})
+	}
+}
+
+func TestTraceWithoutProvider(t *testing.T) {
+	// without a provider the trace of the caller still reaches the log
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	handler := func(c IContext) {
+		c.Log("find").AddOutput("mongo", "find_todo", nil).End()
+		c.JSON(http.StatusOK, nil)
+	}
+	for _, adapter := range adapters(t, handler) {
+		t.Run(adapter.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			req := httptest.NewRequest(http.MethodPost, "/todo/1", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			adapter.do(&buf, req)
+			if detail := lines(t, &buf, "DETAIL"); len(detail) != 1 || detail[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("want the trace id of the caller, got %v", detail)
+			}
+		})
+	}
+}
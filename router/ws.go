@@ -0,0 +1,141 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	fiberws "github.com/gofiber/contrib/websocket"
+	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+	"github.com/sing3demons/todoapi/logger"
+)
+
+// WSConn is the websocket connection handed to handlers registered with WS.
+type WSConn interface {
+	Log(name string) logger.ILogDetail
+	Param(string) string
+	Query(string) string
+	Header(string) string
+	ReadJSON(v any) error
+	WriteJSON(v any) error
+	Close() error
+}
+
+type fiberWSConn struct {
+	*fiberws.Conn
+	route string
+}
+
+func (c *fiberWSConn) Log(name string) logger.ILogDetail {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = c.Conn.IP()
+	}
+
+	attribute := map[string]any{
+		"route":    c.route,
+		"method":   "WS",
+		"device":   c.Header("User-Agent"),
+		"instance": instance,
+	}
+
+	switch l := c.Conn.Locals("logger").(type) {
+	case *slog.Logger:
+		return logger.New(l, name, attribute)
+	default:
+		return logger.New(slog.Default(), name, attribute)
+	}
+}
+
+func (c *fiberWSConn) Param(key string) string {
+	return c.Conn.Params(key)
+}
+
+func (c *fiberWSConn) Query(key string) string {
+	return c.Conn.Query(key)
+}
+
+func (c *fiberWSConn) Header(key string) string {
+	return c.Conn.Headers(textproto.CanonicalMIMEHeaderKey(key))
+}
+
+// originAllowed reports whether a websocket may be opened from origin.
+// Clients other than browsers send none, browsers may connect from the
+// service's own host or one of the allowed origins.
+func originAllowed(origin, host string, allowed []string) bool {
+	if origin == "" || slices.Contains(allowed, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+// AllowOrigins lets browsers on origins, e.g. https://app.example.com, open
+// the websockets registered with WS.
+func (r *FiberRouter) AllowOrigins(origins ...string) {
+	r.origins = append(r.origins, origins...)
+}
+
+// WS upgrades requests on path to a websocket handled by h.
+func (r *FiberRouter) WS(path string, h func(WSConn)) {
+	r.App.Get(path, func(c *fiber.Ctx) error {
+		if !fiberws.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		if !originAllowed(c.Get(fiber.HeaderOrigin), string(c.Request().Host()), r.origins) {
+			return fiber.ErrForbidden
+		}
+		return c.Next()
+	}, fiberws.New(func(c *fiberws.Conn) {
+		h(&fiberWSConn{Conn: c, route: path})
+	}))
+}
+
+type ginWSConn struct {
+	*websocket.Conn
+	ctx *MyContext
+}
+
+func (c *ginWSConn) Log(name string) logger.ILogDetail {
+	return c.ctx.Log(name)
+}
+
+func (c *ginWSConn) Param(key string) string {
+	return c.ctx.Param(key)
+}
+
+func (c *ginWSConn) Query(key string) string {
+	return c.ctx.Query(key)
+}
+
+func (c *ginWSConn) Header(key string) string {
+	return c.ctx.Header(key)
+}
+
+// AllowOrigins lets browsers on origins, e.g. https://app.example.com, open
+// the websockets registered with WS.
+func (r *MyRouter) AllowOrigins(origins ...string) {
+	r.origins = append(r.origins, origins...)
+}
+
+// WS upgrades requests on path to a websocket handled by h.
+func (r *MyRouter) WS(path string, h func(WSConn)) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(req *http.Request) bool {
+			return originAllowed(req.Header.Get("Origin"), req.Host, r.origins)
+		},
+	}
+	r.Engine.GET(path, func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		h(&ginWSConn{Conn: conn, ctx: NewMyContext(c)})
+	})
+}
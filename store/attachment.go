@@ -0,0 +1,27 @@
+package store
+
+import (
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// AttachmentStorer keeps the attachment metadata of todos, the content is
+// written to a blob store by the caller.
+type AttachmentStorer interface {
+	AddAttachment(todoID string, attachment *model.Attachment, logger logger.ILogDetail) error
+	// RemoveAttachment fails with a not found error when the todo has no
+	// such attachment.
+	RemoveAttachment(todoID, attachmentID string, logger logger.ILogDetail) (*model.Attachment, error)
+}
+
+// withoutAttachment returns attachments less the one with id, nil when
+// there is none.
+func withoutAttachment(attachments []model.Attachment, id string) ([]model.Attachment, *model.Attachment) {
+	for i, a := range attachments {
+		if a.ID == id {
+			rest := append(append([]model.Attachment{}, attachments[:i]...), attachments[i+1:]...)
+			return rest, &a
+		}
+	}
+	return attachments, nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// AuditStorer reads the audit trail. Entries are written by the todo
+// stores, in the transaction of the change.
+type AuditStorer interface {
+	// History returns the entries of a todo, newest first.
+	History(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.AuditEntry, error)
+}
+
+func newAuditEntry(action string, before, after *model.Todo, actor logger.Actor) *model.AuditEntry {
+	todoID := ""
+	if after != nil {
+		todoID = after.ID
+	} else if before != nil {
+		todoID = before.ID
+	}
+	return &model.AuditEntry{
+		ID:        uuid.New().String(),
+		TodoID:    todoID,
+		Action:    action,
+		Session:   actor.Session,
+		UserID:    actor.UserID,
+		Before:    before,
+		After:     after,
+		Diff:      model.TodoDiff(before, after),
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// CommentStorer keeps the discussion of todos. Writing a comment that
+// mentions users also writes a TodoMentioned outbox entry for them, in the
+// same transaction.
+type CommentStorer interface {
+	CreateComment(comment *model.Comment, logger logger.ILogDetail) error
+	// Comments returns the comments of a todo, oldest first.
+	Comments(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.Comment, error)
+	FindComment(todoID, id string, logger logger.ILogDetail) (*model.Comment, error)
+	// UpdateComment saves the body of comment. Only the users it didn't
+	// mention before are notified.
+	UpdateComment(comment *model.Comment, logger logger.ILogDetail) error
+	DeleteComment(todoID, id string, logger logger.ILogDetail) error
+}
+
+// newMentions returns the users in after that aren't in before.
+func newMentions(before, after []string) []string {
+	var users []string
+	for _, user := range after {
+		if !slices.Contains(before, user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// newMentionEntry is the outbox entry notifying users of comment.
+func newMentionEntry(todo *model.Todo, comment model.Comment, users []string) *model.OutboxEntry {
+	entry := newOutboxEntry(model.TodoMentioned, todo)
+	comment.Mentions = users
+	entry.Payload = model.MentionPayload(*todo, comment)
+	return entry
+}
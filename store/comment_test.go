@@ -0,0 +1,71 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+)
+
+func TestComments(t *testing.T) {
+	s, log := newTestStore(t)
+	todo := &model.Todo{Title: "release"}
+	s.Create(todo, log)
+
+	mentions := func() [][]string {
+		var entries []model.OutboxEntry
+		s.db.Where("event = ?", model.TodoMentioned).Order("created_at").Find(&entries)
+		var out [][]string
+		for _, e := range entries {
+			comment, err := e.Comment()
+			if err != nil || comment == nil {
+				t.Fatalf("want a comment in the mention entry, got %v %v", comment, err)
+			}
+			out = append(out, comment.Mentions)
+		}
+		return out
+	}
+
+	first := &model.Comment{TodoID: todo.ID, UserID: "alice", Body: "@bob please review", Mentions: []string{"bob"}}
+	if err := s.CreateComment(first, log); err != nil {
+		t.Fatal(err)
+	}
+	second := &model.Comment{TodoID: todo.ID, UserID: "bob", Body: "on it"}
+	s.CreateComment(second, log)
+	if err := s.CreateComment(&model.Comment{TodoID: "missing", Body: "lost"}, log); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("want commenting a missing todo to fail, got %v", err)
+	}
+
+	// mentioning bob again notifies carol only
+	first.Body = "@bob @carol please review"
+	first.Mentions = []string{"bob", "carol"}
+	if err := s.UpdateComment(first, log); err != nil {
+		t.Fatal(err)
+	}
+	if got := mentions(); !reflect.DeepEqual(got, [][]string{{"bob"}, {"carol"}}) {
+		t.Errorf("want bob then carol notified, got %v", got)
+	}
+
+	page, _ := s.Comments(todo.ID, 1, 1, log)
+	if len(page) != 1 || page[0].ID != second.ID {
+		t.Errorf("want the second comment on the second page, got %+v", page)
+	}
+	found, err := s.FindComment(todo.ID, first.ID, log)
+	if err != nil || !reflect.DeepEqual(found.Mentions, []string{"bob", "carol"}) {
+		t.Errorf("want the updated mentions saved, got %+v %v", found, err)
+	}
+
+	if err := s.DeleteComment(todo.ID, second.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteComment(todo.ID, second.ID, log); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("want deleting twice to fail with not found, got %v", err)
+	}
+
+	s.Delete(todo.ID, log)
+	if left, _ := s.Comments(todo.ID, 10, 0, log); len(left) != 0 {
+		t.Errorf("want the comments deleted with the todo, got %d", len(left))
+	}
+}
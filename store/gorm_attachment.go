@@ -0,0 +1,62 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+)
+
+func (g *GormStore) AddAttachment(todoID string, attachment *model.Attachment, logger logger.ILogDetail) error {
+	return g.attachments("add_attachment", todoID, attachment, func(before []model.Attachment) ([]model.Attachment, error) {
+		return append(append([]model.Attachment{}, before...), *attachment), nil
+	}, logger)
+}
+
+func (g *GormStore) RemoveAttachment(todoID, attachmentID string, logger logger.ILogDetail) (*model.Attachment, error) {
+	var removed *model.Attachment
+	err := g.attachments("remove_attachment", todoID, attachmentID, func(before []model.Attachment) ([]model.Attachment, error) {
+		var after []model.Attachment
+		after, removed = withoutAttachment(before, attachmentID)
+		if removed == nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return after, nil
+	}, logger)
+	return removed, err
+}
+
+// attachments replaces the attachments of a todo with what change makes of
+// them and records the update.
+func (g *GormStore) attachments(cmd, todoID string, document any, change func([]model.Attachment) ([]model.Attachment, error), logger logger.ILogDetail) error {
+	node := "gorm"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": todoID, "document": document}).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var before model.Todo
+		if err := tx.First(&before, "id = ?", todoID).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+
+		attachments, err := change(before.Attachments)
+		if err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+		after := before
+		after.Attachments = attachments
+		after.UpdatedAt = time.Now()
+
+		// a struct update so the column goes through the json serializer
+		r := tx.Model(&model.Todo{ID: todoID}).Select("attachments", "updated_at").Updates(&after)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		return g.record(tx, model.TodoUpdated, &before, &after, logger)
+	})
+}
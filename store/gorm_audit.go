@@ -0,0 +1,30 @@
+package store
+
+import (
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+)
+
+type GormAuditStore struct {
+	db *gorm.DB
+}
+
+func NewGormAuditStore(db *gorm.DB) *GormAuditStore {
+	return &GormAuditStore{db: db}
+}
+
+func (g *GormAuditStore) History(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.AuditEntry, error) {
+	node := "gorm"
+	cmd := "todo_history"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": todoID, "limit": limit, "offset": offset}).End()
+
+	entries := []model.AuditEntry{}
+	r := g.db.Where("todo_id = ?", todoID).Order("created_at desc").Limit(limit).Offset(offset).Find(&entries)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return nil, r.Error
+	}
+	logger.AddInput(node, cmd, len(entries))
+	return entries, nil
+}
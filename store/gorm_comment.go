@@ -0,0 +1,126 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+)
+
+func (g *GormStore) CreateComment(comment *model.Comment, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "create_comment"
+	comment.ID = uuid.New().String()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	logger.AddOutput(node, cmd, comment).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var todo model.Todo
+		if err := tx.First(&todo, "id = ?", comment.TodoID).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+		if err := tx.Create(comment).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+		logger.AddInput(node, cmd, comment.ID)
+
+		return g.mention(tx, &todo, *comment, comment.Mentions, logger)
+	})
+}
+
+func (g *GormStore) Comments(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.Comment, error) {
+	node := "gorm"
+	cmd := "list_comments"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": todoID, "limit": limit, "offset": offset}).End()
+
+	comments := []model.Comment{}
+	r := g.db.Where("todo_id = ?", todoID).Order("created_at").Limit(limit).Offset(offset).Find(&comments)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return nil, r.Error
+	}
+	logger.AddInput(node, cmd, len(comments))
+	return comments, nil
+}
+
+func (g *GormStore) FindComment(todoID, id string, logger logger.ILogDetail) (*model.Comment, error) {
+	node := "gorm"
+	cmd := "find_one_comment"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": todoID, "id": id}).End()
+
+	var comment model.Comment
+	if err := g.db.First(&comment, "todo_id = ? AND id = ?", todoID, id).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, comment.ID)
+	return &comment, nil
+}
+
+func (g *GormStore) UpdateComment(comment *model.Comment, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "update_comment"
+	comment.UpdatedAt = time.Now()
+	logger.AddOutput(node, cmd, comment).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var before model.Comment
+		if err := tx.First(&before, "todo_id = ? AND id = ?", comment.TodoID, comment.ID).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+		var todo model.Todo
+		if err := tx.First(&todo, "id = ?", comment.TodoID).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+
+		// a struct update so mentions go through the json serializer
+		r := tx.Model(&model.Comment{ID: comment.ID}).Select("body", "html", "mentions", "updated_at").Updates(comment)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		return g.mention(tx, &todo, *comment, newMentions(before.Mentions, comment.Mentions), logger)
+	})
+}
+
+func (g *GormStore) DeleteComment(todoID, id string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "delete_comment"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": todoID, "id": id}).End()
+
+	r := g.db.Where("todo_id = ? AND id = ?", todoID, id).Delete(&model.Comment{})
+	if r.Error == nil && r.RowsAffected == 0 {
+		r.Error = gorm.ErrRecordNotFound
+	}
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+// mention writes the outbox entry notifying users of comment, if any.
+func (g *GormStore) mention(tx *gorm.DB, todo *model.Todo, comment model.Comment, users []string, logger logger.ILogDetail) error {
+	if len(users) == 0 {
+		return nil
+	}
+	node := "gorm"
+	entry := newMentionEntry(todo, comment, users)
+	logger.AddOutput(node, "create_outbox", map[string]any{"id": entry.ID, "event": entry.Event, "todo_id": todo.ID, "mentions": users}).End()
+	if err := tx.Create(entry).Error; err != nil {
+		logger.AddError(node, "create_outbox", "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, "create_outbox", entry.ID)
+	return nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/utils"
+	"gorm.io/gorm"
+)
+
+func (g *GormStore) CreateList(list *model.List, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "create_list"
+	list.ID = uuid.New().String()
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	logger.AddOutput(node, cmd, list).End()
+
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(list).Error; err != nil {
+			return err
+		}
+		if list.UserID == "" {
+			return nil
+		}
+		return addMember(tx, &model.Membership{ListID: list.ID, UserID: list.UserID, Role: model.RoleOwner})
+	})
+	if err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, list.ID)
+	return nil
+}
+
+func (g *GormStore) Lists(ids []string, logger logger.ILogDetail) ([]model.List, error) {
+	node := "gorm"
+	cmd := "list_lists"
+	logger.AddOutput(node, cmd, map[string]any{"ids": ids}).End()
+
+	lists := []model.List{}
+	if len(ids) == 0 {
+		logger.AddInput(node, cmd, 0)
+		return lists, nil
+	}
+	if err := g.db.Where("id IN ?", ids).Order("created_at").Find(&lists).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, len(lists))
+	return lists, nil
+}
+
+func (g *GormStore) FindList(id string, logger logger.ILogDetail) (*model.List, error) {
+	node := "gorm"
+	cmd := "find_one_list"
+	logger.AddOutput(node, cmd, id).End()
+
+	var list model.List
+	if err := g.db.First(&list, "id = ?", id).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, list.ID)
+	return &list, nil
+}
+
+func (g *GormStore) UpdateList(list *model.List, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "update_list"
+	list.UpdatedAt = time.Now()
+	logger.AddOutput(node, cmd, list).End()
+
+	r := g.db.Model(&model.List{}).Where("id = ?", list.ID).Updates(map[string]any{
+		"name":       list.Name,
+		"updated_at": list.UpdatedAt,
+	})
+	if r.Error == nil && r.RowsAffected == 0 {
+		r.Error = gorm.ErrRecordNotFound
+	}
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+func (g *GormStore) DeleteList(id string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "delete_list"
+	logger.AddOutput(node, cmd, id).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		r := tx.Where("id = ?", id).Delete(&model.List{})
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return r.Error
+		}
+
+		err := tx.Model(&model.Todo{}).Where("list_id = ?", id).Updates(map[string]any{
+			"list_id": "",
+			"rank":    "",
+		}).Error
+		if err == nil {
+			err = tx.Where("list_id = ?", id).Delete(&model.Membership{}).Error
+		}
+		if err == nil {
+			err = tx.Where("list_id = ?", id).Delete(&model.Invitation{}).Error
+		}
+		if err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+		return nil
+	})
+}
+
+func (g *GormStore) ListTodos(listID string, logger logger.ILogDetail) ([]model.Todo, error) {
+	node := "gorm"
+	cmd := "list_todos_of_list"
+	logger.AddOutput(node, cmd, map[string]any{"list_id": listID}).End()
+
+	todos := []model.Todo{}
+	if err := g.db.Where("list_id = ?", listID).Order("rank").Find(&todos).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Href = utils.GenHref(todos[i].ID)
+	}
+	logger.AddInput(node, cmd, len(todos))
+	return todos, nil
+}
+
+// neighbours looks up ranks in listID, leaving out the todo being moved.
+func (g *GormStore) neighbours(tx *gorm.DB, listID, id string) neighbours {
+	inList := func() *gorm.DB {
+		return tx.Model(&model.Todo{}).Where("list_id = ? AND id <> ?", listID, id)
+	}
+	first := func(q *gorm.DB, order string) (string, error) {
+		var ranks []string
+		err := q.Order(order).Limit(1).Pluck("rank", &ranks).Error
+		if err != nil || len(ranks) == 0 {
+			return "", err
+		}
+		return ranks[0], nil
+	}
+
+	return neighbours{
+		rankOf: func(other string) (string, error) {
+			var todo model.Todo
+			if err := tx.First(&todo, "id = ?", other).Error; err != nil {
+				return "", err
+			}
+			if todo.ListID != listID {
+				return "", ErrNotInList
+			}
+			return todo.Rank, nil
+		},
+		last: func() (string, error) {
+			return first(inList(), "rank DESC")
+		},
+		after: func(rank string) (string, error) {
+			return first(inList().Where("rank > ?", rank), "rank")
+		},
+		before: func(rank string) (string, error) {
+			return first(inList().Where("rank < ?", rank), "rank DESC")
+		},
+	}
+}
+
+func (g *GormStore) Reorder(id string, pos Position, logger logger.ILogDetail) (*model.Todo, error) {
+	node := "gorm"
+	cmd := "reorder_todo"
+	logger.AddOutput(node, cmd, map[string]any{"id": id, "position": pos}).End()
+
+	var after model.Todo
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		var before model.Todo
+		if err := tx.First(&before, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		listID := pos.ListID
+		if listID == "" {
+			listID = before.ListID
+		}
+		if listID == "" {
+			return ErrNotInList
+		}
+		if err := tx.First(&model.List{}, "id = ?", listID).Error; err != nil {
+			return err
+		}
+
+		rank, err := g.neighbours(tx, listID, id).place(pos)
+		if err != nil {
+			return err
+		}
+
+		after = before
+		after.ListID = listID
+		after.Rank = rank
+		after.UpdatedAt = time.Now()
+		r := tx.Model(&model.Todo{}).Where("id = ?", id).Updates(map[string]any{
+			"list_id":    listID,
+			"rank":       rank,
+			"updated_at": after.UpdatedAt,
+		})
+		if r.Error != nil {
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		return g.record(tx, model.TodoUpdated, &before, &after, logger)
+	})
+	if err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+
+	after.Href = utils.GenHref(after.ID)
+	return &after, nil
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (g *GormStore) AddMember(m *model.Membership, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "add_member"
+	logger.AddOutput(node, cmd, m).End()
+
+	if err := addMember(g.db, m); err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, m.Role)
+	return nil
+}
+
+func addMember(tx *gorm.DB, m *model.Membership) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "list_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(m).Error
+}
+
+func (g *GormStore) RemoveMember(listID, userID string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "remove_member"
+	logger.AddOutput(node, cmd, map[string]any{"list_id": listID, "user_id": userID}).End()
+
+	r := g.db.Where("list_id = ? AND user_id = ?", listID, userID).Delete(&model.Membership{})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+func (g *GormStore) Members(listID string, logger logger.ILogDetail) ([]model.Membership, error) {
+	return g.memberships("list_members", "list_id = ?", listID, logger)
+}
+
+func (g *GormStore) Memberships(userID string, logger logger.ILogDetail) ([]model.Membership, error) {
+	return g.memberships("list_memberships", "user_id = ?", userID, logger)
+}
+
+func (g *GormStore) memberships(cmd, query, id string, logger logger.ILogDetail) ([]model.Membership, error) {
+	node := "gorm"
+	logger.AddOutput(node, cmd, map[string]any{"query": query, "id": id}).End()
+
+	members := []model.Membership{}
+	if err := g.db.Where(query, id).Order("created_at").Find(&members).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, len(members))
+	return members, nil
+}
+
+func (g *GormStore) Role(listID, userID string, logger logger.ILogDetail) (string, error) {
+	node := "gorm"
+	cmd := "member_role"
+	logger.AddOutput(node, cmd, map[string]any{"list_id": listID, "user_id": userID}).End()
+
+	role, err := memberRole(g.db, listID, userID)
+	if err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return "", err
+	}
+	logger.AddInput(node, cmd, role)
+	return role, nil
+}
+
+func memberRole(tx *gorm.DB, listID, userID string) (string, error) {
+	var m model.Membership
+	err := tx.Limit(1).Find(&m, "list_id = ? AND user_id = ?", listID, userID).Error
+	return m.Role, err
+}
+
+func (g *GormStore) CreateInvitation(inv *model.Invitation, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "create_invitation"
+	inv.ID = invitationID(inv.Token)
+	inv.CreatedAt = time.Now()
+	logger.AddOutput(node, cmd, map[string]any{"list_id": inv.ListID, "role": inv.Role, "expires_at": inv.ExpiresAt}).End()
+
+	if err := g.db.Create(inv).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, inv.ListID)
+	return nil
+}
+
+func (g *GormStore) AcceptInvitation(token, userID string, logger logger.ILogDetail) (*model.Membership, error) {
+	node := "gorm"
+	cmd := "accept_invitation"
+	logger.AddOutput(node, cmd, map[string]any{"user_id": userID}).End()
+
+	var m *model.Membership
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var inv model.Invitation
+		err := tx.First(&inv, "id = ?", invitationID(token)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitation
+		}
+		if err != nil {
+			return err
+		}
+		if err := acceptable(&inv, now); err != nil {
+			return err
+		}
+
+		// only the first of concurrent accepts gets the invitation
+		r := tx.Model(&model.Invitation{}).Where("id = ? AND accepted_at IS NULL", inv.ID).Updates(map[string]any{
+			"accepted_by": userID,
+			"accepted_at": now,
+		})
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return ErrInvitation
+		}
+
+		role, err := memberRole(tx, inv.ListID, userID)
+		if err != nil {
+			return err
+		}
+		m = joined(&inv, userID, role, now)
+		if m.Role == role {
+			return nil
+		}
+		return addMember(tx, m)
+	})
+	if err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, m)
+	return m, nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+)
+
+type GormOutboxStore struct {
+	db *gorm.DB
+}
+
+func NewGormOutboxStore(db *gorm.DB) *GormOutboxStore {
+	return &GormOutboxStore{db: db}
+}
+
+func (g *GormOutboxStore) PendingOutbox(limit int, logger logger.ILogDetail) ([]model.OutboxEntry, error) {
+	node := "gorm"
+	cmd := "pending_outbox"
+	logger.AddOutput(node, cmd, map[string]any{"limit": limit}).End()
+
+	var entries []model.OutboxEntry
+	r := g.db.Where("status = ?", model.OutboxPending).Order("created_at, id").Limit(limit).Find(&entries)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return nil, r.Error
+	}
+	logger.AddInput(node, cmd, len(entries))
+	return entries, nil
+}
+
+func (g *GormOutboxStore) MarkPublished(entry *model.OutboxEntry, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "publish_outbox"
+	now := time.Now()
+	entry.Status = model.OutboxPublished
+	entry.PublishedAt = &now
+	entry.Attempts++
+	logger.AddOutput(node, cmd, map[string]any{"id": entry.ID, "attempts": entry.Attempts}).End()
+
+	if err := g.db.Save(entry).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, entry.ID)
+	return nil
+}
+
+func (g *GormOutboxStore) MarkFailed(entry *model.OutboxEntry, cause error, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "fail_outbox"
+	entry.Attempts++
+	entry.LastError = cause.Error()
+	logger.AddOutput(node, cmd, map[string]any{"id": entry.ID, "attempts": entry.Attempts}).End()
+
+	if err := g.db.Save(entry).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, entry.ID)
+	return nil
+}
+
+func (g *GormOutboxStore) CountOutbox(status string, logger logger.ILogDetail) (int64, error) {
+	node := "gorm"
+	cmd := "count_outbox"
+	logger.AddOutput(node, cmd, status).End()
+
+	var n int64
+	if err := g.db.Model(&model.OutboxEntry{}).Where("status = ?", status).Count(&n).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return 0, err
+	}
+	logger.AddInput(node, cmd, n)
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormReminderStore struct {
+	db *gorm.DB
+}
+
+func NewGormReminderStore(db *gorm.DB) *GormReminderStore {
+	return &GormReminderStore{db: db}
+}
+
+func (g *GormReminderStore) AcquireLease(name, holder string, ttl time.Duration, logger logger.ILogDetail) (bool, error) {
+	node := "gorm"
+	cmd := "acquire_lease"
+	now := time.Now()
+	lease := model.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+	logger.AddOutput(node, cmd, lease).End()
+
+	// renew our own or take over an expired lease, else create it
+	r := g.db.Model(&model.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]any{"holder": holder, "expires_at": lease.ExpiresAt})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return false, r.Error
+	}
+	if r.RowsAffected == 0 {
+		r = g.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return false, r.Error
+		}
+	}
+
+	// what the writes affected depends on the driver, e.g. MySQL counts no
+	// row for a renewal changing nothing, so the lease is read back
+	var held model.Lease
+	if err := g.db.Take(&held, "name = ?", name).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return false, err
+	}
+	acquired := held.Holder == holder
+	logger.AddInput(node, cmd, map[string]any{"acquired": acquired, "lease": held})
+	return acquired, nil
+}
+
+func (g *GormReminderStore) ReleaseLease(name, holder string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "release_lease"
+	logger.AddOutput(node, cmd, map[string]any{"name": name, "holder": holder}).End()
+
+	r := g.db.Where("name = ? AND holder = ?", name, holder).Delete(&model.Lease{})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+func (g *GormReminderStore) DueTodos(now, from, to time.Time, limit int, logger logger.ILogDetail) ([]model.Todo, error) {
+	node := "gorm"
+	cmd := "due_todo"
+	logger.AddOutput(node, cmd, map[string]any{"now": now, "from": from, "to": to, "limit": limit}).End()
+
+	// skip the todos reminded of already, else they fill every batch and
+	// the ones behind them are never reached
+	reminded := g.db.Model(&model.Reminder{}).Select("1").
+		Where("reminders.todo_id = todos.id AND reminders.due_at = todos.due_at").
+		Where("reminders.kind = CASE WHEN todos.due_at > ? THEN ? ELSE ? END", now, model.ReminderDueSoon, model.ReminderOverdue)
+
+	var todos []model.Todo
+	r := g.db.Where("completed = ? AND due_at >= ? AND due_at <= ?", false, from, to).
+		Where("NOT EXISTS (?)", reminded).
+		Order("due_at").Limit(limit).Find(&todos)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return nil, r.Error
+	}
+	logger.AddInput(node, cmd, len(todos))
+	return todos, nil
+}
+
+func (g *GormReminderStore) ClaimReminder(reminder *model.Reminder, logger logger.ILogDetail) (bool, error) {
+	node := "gorm"
+	cmd := "claim_reminder"
+	logger.AddOutput(node, cmd, reminder).End()
+
+	r := g.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return false, r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return r.RowsAffected == 1, nil
+}
+
+func (g *GormReminderStore) ReleaseReminder(id string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "release_reminder"
+	logger.AddOutput(node, cmd, id).End()
+
+	if err := g.db.Where("id = ?", id).Delete(&model.Reminder{}).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, id)
+	return nil
+}
@@ -20,15 +20,33 @@ func NewGormStore(db *gorm.DB) *GormStore {
 }
 
 func (g *GormStore) Create(todo *model.Todo, logger logger.ILogDetail) error {
-	store := Store{
-		sql:    g.db,
-		logger: logger,
-	}
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		return g.create(tx, todo, logger)
+	})
+}
 
+func (g *GormStore) create(tx *gorm.DB, todo *model.Todo, logger logger.ILogDetail) error {
 	todo.ID = uuid.New().String()
 	todo.CreatedAt = time.Now()
 	todo.UpdatedAt = time.Now()
-	return store.Create("create_todo", "todo", "create", todo)
+	startSeries(todo)
+	if todo.ListID != "" {
+		// new todos go to the end of their list
+		rank, err := g.neighbours(tx, todo.ListID, todo.ID).place(Position{})
+		if err != nil {
+			return err
+		}
+		todo.Rank = rank
+	}
+
+	store := Store{
+		sql:    tx,
+		logger: logger,
+	}
+	if err := store.Create("create_todo", "todo", "create", todo); err != nil {
+		return err
+	}
+	return g.record(tx, model.TodoCreated, nil, todo, logger)
 }
 
 func (g *GormStore) List(opt FindOption, logger logger.ILogDetail) ([]model.Todo, error) {
@@ -62,14 +80,32 @@ func (g *GormStore) Delete(id string, logger logger.ILogDetail) error {
 	logger.AddOutput(node, cmd, map[string]any{
 		"query": strings.Replace(query, "?", id, 1),
 	}).End()
-	r := g.db.Where(query, id).Delete(&model.Todo{})
 
-	if r.Error != nil {
-		logger.AddError(node, cmd, "output", nil, r.Error)
-		return r.Error
-	}
-	logger.AddOutput(node, cmd, r.RowsAffected).End()
-	return nil
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		// the deleted todo goes in the outbox entry
+		var todo model.Todo
+		r := tx.Limit(1).Find(&todo, query, id)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "output", nil, r.Error)
+			return r.Error
+		}
+
+		r = tx.Where(query, id).Delete(&model.Todo{})
+		if r.Error != nil {
+			logger.AddError(node, cmd, "output", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		if r.RowsAffected == 0 {
+			return nil
+		}
+		if err := g.detach(tx, &todo); err != nil {
+			logger.AddError(node, cmd, "output", nil, err)
+			return err
+		}
+		return g.record(tx, model.TodoDeleted, &todo, nil, logger)
+	})
 }
 
 func (g *GormStore) FindOne(id string, logger logger.ILogDetail) (*model.Todo, error) {
@@ -86,3 +122,87 @@ func (g *GormStore) FindOne(id string, logger logger.ILogDetail) (*model.Todo, e
 	logger.AddInput(node, cmd, todo)
 	return &todo, nil
 }
+
+// Update saves todo. Completing a recurring todo creates the next
+// occurrence, which carries the recurrence on from then.
+func (g *GormStore) Update(todo *model.Todo, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "update_todo"
+	query := "id = ?"
+	stampUpdate(todo)
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var before model.Todo
+		if err := tx.First(&before, query, todo.ID).Error; err != nil {
+			logger.AddError(node, cmd, "output", nil, err)
+			return err
+		}
+
+		after := applyUpdate(before, todo)
+		kind := model.UpdateKind(&before, &after)
+		var next *model.Todo
+		if kind == model.TodoCompleted {
+			next = nextOccurrence(&after)
+		}
+		if next != nil {
+			todo.Recurrence = ""
+			after.Recurrence = ""
+		}
+
+		updates := map[string]any{
+			"title":        todo.Title,
+			"completed":    todo.Completed,
+			"completed_at": todo.CompletedAt,
+			"due_at":       todo.DueAt,
+			"time_zone":    todo.TimeZone,
+			"recurrence":   todo.Recurrence,
+			"updated_at":   todo.UpdatedAt,
+		}
+		logger.AddOutput(node, cmd, map[string]any{
+			"query":    strings.Replace(query, "?", todo.ID, 1),
+			"document": updates,
+		}).End()
+
+		r := tx.Model(&model.Todo{}).Where(query, todo.ID).Updates(updates)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "output", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		if err := g.record(tx, kind, &before, &after, logger); err != nil {
+			return err
+		}
+		if next != nil {
+			return g.create(tx, next, logger)
+		}
+		return nil
+	})
+}
+
+// record writes the audit entry and the outbox entry of a change. A nil
+// before is a create, a nil after a delete.
+func (g *GormStore) record(tx *gorm.DB, event string, before, after *model.Todo, logger logger.ILogDetail) error {
+	node := "gorm"
+
+	audit := newAuditEntry(event, before, after, logger.Actor())
+	logger.AddOutput(node, "create_audit", map[string]any{"id": audit.ID, "action": event, "todo_id": audit.TodoID}).End()
+	if err := tx.Create(audit).Error; err != nil {
+		logger.AddError(node, "create_audit", "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, "create_audit", audit.ID)
+
+	todo := after
+	if todo == nil {
+		todo = before
+	}
+	entry := newOutboxEntry(event, todo)
+	logger.AddOutput(node, "create_outbox", map[string]any{"id": entry.ID, "event": event, "todo_id": todo.ID}).End()
+	if err := tx.Create(entry).Error; err != nil {
+		logger.AddError(node, "create_outbox", "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, "create_outbox", entry.ID)
+	return nil
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// subtreeCTE selects the ids of every descendant of a todo.
+const subtreeCTE = `WITH RECURSIVE subtree(id) AS (
+	SELECT id FROM todos WHERE parent_id = ?
+	UNION
+	SELECT t.id FROM todos t JOIN subtree s ON t.parent_id = s.id
+)`
+
+// blockersCTE selects the ids of every todo a todo is transitively blocked
+// by. UNION rather than UNION ALL stops at todos already seen.
+const blockersCTE = `WITH RECURSIVE blockers(id) AS (
+	SELECT blocked_by_id FROM todo_dependencies WHERE todo_id = ?
+	UNION
+	SELECT d.blocked_by_id FROM todo_dependencies d JOIN blockers b ON d.todo_id = b.id
+)`
+
+func (g *GormStore) Children(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error) {
+	node := "gorm"
+	cmd := "list_children"
+	query := "SELECT * FROM todos WHERE parent_id = ? ORDER BY created_at"
+	if recursive {
+		query = subtreeCTE + " SELECT * FROM todos WHERE id IN (SELECT id FROM subtree) ORDER BY created_at"
+	}
+	return g.related(node, cmd, query, id, logger)
+}
+
+func (g *GormStore) Blockers(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error) {
+	node := "gorm"
+	cmd := "list_blockers"
+	query := "SELECT * FROM todos WHERE id IN (SELECT blocked_by_id FROM todo_dependencies WHERE todo_id = ?) ORDER BY created_at"
+	if recursive {
+		query = blockersCTE + " SELECT * FROM todos WHERE id IN (SELECT id FROM blockers) ORDER BY created_at"
+	}
+	return g.related(node, cmd, query, id, logger)
+}
+
+func (g *GormStore) related(node, cmd, query, id string, logger logger.ILogDetail) ([]model.Todo, error) {
+	logger.AddOutput(node, cmd, map[string]any{"query": query, "id": id}).End()
+
+	todos := []model.Todo{}
+	if err := g.db.Raw(query, id).Scan(&todos).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Href = utils.GenHref(todos[i].ID)
+	}
+	logger.AddInput(node, cmd, len(todos))
+	return todos, nil
+}
+
+// reaches reports whether target is among the ids selected by cte for id.
+func reaches(tx *gorm.DB, cte, table, id, target string) (bool, error) {
+	var n int64
+	err := tx.Raw(cte+" SELECT count(*) FROM "+table+" WHERE id = ?", id, target).Scan(&n).Error
+	return n > 0, err
+}
+
+func (g *GormStore) Move(id, parentID string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "move_todo"
+	logger.AddOutput(node, cmd, map[string]any{"id": id, "parent_id": parentID}).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var before model.Todo
+		if err := tx.First(&before, "id = ?", id).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+
+		if parentID != "" {
+			if err := tx.First(&model.Todo{}, "id = ?", parentID).Error; err != nil {
+				logger.AddError(node, cmd, "input", nil, err)
+				return err
+			}
+			// the new parent can't be the todo or one of its descendants
+			cycle, err := reaches(tx, subtreeCTE, "subtree", id, parentID)
+			if err == nil && (cycle || parentID == id) {
+				err = ErrCycle
+			}
+			if err != nil {
+				logger.AddError(node, cmd, "input", nil, err)
+				return err
+			}
+		}
+
+		after := before
+		after.ParentID = parentID
+		after.UpdatedAt = time.Now()
+		r := tx.Model(&model.Todo{}).Where("id = ?", id).Updates(map[string]any{
+			"parent_id":  parentID,
+			"updated_at": after.UpdatedAt,
+		})
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+
+		return g.record(tx, model.TodoUpdated, &before, &after, logger)
+	})
+}
+
+func (g *GormStore) AddBlocker(id, blockerID string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "add_blocker"
+	dep := model.Dependency{TodoID: id, BlockedByID: blockerID, CreatedAt: time.Now()}
+	logger.AddOutput(node, cmd, dep).End()
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		for _, todoID := range []string{id, blockerID} {
+			if err := tx.First(&model.Todo{}, "id = ?", todoID).Error; err != nil {
+				logger.AddError(node, cmd, "input", nil, err)
+				return err
+			}
+		}
+
+		// the blocker can't already be waiting on the todo
+		cycle, err := reaches(tx, blockersCTE, "blockers", blockerID, id)
+		if err == nil && (cycle || blockerID == id) {
+			err = ErrCycle
+		}
+		if err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return err
+		}
+
+		r := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&dep)
+		if r.Error != nil {
+			logger.AddError(node, cmd, "input", nil, r.Error)
+			return r.Error
+		}
+		logger.AddInput(node, cmd, r.RowsAffected)
+		return nil
+	})
+}
+
+func (g *GormStore) RemoveBlocker(id, blockerID string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "remove_blocker"
+	logger.AddOutput(node, cmd, map[string]any{"todo_id": id, "blocked_by_id": blockerID}).End()
+
+	r := g.db.Where("todo_id = ? AND blocked_by_id = ?", id, blockerID).Delete(&model.Dependency{})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+// detach lifts the subtasks of a deleted todo to its parent and drops its
+// dependencies and comments.
+func (g *GormStore) detach(tx *gorm.DB, todo *model.Todo) error {
+	err := tx.Model(&model.Todo{}).Where("parent_id = ?", todo.ID).Update("parent_id", todo.ParentID).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("todo_id = ? OR blocked_by_id = ?", todo.ID, todo.ID).Delete(&model.Dependency{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("todo_id = ?", todo.ID).Delete(&model.Comment{}).Error
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormWebhookStore struct {
+	db *gorm.DB
+}
+
+func NewGormWebhookStore(db *gorm.DB) *GormWebhookStore {
+	return &GormWebhookStore{db: db}
+}
+
+func (g *GormWebhookStore) CreateWebhook(hook *model.Webhook, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "create_webhook"
+	logger.AddOutput(node, cmd, map[string]any{"id": hook.ID, "url": hook.URL, "events": hook.Events}).End()
+
+	if err := g.db.Create(hook).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, hook.ID)
+	return nil
+}
+
+func (g *GormWebhookStore) ListWebhooks(logger logger.ILogDetail) ([]model.Webhook, error) {
+	node := "gorm"
+	cmd := "list_webhook"
+	logger.AddOutput(node, cmd, "SELECT * FROM webhooks").End()
+
+	var hooks []model.Webhook
+	if err := g.db.Order("created_at").Find(&hooks).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, len(hooks))
+	return hooks, nil
+}
+
+func (g *GormWebhookStore) FindWebhook(id string, logger logger.ILogDetail) (*model.Webhook, error) {
+	node := "gorm"
+	cmd := "find_one_webhook"
+	logger.AddOutput(node, cmd, id).End()
+
+	var hook model.Webhook
+	if err := g.db.First(&hook, "id = ?", id).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, hook.ID)
+	return &hook, nil
+}
+
+func (g *GormWebhookStore) DeleteWebhook(id string, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "delete_webhook"
+	logger.AddOutput(node, cmd, id).End()
+
+	r := g.db.Where("id = ?", id).Delete(&model.Webhook{})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+func (g *GormWebhookStore) CreateDelivery(d *model.Delivery, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "create_delivery"
+	logger.AddOutput(node, cmd, map[string]any{"id": d.ID, "webhook_id": d.WebhookID, "event": d.Event}).End()
+
+	r := g.db.Clauses(clause.OnConflict{DoNothing: true}).Create(d)
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return r.Error
+	}
+	logger.AddInput(node, cmd, r.RowsAffected)
+	return nil
+}
+
+func (g *GormWebhookStore) UpdateDelivery(d *model.Delivery, logger logger.ILogDetail) error {
+	node := "gorm"
+	cmd := "update_delivery"
+	d.UpdatedAt = time.Now()
+	logger.AddOutput(node, cmd, map[string]any{"id": d.ID, "status": d.Status, "attempts": d.Attempts}).End()
+
+	if err := g.db.Save(d).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput(node, cmd, d.ID)
+	return nil
+}
+
+func (g *GormWebhookStore) FindDelivery(id string, logger logger.ILogDetail) (*model.Delivery, error) {
+	node := "gorm"
+	cmd := "find_one_delivery"
+	logger.AddOutput(node, cmd, id).End()
+
+	var d model.Delivery
+	if err := g.db.First(&d, "id = ?", id).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, d.ID)
+	return &d, nil
+}
+
+func (g *GormWebhookStore) ListDeliveries(opt DeliveryFilter, logger logger.ILogDetail) ([]model.Delivery, error) {
+	node := "gorm"
+	cmd := "list_delivery"
+	logger.AddOutput(node, cmd, opt).End()
+
+	query := g.db.Order("created_at desc")
+	if opt.WebhookID != "" {
+		query = query.Where("webhook_id = ?", opt.WebhookID)
+	}
+	if opt.Status != "" {
+		query = query.Where("status = ?", opt.Status)
+	}
+	if opt.Limit > 0 {
+		query = query.Limit(opt.Limit)
+	}
+
+	var deliveries []model.Delivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		logger.AddError(node, cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput(node, cmd, len(deliveries))
+	return deliveries, nil
+}
+
+func (g *GormWebhookStore) ClaimDeliveries(claim string, now time.Time, ttl time.Duration, limit int, logger logger.ILogDetail) ([]model.Delivery, error) {
+	node := "gorm"
+	cmd := "claim_delivery"
+	until := now.Add(ttl)
+	logger.AddOutput(node, cmd, map[string]any{"claim": claim, "now": now, "until": until, "limit": limit}).End()
+
+	// the update checks again that the rows are due, so rows claimed by a
+	// concurrent update in between are left alone
+	due := g.db.Model(&model.Delivery{}).Select("id").
+		Where("status = ? AND next_attempt <= ?", model.DeliveryPending, now).
+		Order("next_attempt").Limit(limit)
+	r := g.db.Model(&model.Delivery{}).
+		Where("id IN (?) AND status = ? AND next_attempt <= ?", due, model.DeliveryPending, now).
+		Updates(map[string]any{"claimed_by": claim, "next_attempt": until})
+	if r.Error != nil {
+		logger.AddError(node, cmd, "input", nil, r.Error)
+		return nil, r.Error
+	}
+
+	var deliveries []model.Delivery
+	if r.RowsAffected > 0 {
+		if err := g.db.Where("claimed_by = ?", claim).Order("created_at").Find(&deliveries).Error; err != nil {
+			logger.AddError(node, cmd, "input", nil, err)
+			return nil, err
+		}
+	}
+	logger.AddInput(node, cmd, len(deliveries))
+	return deliveries, nil
+}
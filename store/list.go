@@ -0,0 +1,70 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/rank"
+)
+
+// ErrNotInList is returned when a todo to place another one next to isn't
+// in the target list.
+var ErrNotInList = errors.New("todo is not in the list")
+
+// Position says where Reorder puts a todo: right after After, right before
+// Before, or at the end of the list when both are empty.
+type Position struct {
+	ListID string `json:"list_id"`
+	After  string `json:"after"`
+	Before string `json:"before"`
+}
+
+type ListStorer interface {
+	// CreateList makes the list's UserID, if any, its owner.
+	CreateList(*model.List, logger.ILogDetail) error
+	// Lists returns the lists with the given ids.
+	Lists(ids []string, logger logger.ILogDetail) ([]model.List, error)
+	FindList(id string, logger logger.ILogDetail) (*model.List, error)
+	UpdateList(*model.List, logger.ILogDetail) error
+	// DeleteList removes a list with its members and invitations, its
+	// todos are kept without one.
+	DeleteList(id string, logger logger.ILogDetail) error
+
+	// ListTodos returns the todos of a list in their manual order.
+	ListTodos(listID string, logger logger.ILogDetail) ([]model.Todo, error)
+	// Reorder moves todo id to pos, in its current list when pos has no
+	// ListID. Only the moved todo is written.
+	Reorder(id string, pos Position, logger logger.ILogDetail) (*model.Todo, error)
+}
+
+// neighbours looks up the ranks a todo placed at pos goes between. rankOf
+// returns the rank of a todo in the list, last the rank at the end of it
+// and after/before the closest rank past a given one.
+type neighbours struct {
+	rankOf func(id string) (string, error)
+	last   func() (string, error)
+	after  func(rank string) (string, error)
+	before func(rank string) (string, error)
+}
+
+func (n neighbours) place(pos Position) (string, error) {
+	var prev, next string
+	var err error
+	switch {
+	case pos.After != "":
+		if prev, err = n.rankOf(pos.After); err == nil {
+			next, err = n.after(prev)
+		}
+	case pos.Before != "":
+		if next, err = n.rankOf(pos.Before); err == nil {
+			prev, err = n.before(next)
+		}
+	default:
+		prev, err = n.last()
+	}
+	if err != nil {
+		return "", err
+	}
+	return rank.Between(prev, next)
+}
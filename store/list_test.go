@@ -0,0 +1,91 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sing3demons/todoapi/model"
+)
+
+func titles(todos []model.Todo) []string {
+	out := make([]string, len(todos))
+	for i, todo := range todos {
+		out[i] = todo.Title
+	}
+	return out
+}
+
+func TestListOrdering(t *testing.T) {
+	s, log := newTestStore(t)
+
+	list := &model.List{Name: "groceries"}
+	if err := s.CreateList(list, log); err != nil {
+		t.Fatal(err)
+	}
+	todos := map[string]*model.Todo{}
+	for _, title := range []string{"milk", "eggs", "bread"} {
+		todo := &model.Todo{Title: title, ListID: list.ID}
+		if err := s.Create(todo, log); err != nil {
+			t.Fatal(err)
+		}
+		todos[title] = todo
+	}
+
+	want := func(order ...string) {
+		t.Helper()
+		got, err := s.ListTodos(list.ID, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(order) {
+			t.Fatalf("want %v, got %v", order, titles(got))
+		}
+		for i := range order {
+			if got[i].Title != order[i] {
+				t.Fatalf("want %v, got %v", order, titles(got))
+			}
+		}
+	}
+	want("milk", "eggs", "bread")
+
+	if _, err := s.Reorder(todos["bread"].ID, Position{Before: todos["milk"].ID}, log); err != nil {
+		t.Fatal(err)
+	}
+	want("bread", "milk", "eggs")
+
+	moved, err := s.Reorder(todos["bread"].ID, Position{After: todos["milk"].ID}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want("milk", "bread", "eggs")
+
+	// only the moved todo gets a new rank
+	milk, _ := s.FindOne(todos["milk"].ID, log)
+	eggs, _ := s.FindOne(todos["eggs"].ID, log)
+	if milk.Rank != todos["milk"].Rank || eggs.Rank != todos["eggs"].Rank {
+		t.Error("want the other todos left alone")
+	}
+	if !(milk.Rank < moved.Rank && moved.Rank < eggs.Rank) {
+		t.Errorf("want %q between %q and %q", moved.Rank, milk.Rank, eggs.Rank)
+	}
+
+	loose := &model.Todo{Title: "coffee"}
+	s.Create(loose, log)
+	if _, err := s.Reorder(loose.ID, Position{}, log); !errors.Is(err, ErrNotInList) {
+		t.Errorf("reordering a todo without a list: want ErrNotInList, got %v", err)
+	}
+	if _, err := s.Reorder(todos["milk"].ID, Position{After: loose.ID}, log); !errors.Is(err, ErrNotInList) {
+		t.Errorf("placing after a todo of another list: want ErrNotInList, got %v", err)
+	}
+	if _, err := s.Reorder(loose.ID, Position{ListID: list.ID}, log); err != nil {
+		t.Fatal(err)
+	}
+	want("milk", "bread", "eggs", "coffee")
+
+	if err := s.DeleteList(list.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	if milk, _ := s.FindOne(todos["milk"].ID, log); milk.ListID != "" || milk.Rank != "" {
+		t.Errorf("want milk kept without a list, got %+v", milk)
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// ErrInvitation is returned for an invitation token that is unknown,
+// already used or expired.
+var ErrInvitation = errors.New("invitation is invalid or expired")
+
+type MemberStorer interface {
+	// AddMember gives a user a role on a list, replacing the one they had.
+	AddMember(*model.Membership, logger.ILogDetail) error
+	RemoveMember(listID, userID string, logger logger.ILogDetail) error
+	Members(listID string, logger logger.ILogDetail) ([]model.Membership, error)
+	// Memberships returns the lists userID belongs to.
+	Memberships(userID string, logger logger.ILogDetail) ([]model.Membership, error)
+	// Role returns the role of userID on listID, empty when they have none.
+	Role(listID, userID string, logger logger.ILogDetail) (string, error)
+
+	// CreateInvitation stores an invitation under the hash of its Token.
+	CreateInvitation(*model.Invitation, logger.ILogDetail) error
+	// AcceptInvitation makes userID a member with the role of the
+	// invitation. Members keep a higher role they already have.
+	AcceptInvitation(token, userID string, logger logger.ILogDetail) (*model.Membership, error)
+}
+
+func invitationID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// acceptable checks an invitation can still be used.
+func acceptable(inv *model.Invitation, now time.Time) error {
+	if inv.AcceptedAt != nil || now.After(inv.ExpiresAt) {
+		return ErrInvitation
+	}
+	return nil
+}
+
+// joined is the membership accepting inv gives userID, who has role on the
+// list already.
+func joined(inv *model.Invitation, userID, role string, now time.Time) *model.Membership {
+	if model.RoleAllows(role, inv.Role) {
+		return &model.Membership{ListID: inv.ListID, UserID: userID, Role: role}
+	}
+	return &model.Membership{ListID: inv.ListID, UserID: userID, Role: inv.Role, CreatedAt: now}
+}
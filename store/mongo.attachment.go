@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (g *MongoStore) AddAttachment(todoID string, attachment *model.Attachment, logger logger.ILogDetail) error {
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "attachments", Value: attachment}}}}
+	return g.attachments("add_attachment", todoID, update, func(before []model.Attachment) ([]model.Attachment, error) {
+		return append(append([]model.Attachment{}, before...), *attachment), nil
+	}, logger)
+}
+
+func (g *MongoStore) RemoveAttachment(todoID, attachmentID string, logger logger.ILogDetail) (*model.Attachment, error) {
+	var removed *model.Attachment
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "attachments", Value: bson.D{{Key: "id", Value: attachmentID}}}}}}
+	err := g.attachments("remove_attachment", todoID, update, func(before []model.Attachment) ([]model.Attachment, error) {
+		var after []model.Attachment
+		after, removed = withoutAttachment(before, attachmentID)
+		if removed == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return after, nil
+	}, logger)
+	return removed, err
+}
+
+// attachments applies update to a todo, change tells what it does to the
+// attachments for the audit entry. $push and $pull leave attachments added
+// concurrently alone.
+func (g *MongoStore) attachments(cmd, todoID string, update bson.D, change func([]model.Attachment) ([]model.Attachment, error), logger logger.ILogDetail) error {
+	filter := bson.D{
+		{Key: "deleted_at", Value: nil},
+		{Key: "id", Value: todoID},
+	}
+
+	return g.withTx(func(ctx context.Context) error {
+		var before model.Todo
+		if err := g.Collection.FindOne(ctx, filter).Decode(&before); err != nil {
+			logger.AddError("mongo", cmd, "input", nil, err)
+			return err
+		}
+
+		attachments, err := change(before.Attachments)
+		if err != nil {
+			logger.AddError("mongo", cmd, "input", nil, err)
+			return err
+		}
+		after := before
+		after.Attachments = attachments
+		after.UpdatedAt = time.Now()
+
+		stamped := append(bson.D{{Key: "$set", Value: bson.D{{Key: "updated_at", Value: after.UpdatedAt}}}}, update...)
+		logger.AddOutput("mongo", cmd, map[string]any{
+			"filter": filter,
+			"update": stamped,
+		}).End()
+
+		r, err := g.Collection.UpdateOne(ctx, filter, stamped)
+		if err != nil {
+			logger.AddError("mongo", cmd, "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", cmd, r)
+
+		return g.record(ctx, model.TodoUpdated, &before, &after, logger)
+	})
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAuditStore struct {
+	*mongo.Collection
+}
+
+func NewMongoAuditStore(db *mongo.Database) *MongoAuditStore {
+	return &MongoAuditStore{db.Collection("todo_audit")}
+}
+
+func (m *MongoAuditStore) History(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "todo_id", Value: todoID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)).SetSkip(int64(offset))
+	logger.AddOutput("mongo", "todo_history", map[string]any{"filter": filter, "limit": limit, "offset": offset}).End()
+
+	cur, err := m.Find(ctx, filter, opts)
+	if err != nil {
+		logger.AddError("mongo", "todo_history", "input", nil, err)
+		return nil, err
+	}
+
+	entries := []model.AuditEntry{}
+	if err := cur.All(ctx, &entries); err != nil {
+		logger.AddError("mongo", "todo_history", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "todo_history", len(entries))
+	return entries, nil
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (g *MongoStore) CreateComment(comment *model.Comment, logger logger.ILogDetail) error {
+	comment.ID = primitive.NewObjectID().Hex()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	logger.AddOutput("mongo", "create_comment", comment).End()
+
+	return g.withTx(func(ctx context.Context) error {
+		var todo model.Todo
+		if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: comment.TodoID}}).Decode(&todo); err != nil {
+			logger.AddError("mongo", "create_comment", "input", nil, err)
+			return err
+		}
+		r, err := g.comments.InsertOne(ctx, comment)
+		if err != nil {
+			logger.AddError("mongo", "create_comment", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "create_comment", r)
+
+		return g.mention(ctx, &todo, *comment, comment.Mentions, logger)
+	})
+}
+
+func (g *MongoStore) Comments(todoID string, limit, offset int, logger logger.ILogDetail) ([]model.Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "todo_id", Value: todoID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit)).SetSkip(int64(offset))
+	logger.AddOutput("mongo", "list_comments", map[string]any{"filter": filter, "limit": limit, "offset": offset}).End()
+
+	cur, err := g.comments.Find(ctx, filter, opts)
+	if err != nil {
+		logger.AddError("mongo", "list_comments", "input", nil, err)
+		return nil, err
+	}
+
+	comments := []model.Comment{}
+	if err := cur.All(ctx, &comments); err != nil {
+		logger.AddError("mongo", "list_comments", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "list_comments", len(comments))
+	return comments, nil
+}
+
+func (g *MongoStore) FindComment(todoID, id string, logger logger.ILogDetail) (*model.Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "todo_id", Value: todoID}, {Key: "id", Value: id}}
+	logger.AddOutput("mongo", "find_one_comment", filter).End()
+
+	var comment model.Comment
+	if err := g.comments.FindOne(ctx, filter).Decode(&comment); err != nil {
+		logger.AddError("mongo", "find_one_comment", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "find_one_comment", comment.ID)
+	return &comment, nil
+}
+
+func (g *MongoStore) UpdateComment(comment *model.Comment, logger logger.ILogDetail) error {
+	comment.UpdatedAt = time.Now()
+	filter := bson.D{{Key: "todo_id", Value: comment.TodoID}, {Key: "id", Value: comment.ID}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "body", Value: comment.Body},
+		{Key: "html", Value: comment.HTML},
+		{Key: "mentions", Value: comment.Mentions},
+		{Key: "updated_at", Value: comment.UpdatedAt},
+	}}}
+	logger.AddOutput("mongo", "update_comment", map[string]any{
+		"filter": filter,
+		"update": update,
+	}).End()
+
+	return g.withTx(func(ctx context.Context) error {
+		// the comment as it was, to tell who is newly mentioned
+		var before model.Comment
+		err := g.comments.FindOneAndUpdate(ctx, filter, update).Decode(&before)
+		if err != nil {
+			logger.AddError("mongo", "update_comment", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "update_comment", before.ID)
+
+		var todo model.Todo
+		if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: comment.TodoID}}).Decode(&todo); err != nil {
+			logger.AddError("mongo", "update_comment", "input", nil, err)
+			return err
+		}
+		return g.mention(ctx, &todo, *comment, newMentions(before.Mentions, comment.Mentions), logger)
+	})
+}
+
+func (g *MongoStore) DeleteComment(todoID, id string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "todo_id", Value: todoID}, {Key: "id", Value: id}}
+	logger.AddOutput("mongo", "delete_comment", filter).End()
+
+	r, err := g.comments.DeleteOne(ctx, filter)
+	if err == nil && r.DeletedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		logger.AddError("mongo", "delete_comment", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "delete_comment", r)
+	return nil
+}
+
+// mention writes the outbox entry notifying users of comment, if any.
+func (g *MongoStore) mention(ctx context.Context, todo *model.Todo, comment model.Comment, users []string, logger logger.ILogDetail) error {
+	if len(users) == 0 {
+		return nil
+	}
+	entry := newMentionEntry(todo, comment, users)
+	logger.AddOutput("mongo", "create_outbox", map[string]any{"id": entry.ID, "event": entry.Event, "todo_id": todo.ID, "mentions": users}).End()
+	r, err := g.outbox.InsertOne(ctx, entry)
+	if err != nil {
+		logger.AddError("mongo", "create_outbox", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_outbox", r)
+	return nil
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (g *MongoStore) CreateList(list *model.List, logger logger.ILogDetail) error {
+	list.ID = primitive.NewObjectID().Hex()
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	logger.AddOutput("mongo", "create_list", list).End()
+
+	err := g.withTx(func(ctx context.Context) error {
+		if _, err := g.lists.InsertOne(ctx, list); err != nil {
+			return err
+		}
+		if list.UserID == "" {
+			return nil
+		}
+		return g.addMember(ctx, &model.Membership{ListID: list.ID, UserID: list.UserID, Role: model.RoleOwner})
+	})
+	if err != nil {
+		logger.AddError("mongo", "create_list", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_list", list.ID)
+	return nil
+}
+
+func (g *MongoStore) Lists(ids []string, logger logger.ILogDetail) ([]model.List, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: append([]string{}, ids...)}}}}
+	logger.AddOutput("mongo", "list_lists", filter).End()
+
+	cur, err := g.lists.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		logger.AddError("mongo", "list_lists", "input", nil, err)
+		return nil, err
+	}
+
+	lists := []model.List{}
+	if err := cur.All(ctx, &lists); err != nil {
+		logger.AddError("mongo", "list_lists", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "list_lists", len(lists))
+	return lists, nil
+}
+
+func (g *MongoStore) FindList(id string, logger logger.ILogDetail) (*model.List, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "find_one_list", id).End()
+
+	var list model.List
+	if err := g.lists.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Decode(&list); err != nil {
+		logger.AddError("mongo", "find_one_list", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "find_one_list", list.ID)
+	return &list, nil
+}
+
+func (g *MongoStore) UpdateList(list *model.List, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	list.UpdatedAt = time.Now()
+	logger.AddOutput("mongo", "update_list", list).End()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: list.Name},
+		{Key: "updated_at", Value: list.UpdatedAt},
+	}}}
+	r, err := g.lists.UpdateOne(ctx, bson.D{{Key: "id", Value: list.ID}}, update)
+	if err == nil && r.MatchedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		logger.AddError("mongo", "update_list", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "update_list", r)
+	return nil
+}
+
+func (g *MongoStore) DeleteList(id string, logger logger.ILogDetail) error {
+	logger.AddOutput("mongo", "delete_list", id).End()
+
+	return g.withTx(func(ctx context.Context) error {
+		r, err := g.lists.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+		if err != nil {
+			logger.AddError("mongo", "delete_list", "input", nil, err)
+			return err
+		}
+
+		_, err = g.Collection.UpdateMany(ctx,
+			bson.D{{Key: "list_id", Value: id}},
+			bson.D{{Key: "$unset", Value: bson.D{{Key: "list_id", Value: ""}, {Key: "rank", Value: ""}}}},
+		)
+		if err == nil {
+			_, err = g.members.DeleteMany(ctx, bson.D{{Key: "list_id", Value: id}})
+		}
+		if err == nil {
+			_, err = g.invitations.DeleteMany(ctx, bson.D{{Key: "list_id", Value: id}})
+		}
+		if err != nil {
+			logger.AddError("mongo", "delete_list", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "delete_list", r)
+		return nil
+	})
+}
+
+func (g *MongoStore) ListTodos(listID string, logger logger.ILogDetail) ([]model.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "deleted_at", Value: nil}, {Key: "list_id", Value: listID}}
+	logger.AddOutput("mongo", "list_todos_of_list", filter).End()
+
+	cur, err := g.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "rank", Value: 1}}))
+	if err != nil {
+		logger.AddError("mongo", "list_todos_of_list", "input", nil, err)
+		return nil, err
+	}
+
+	todos := []model.Todo{}
+	if err := cur.All(ctx, &todos); err != nil {
+		logger.AddError("mongo", "list_todos_of_list", "input", nil, err)
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Href = utils.GenHref(todos[i].ID)
+	}
+	logger.AddInput("mongo", "list_todos_of_list", len(todos))
+	return todos, nil
+}
+
+// neighbours looks up ranks in listID, leaving out the todo being moved.
+func (g *MongoStore) neighbours(ctx context.Context, listID, id string) neighbours {
+	first := func(cond bson.D, order int) (string, error) {
+		filter := append(bson.D{
+			{Key: "list_id", Value: listID},
+			{Key: "id", Value: bson.D{{Key: "$ne", Value: id}}},
+		}, cond...)
+		opts := options.FindOne().SetSort(bson.D{{Key: "rank", Value: order}})
+
+		var todo model.Todo
+		err := g.Collection.FindOne(ctx, filter, opts).Decode(&todo)
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return todo.Rank, err
+	}
+
+	return neighbours{
+		rankOf: func(other string) (string, error) {
+			var todo model.Todo
+			if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: other}}).Decode(&todo); err != nil {
+				return "", err
+			}
+			if todo.ListID != listID {
+				return "", ErrNotInList
+			}
+			return todo.Rank, nil
+		},
+		last: func() (string, error) {
+			return first(nil, -1)
+		},
+		after: func(rank string) (string, error) {
+			return first(bson.D{{Key: "rank", Value: bson.D{{Key: "$gt", Value: rank}}}}, 1)
+		},
+		before: func(rank string) (string, error) {
+			return first(bson.D{{Key: "rank", Value: bson.D{{Key: "$lt", Value: rank}}}}, -1)
+		},
+	}
+}
+
+func (g *MongoStore) Reorder(id string, pos Position, logger logger.ILogDetail) (*model.Todo, error) {
+	logger.AddOutput("mongo", "reorder_todo", map[string]any{"id": id, "position": pos}).End()
+
+	var after model.Todo
+	err := g.withTx(func(ctx context.Context) error {
+		var before model.Todo
+		if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Decode(&before); err != nil {
+			return err
+		}
+
+		listID := pos.ListID
+		if listID == "" {
+			listID = before.ListID
+		}
+		if listID == "" {
+			return ErrNotInList
+		}
+		if err := g.lists.FindOne(ctx, bson.D{{Key: "id", Value: listID}}).Err(); err != nil {
+			return err
+		}
+
+		rank, err := g.neighbours(ctx, listID, id).place(pos)
+		if err != nil {
+			return err
+		}
+
+		after = before
+		after.ListID = listID
+		after.Rank = rank
+		after.UpdatedAt = time.Now()
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "list_id", Value: listID},
+			{Key: "rank", Value: rank},
+			{Key: "updated_at", Value: after.UpdatedAt},
+		}}}
+		r, err := g.Collection.UpdateOne(ctx, bson.D{{Key: "id", Value: id}}, update)
+		if err != nil {
+			return err
+		}
+		logger.AddInput("mongo", "reorder_todo", r)
+
+		return g.record(ctx, model.TodoUpdated, &before, &after, logger)
+	})
+	if err != nil {
+		logger.AddError("mongo", "reorder_todo", "input", nil, err)
+		return nil, err
+	}
+
+	after.Href = utils.GenHref(after.ID)
+	return &after, nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (g *MongoStore) AddMember(m *model.Membership, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "add_member", m).End()
+
+	if err := g.addMember(ctx, m); err != nil {
+		logger.AddError("mongo", "add_member", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "add_member", m.Role)
+	return nil
+}
+
+func (g *MongoStore) addMember(ctx context.Context, m *model.Membership) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	filter := bson.D{{Key: "list_id", Value: m.ListID}, {Key: "user_id", Value: m.UserID}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "role", Value: m.Role}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: m.CreatedAt}}},
+	}
+	_, err := g.members.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (g *MongoStore) RemoveMember(listID, userID string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "list_id", Value: listID}, {Key: "user_id", Value: userID}}
+	logger.AddOutput("mongo", "remove_member", filter).End()
+
+	r, err := g.members.DeleteOne(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "remove_member", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "remove_member", r)
+	return nil
+}
+
+func (g *MongoStore) Members(listID string, logger logger.ILogDetail) ([]model.Membership, error) {
+	return g.memberships("list_members", bson.D{{Key: "list_id", Value: listID}}, logger)
+}
+
+func (g *MongoStore) Memberships(userID string, logger logger.ILogDetail) ([]model.Membership, error) {
+	return g.memberships("list_memberships", bson.D{{Key: "user_id", Value: userID}}, logger)
+}
+
+func (g *MongoStore) memberships(cmd string, filter bson.D, logger logger.ILogDetail) ([]model.Membership, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", cmd, filter).End()
+
+	cur, err := g.members.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+
+	members := []model.Membership{}
+	if err := cur.All(ctx, &members); err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", cmd, len(members))
+	return members, nil
+}
+
+func (g *MongoStore) Role(listID, userID string, logger logger.ILogDetail) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "member_role", map[string]any{"list_id": listID, "user_id": userID}).End()
+
+	role, err := g.memberRole(ctx, listID, userID)
+	if err != nil {
+		logger.AddError("mongo", "member_role", "input", nil, err)
+		return "", err
+	}
+	logger.AddInput("mongo", "member_role", role)
+	return role, nil
+}
+
+func (g *MongoStore) memberRole(ctx context.Context, listID, userID string) (string, error) {
+	var m model.Membership
+	err := g.members.FindOne(ctx, bson.D{{Key: "list_id", Value: listID}, {Key: "user_id", Value: userID}}).Decode(&m)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	return m.Role, err
+}
+
+func (g *MongoStore) CreateInvitation(inv *model.Invitation, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	inv.ID = invitationID(inv.Token)
+	inv.CreatedAt = time.Now()
+	logger.AddOutput("mongo", "create_invitation", map[string]any{"list_id": inv.ListID, "role": inv.Role, "expires_at": inv.ExpiresAt}).End()
+
+	if _, err := g.invitations.InsertOne(ctx, inv); err != nil {
+		logger.AddError("mongo", "create_invitation", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_invitation", inv.ListID)
+	return nil
+}
+
+func (g *MongoStore) AcceptInvitation(token, userID string, logger logger.ILogDetail) (*model.Membership, error) {
+	logger.AddOutput("mongo", "accept_invitation", map[string]any{"user_id": userID}).End()
+
+	var m *model.Membership
+	err := g.withTx(func(ctx context.Context) error {
+		now := time.Now()
+		// claiming the invitation in one update lets only the first of
+		// concurrent accepts through
+		filter := bson.D{
+			{Key: "id", Value: invitationID(token)},
+			{Key: "accepted_at", Value: nil},
+			{Key: "expires_at", Value: bson.D{{Key: "$gte", Value: now}}},
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "accepted_by", Value: userID},
+			{Key: "accepted_at", Value: now},
+		}}}
+
+		var inv model.Invitation
+		err := g.invitations.FindOneAndUpdate(ctx, filter, update).Decode(&inv)
+		if err == mongo.ErrNoDocuments {
+			return ErrInvitation
+		}
+		if err != nil {
+			return err
+		}
+
+		role, err := g.memberRole(ctx, inv.ListID, userID)
+		if err != nil {
+			return err
+		}
+		m = joined(&inv, userID, role, now)
+		if m.Role == role {
+			return nil
+		}
+		return g.addMember(ctx, m)
+	})
+	if err != nil {
+		logger.AddError("mongo", "accept_invitation", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "accept_invitation", m)
+	return m, nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoOutboxStore struct {
+	*mongo.Collection
+}
+
+func NewMongoOutboxStore(db *mongo.Database) *MongoOutboxStore {
+	return &MongoOutboxStore{db.Collection("outbox")}
+}
+
+func (m *MongoOutboxStore) PendingOutbox(limit int, logger logger.ILogDetail) ([]model.OutboxEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "status", Value: model.OutboxPending}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}}).SetLimit(int64(limit))
+	logger.AddOutput("mongo", "pending_outbox", map[string]any{"filter": filter, "limit": limit}).End()
+
+	cur, err := m.Find(ctx, filter, opts)
+	if err != nil {
+		logger.AddError("mongo", "pending_outbox", "input", nil, err)
+		return nil, err
+	}
+
+	var entries []model.OutboxEntry
+	if err := cur.All(ctx, &entries); err != nil {
+		logger.AddError("mongo", "pending_outbox", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "pending_outbox", len(entries))
+	return entries, nil
+}
+
+func (m *MongoOutboxStore) MarkPublished(entry *model.OutboxEntry, logger logger.ILogDetail) error {
+	now := time.Now()
+	entry.Status = model.OutboxPublished
+	entry.PublishedAt = &now
+	entry.Attempts++
+	return m.save("publish_outbox", entry, logger)
+}
+
+func (m *MongoOutboxStore) MarkFailed(entry *model.OutboxEntry, cause error, logger logger.ILogDetail) error {
+	entry.Attempts++
+	entry.LastError = cause.Error()
+	return m.save("fail_outbox", entry, logger)
+}
+
+func (m *MongoOutboxStore) save(cmd string, entry *model.OutboxEntry, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: entry.ID}}
+	logger.AddOutput("mongo", cmd, map[string]any{"id": entry.ID, "attempts": entry.Attempts}).End()
+
+	r, err := m.ReplaceOne(ctx, filter, entry)
+	if err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", cmd, r)
+	return nil
+}
+
+func (m *MongoOutboxStore) CountOutbox(status string, logger logger.ILogDetail) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "status", Value: status}}
+	logger.AddOutput("mongo", "count_outbox", filter).End()
+
+	n, err := m.CountDocuments(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "count_outbox", "input", nil, err)
+		return 0, err
+	}
+	logger.AddInput("mongo", "count_outbox", n)
+	return n, nil
+}
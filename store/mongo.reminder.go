@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoReminderStore struct {
+	todos     *mongo.Collection
+	leases    *mongo.Collection
+	reminders *mongo.Collection
+}
+
+func NewMongoReminderStore(db *mongo.Database) *MongoReminderStore {
+	return &MongoReminderStore{
+		todos:     db.Collection("todos"),
+		leases:    db.Collection("leases"),
+		reminders: db.Collection("reminders"),
+	}
+}
+
+func (m *MongoReminderStore) AcquireLease(name, holder string, ttl time.Duration, logger logger.ILogDetail) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	// renew our own or take over an expired lease, the upsert of a lease
+	// held by someone else fails on the unique name
+	filter := bson.D{
+		{Key: "name", Value: name},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "holder", Value: holder}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "holder", Value: holder},
+		{Key: "expires_at", Value: now.Add(ttl)},
+	}}}
+	logger.AddOutput("mongo", "acquire_lease", map[string]any{"filter": filter, "update": update}).End()
+
+	_, err := m.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		logger.AddInput("mongo", "acquire_lease", false)
+		return false, nil
+	}
+	if err != nil {
+		logger.AddError("mongo", "acquire_lease", "input", nil, err)
+		return false, err
+	}
+	logger.AddInput("mongo", "acquire_lease", true)
+	return true, nil
+}
+
+func (m *MongoReminderStore) ReleaseLease(name, holder string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "name", Value: name}, {Key: "holder", Value: holder}}
+	logger.AddOutput("mongo", "release_lease", filter).End()
+
+	r, err := m.leases.DeleteOne(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "release_lease", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "release_lease", r)
+	return nil
+}
+
+func (m *MongoReminderStore) DueTodos(now, from, to time.Time, limit int, logger logger.ILogDetail) ([]model.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "deleted_at", Value: nil},
+		{Key: "completed", Value: false},
+		{Key: "due_at", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+	}
+	// skip the todos reminded of already, else they fill every batch and
+	// the ones behind them are never reached
+	kind := bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$gt", Value: bson.A{"$$due_at", now}}},
+		model.ReminderDueSoon,
+		model.ReminderOverdue,
+	}}}
+	reminded := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: m.reminders.Name()},
+		{Key: "let", Value: bson.D{{Key: "todo_id", Value: "$id"}, {Key: "due_at", Value: "$due_at"}}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$todo_id", "$$todo_id"}}},
+				bson.D{{Key: "$eq", Value: bson.A{"$due_at", "$$due_at"}}},
+				bson.D{{Key: "$eq", Value: bson.A{"$kind", kind}}},
+			}}}}}}},
+			bson.D{{Key: "$limit", Value: 1}},
+		}},
+		{Key: "as", Value: "reminded"},
+	}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "due_at", Value: 1}}}},
+		reminded,
+		{{Key: "$match", Value: bson.D{{Key: "reminded", Value: bson.D{{Key: "$size", Value: 0}}}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.D{{Key: "reminded", Value: 0}}}},
+	}
+	logger.AddOutput("mongo", "due_todo", map[string]any{"pipeline": pipeline}).End()
+
+	cur, err := m.todos.Aggregate(ctx, pipeline)
+	if err != nil {
+		logger.AddError("mongo", "due_todo", "input", nil, err)
+		return nil, err
+	}
+
+	var todos []model.Todo
+	if err := cur.All(ctx, &todos); err != nil {
+		logger.AddError("mongo", "due_todo", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "due_todo", len(todos))
+	return todos, nil
+}
+
+func (m *MongoReminderStore) ClaimReminder(r *model.Reminder, logger logger.ILogDetail) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "claim_reminder", r).End()
+
+	_, err := m.reminders.InsertOne(ctx, r)
+	if mongo.IsDuplicateKeyError(err) {
+		logger.AddInput("mongo", "claim_reminder", "duplicate")
+		return false, nil
+	}
+	if err != nil {
+		logger.AddError("mongo", "claim_reminder", "input", nil, err)
+		return false, err
+	}
+	logger.AddInput("mongo", "claim_reminder", r.ID)
+	return true, nil
+}
+
+func (m *MongoReminderStore) ReleaseReminder(id string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: id}}
+	logger.AddOutput("mongo", "release_reminder", filter).End()
+
+	r, err := m.reminders.DeleteOne(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "release_reminder", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "release_reminder", r)
+	return nil
+}
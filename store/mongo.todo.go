@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/sing3demons/todoapi/logger"
@@ -15,24 +16,125 @@ import (
 
 type MongoStore struct {
 	*mongo.Collection
+	outbox      *mongo.Collection
+	audit       *mongo.Collection
+	deps        *mongo.Collection
+	lists       *mongo.Collection
+	members     *mongo.Collection
+	invitations *mongo.Collection
+	comments    *mongo.Collection
+
+	txOnce sync.Once
+	txOK   bool
 }
 
 func NewMongoStore(db *mongo.Collection) *MongoStore {
-	return &MongoStore{db}
+	return &MongoStore{
+		Collection:  db,
+		outbox:      db.Database().Collection("outbox"),
+		audit:       db.Database().Collection("todo_audit"),
+		deps:        db.Database().Collection("todo_dependencies"),
+		lists:       db.Database().Collection("lists"),
+		members:     db.Database().Collection("list_members"),
+		invitations: db.Database().Collection("list_invitations"),
+		comments:    db.Database().Collection("todo_comments"),
+	}
+}
+
+// withTx runs fn in a transaction so a change and its audit and outbox
+// entries are written together. Standalone servers have no transactions, there the
+// writes just run one after the other.
+func (g *MongoStore) withTx(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if !g.transactions(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := g.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// transactions reports whether the server is a replica set member or a
+// mongos, the deployments that support transactions.
+func (g *MongoStore) transactions(ctx context.Context) bool {
+	g.txOnce.Do(func() {
+		var hello struct {
+			SetName string `bson:"setName"`
+			Msg     string `bson:"msg"`
+		}
+		err := g.Database().RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+		g.txOK = err == nil && (hello.SetName != "" || hello.Msg == "isdbgrid")
+	})
+	return g.txOK
+}
+
+// record writes the audit entry and the outbox entry of a change. A nil
+// before is a create, a nil after a delete.
+func (g *MongoStore) record(ctx context.Context, event string, before, after *model.Todo, logger logger.ILogDetail) error {
+	audit := newAuditEntry(event, before, after, logger.Actor())
+	logger.AddOutput("mongo", "create_audit", map[string]any{"id": audit.ID, "action": event, "todo_id": audit.TodoID}).End()
+	r, err := g.audit.InsertOne(ctx, audit)
+	if err != nil {
+		logger.AddError("mongo", "create_audit", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_audit", r)
+
+	todo := after
+	if todo == nil {
+		todo = before
+	}
+	entry := newOutboxEntry(event, todo)
+	logger.AddOutput("mongo", "create_outbox", map[string]any{"id": entry.ID, "event": event, "todo_id": todo.ID}).End()
+	r, err = g.outbox.InsertOne(ctx, entry)
+	if err != nil {
+		logger.AddError("mongo", "create_outbox", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_outbox", r)
+	return nil
 }
 
 func (g *MongoStore) Create(todo *model.Todo, logger logger.ILogDetail) error {
+	return g.withTx(func(ctx context.Context) error {
+		return g.create(ctx, todo, logger)
+	})
+}
+
+func (g *MongoStore) create(ctx context.Context, todo *model.Todo, logger logger.ILogDetail) error {
 	todo.ID = primitive.NewObjectID().Hex()
 	todo.CreatedAt = time.Now()
 	todo.UpdatedAt = time.Now()
 	todo.DeletedAt = nil
+	startSeries(todo)
+	if todo.ListID != "" {
+		// new todos go to the end of their list
+		rank, err := g.neighbours(ctx, todo.ListID, todo.ID).place(Position{})
+		if err != nil {
+			return err
+		}
+		todo.Rank = rank
+	}
 
 	store := Store{
 		mongo:  g.Collection,
 		logger: logger,
+		ctx:    ctx,
 	}
-
-	return store.Create("create_todo", "todo", "InsertOne", todo)
+	if err := store.Create("create_todo", "todo", "InsertOne", todo); err != nil {
+		return err
+	}
+	return g.record(ctx, model.TodoCreated, nil, todo, logger)
 }
 
 func (g *MongoStore) List(opt FindOption, logger logger.ILogDetail) ([]model.Todo, error) {
@@ -63,10 +165,6 @@ func (g *MongoStore) List(opt FindOption, logger logger.ILogDetail) ([]model.Tod
 }
 
 func (g *MongoStore) Delete(id string, logger logger.ILogDetail) error {
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
 	filter := bson.D{
 		{Key: "deleted_at", Value: nil},
 		{Key: "id", Value: id},
@@ -74,14 +172,26 @@ func (g *MongoStore) Delete(id string, logger logger.ILogDetail) error {
 
 	logger.AddOutput("mongo", "delete_todo", filter).End()
 
-	r, err := g.Collection.DeleteOne(ctx, filter)
-	if err != nil {
-		logger.AddError("mongo", "delete_todo", "input", nil, err)
-		return err
-	}
+	return g.withTx(func(ctx context.Context) error {
+		// the deleted todo goes in the outbox entry
+		var todo model.Todo
+		err := g.Collection.FindOneAndDelete(ctx, filter).Decode(&todo)
+		if err == mongo.ErrNoDocuments {
+			logger.AddInput("mongo", "delete_todo", map[string]any{"DeletedCount": 0})
+			return nil
+		}
+		if err != nil {
+			logger.AddError("mongo", "delete_todo", "input", nil, err)
+			return err
+		}
 
-	logger.AddInput("mongo", "delete_todo", r)
-	return nil
+		logger.AddInput("mongo", "delete_todo", map[string]any{"DeletedCount": 1})
+		if err := g.detach(ctx, &todo); err != nil {
+			logger.AddError("mongo", "delete_todo", "input", nil, err)
+			return err
+		}
+		return g.record(ctx, model.TodoDeleted, &todo, nil, logger)
+	})
 }
 
 func (g *MongoStore) FindOne(id string, logger logger.ILogDetail) (*model.Todo, error) {
@@ -101,3 +211,61 @@ func (g *MongoStore) FindOne(id string, logger logger.ILogDetail) (*model.Todo,
 	todo.Href = utils.GenHref(todo.ID)
 	return &todo, nil
 }
+
+// Update saves todo. Completing a recurring todo creates the next
+// occurrence, which carries the recurrence on from then.
+func (g *MongoStore) Update(todo *model.Todo, logger logger.ILogDetail) error {
+	stampUpdate(todo)
+	filter := bson.D{
+		{Key: "deleted_at", Value: nil},
+		{Key: "id", Value: todo.ID},
+	}
+
+	return g.withTx(func(ctx context.Context) error {
+		var before model.Todo
+		if err := g.Collection.FindOne(ctx, filter).Decode(&before); err != nil {
+			logger.AddError("mongo", "update_todo", "input", nil, err)
+			return err
+		}
+
+		after := applyUpdate(before, todo)
+		kind := model.UpdateKind(&before, &after)
+		var next *model.Todo
+		if kind == model.TodoCompleted {
+			next = nextOccurrence(&after)
+		}
+		if next != nil {
+			todo.Recurrence = ""
+			after.Recurrence = ""
+		}
+
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "title", Value: todo.Title},
+			{Key: "completed", Value: todo.Completed},
+			{Key: "completed_at", Value: todo.CompletedAt},
+			{Key: "due_at", Value: todo.DueAt},
+			{Key: "time_zone", Value: todo.TimeZone},
+			{Key: "recurrence", Value: todo.Recurrence},
+			{Key: "updated_at", Value: todo.UpdatedAt},
+		}}}
+		logger.AddOutput("mongo", "update_todo", map[string]any{
+			"filter": filter,
+			"update": update,
+		}).End()
+
+		r, err := g.Collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			logger.AddError("mongo", "update_todo", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "update_todo", r)
+
+		if err := g.record(ctx, kind, &before, &after, logger); err != nil {
+			return err
+		}
+		if next != nil {
+			return g.create(ctx, next, logger)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// graph follows edges from the todo id through the from collection with
+// $graphLookup and returns field of every document reached.
+func (g *MongoStore) graph(ctx context.Context, id, from, connectFrom, connectTo, field string) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "id", Value: id}}}},
+		{{Key: "$graphLookup", Value: bson.D{
+			{Key: "from", Value: from},
+			{Key: "startWith", Value: "$id"},
+			{Key: "connectFromField", Value: connectFrom},
+			{Key: "connectToField", Value: connectTo},
+			{Key: "as", Value: "reached"},
+		}}},
+		{{Key: "$project", Value: bson.D{{Key: "ids", Value: "$reached." + field}}}},
+	}
+
+	cur, err := g.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var res []struct {
+		IDs []string `bson:"ids"`
+	}
+	if err := cur.All(ctx, &res); err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[0].IDs, nil
+}
+
+func (g *MongoStore) subtree(ctx context.Context, id string) ([]string, error) {
+	return g.graph(ctx, id, g.Collection.Name(), "id", "parent_id", "id")
+}
+
+func (g *MongoStore) blockers(ctx context.Context, id string) ([]string, error) {
+	return g.graph(ctx, id, g.deps.Name(), "blocked_by_id", "todo_id", "blocked_by_id")
+}
+
+func (g *MongoStore) Children(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "deleted_at", Value: nil}, {Key: "parent_id", Value: id}}
+	if recursive {
+		ids, err := g.subtree(ctx, id)
+		if err != nil {
+			logger.AddError("mongo", "list_children", "input", nil, err)
+			return nil, err
+		}
+		filter = bson.D{{Key: "deleted_at", Value: nil}, {Key: "id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	}
+	return g.findTodos(ctx, "list_children", filter, logger)
+}
+
+func (g *MongoStore) Blockers(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var ids []string
+	var err error
+	if recursive {
+		ids, err = g.blockers(ctx, id)
+	} else {
+		ids, err = g.directBlockers(ctx, id)
+	}
+	if err != nil {
+		logger.AddError("mongo", "list_blockers", "input", nil, err)
+		return nil, err
+	}
+
+	filter := bson.D{{Key: "deleted_at", Value: nil}, {Key: "id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	return g.findTodos(ctx, "list_blockers", filter, logger)
+}
+
+func (g *MongoStore) directBlockers(ctx context.Context, id string) ([]string, error) {
+	cur, err := g.deps.Find(ctx, bson.D{{Key: "todo_id", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	var deps []model.Dependency
+	if err := cur.All(ctx, &deps); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(deps))
+	for i, d := range deps {
+		ids[i] = d.BlockedByID
+	}
+	return ids, nil
+}
+
+func (g *MongoStore) findTodos(ctx context.Context, cmd string, filter bson.D, logger logger.ILogDetail) ([]model.Todo, error) {
+	logger.AddOutput("mongo", cmd, filter).End()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cur, err := g.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+
+	todos := []model.Todo{}
+	if err := cur.All(ctx, &todos); err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Href = utils.GenHref(todos[i].ID)
+	}
+	logger.AddInput("mongo", cmd, len(todos))
+	return todos, nil
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *MongoStore) Move(id, parentID string, logger logger.ILogDetail) error {
+	logger.AddOutput("mongo", "move_todo", map[string]any{"id": id, "parent_id": parentID}).End()
+
+	return g.withTx(func(ctx context.Context) error {
+		var before model.Todo
+		if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Decode(&before); err != nil {
+			logger.AddError("mongo", "move_todo", "input", nil, err)
+			return err
+		}
+
+		if parentID != "" {
+			if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: parentID}}).Err(); err != nil {
+				logger.AddError("mongo", "move_todo", "input", nil, err)
+				return err
+			}
+			// the new parent can't be the todo or one of its descendants
+			ids, err := g.subtree(ctx, id)
+			if err == nil && (parentID == id || contains(ids, parentID)) {
+				err = ErrCycle
+			}
+			if err != nil {
+				logger.AddError("mongo", "move_todo", "input", nil, err)
+				return err
+			}
+		}
+
+		after := before
+		after.ParentID = parentID
+		after.UpdatedAt = time.Now()
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "parent_id", Value: parentID},
+			{Key: "updated_at", Value: after.UpdatedAt},
+		}}}
+		r, err := g.Collection.UpdateOne(ctx, bson.D{{Key: "id", Value: id}}, update)
+		if err != nil {
+			logger.AddError("mongo", "move_todo", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "move_todo", r)
+
+		return g.record(ctx, model.TodoUpdated, &before, &after, logger)
+	})
+}
+
+func (g *MongoStore) AddBlocker(id, blockerID string, logger logger.ILogDetail) error {
+	dep := model.Dependency{TodoID: id, BlockedByID: blockerID, CreatedAt: time.Now()}
+	logger.AddOutput("mongo", "add_blocker", dep).End()
+
+	return g.withTx(func(ctx context.Context) error {
+		for _, todoID := range []string{id, blockerID} {
+			if err := g.Collection.FindOne(ctx, bson.D{{Key: "id", Value: todoID}}).Err(); err != nil {
+				logger.AddError("mongo", "add_blocker", "input", nil, err)
+				return err
+			}
+		}
+
+		// the blocker can't already be waiting on the todo
+		ids, err := g.blockers(ctx, blockerID)
+		if err == nil && (blockerID == id || contains(ids, id)) {
+			err = ErrCycle
+		}
+		if err != nil {
+			logger.AddError("mongo", "add_blocker", "input", nil, err)
+			return err
+		}
+
+		_, err = g.deps.InsertOne(ctx, dep)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			logger.AddError("mongo", "add_blocker", "input", nil, err)
+			return err
+		}
+		logger.AddInput("mongo", "add_blocker", dep)
+		return nil
+	})
+}
+
+func (g *MongoStore) RemoveBlocker(id, blockerID string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "todo_id", Value: id}, {Key: "blocked_by_id", Value: blockerID}}
+	logger.AddOutput("mongo", "remove_blocker", filter).End()
+
+	r, err := g.deps.DeleteOne(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "remove_blocker", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "remove_blocker", r)
+	return nil
+}
+
+// detach lifts the subtasks of a deleted todo to its parent and drops its
+// dependencies and comments.
+func (g *MongoStore) detach(ctx context.Context, todo *model.Todo) error {
+	_, err := g.Collection.UpdateMany(ctx,
+		bson.D{{Key: "parent_id", Value: todo.ID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "parent_id", Value: todo.ParentID}}}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = g.deps.DeleteMany(ctx, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "todo_id", Value: todo.ID}},
+		bson.D{{Key: "blocked_by_id", Value: todo.ID}},
+	}}})
+	if err != nil {
+		return err
+	}
+	_, err = g.comments.DeleteMany(ctx, bson.D{{Key: "todo_id", Value: todo.ID}})
+	return err
+}
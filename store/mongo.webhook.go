@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWebhookStore struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+func NewMongoWebhookStore(db *mongo.Database) *MongoWebhookStore {
+	return &MongoWebhookStore{
+		webhooks:   db.Collection("webhooks"),
+		deliveries: db.Collection("webhook_deliveries"),
+	}
+}
+
+func (m *MongoWebhookStore) CreateWebhook(hook *model.Webhook, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "create_webhook", map[string]any{"id": hook.ID, "url": hook.URL, "events": hook.Events}).End()
+
+	r, err := m.webhooks.InsertOne(ctx, hook)
+	if err != nil {
+		logger.AddError("mongo", "create_webhook", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_webhook", r)
+	return nil
+}
+
+func (m *MongoWebhookStore) ListWebhooks(logger logger.ILogDetail) ([]model.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "list_webhook", "webhooks.find({})").End()
+
+	cur, err := m.webhooks.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		logger.AddError("mongo", "list_webhook", "input", nil, err)
+		return nil, err
+	}
+
+	var hooks []model.Webhook
+	if err := cur.All(ctx, &hooks); err != nil {
+		logger.AddError("mongo", "list_webhook", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "list_webhook", len(hooks))
+	return hooks, nil
+}
+
+func (m *MongoWebhookStore) FindWebhook(id string, logger logger.ILogDetail) (*model.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: id}}
+	logger.AddOutput("mongo", "find_one_webhook", filter).End()
+
+	var hook model.Webhook
+	if err := m.webhooks.FindOne(ctx, filter).Decode(&hook); err != nil {
+		logger.AddError("mongo", "find_one_webhook", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "find_one_webhook", hook.ID)
+	return &hook, nil
+}
+
+func (m *MongoWebhookStore) DeleteWebhook(id string, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: id}}
+	logger.AddOutput("mongo", "delete_webhook", filter).End()
+
+	r, err := m.webhooks.DeleteOne(ctx, filter)
+	if err != nil {
+		logger.AddError("mongo", "delete_webhook", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "delete_webhook", r)
+	return nil
+}
+
+func (m *MongoWebhookStore) CreateDelivery(d *model.Delivery, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", "create_delivery", map[string]any{"id": d.ID, "webhook_id": d.WebhookID, "event": d.Event}).End()
+
+	r, err := m.deliveries.InsertOne(ctx, d)
+	if mongo.IsDuplicateKeyError(err) {
+		logger.AddInput("mongo", "create_delivery", "duplicate")
+		return nil
+	}
+	if err != nil {
+		logger.AddError("mongo", "create_delivery", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "create_delivery", r)
+	return nil
+}
+
+func (m *MongoWebhookStore) UpdateDelivery(d *model.Delivery, logger logger.ILogDetail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	d.UpdatedAt = time.Now()
+	filter := bson.D{{Key: "id", Value: d.ID}}
+	logger.AddOutput("mongo", "update_delivery", map[string]any{"id": d.ID, "status": d.Status, "attempts": d.Attempts}).End()
+
+	r, err := m.deliveries.ReplaceOne(ctx, filter, d)
+	if err != nil {
+		logger.AddError("mongo", "update_delivery", "input", nil, err)
+		return err
+	}
+	logger.AddInput("mongo", "update_delivery", r)
+	return nil
+}
+
+func (m *MongoWebhookStore) FindDelivery(id string, logger logger.ILogDetail) (*model.Delivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{{Key: "id", Value: id}}
+	logger.AddOutput("mongo", "find_one_delivery", filter).End()
+
+	var d model.Delivery
+	if err := m.deliveries.FindOne(ctx, filter).Decode(&d); err != nil {
+		logger.AddError("mongo", "find_one_delivery", "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", "find_one_delivery", d.ID)
+	return &d, nil
+}
+
+func (m *MongoWebhookStore) ListDeliveries(opt DeliveryFilter, logger logger.ILogDetail) ([]model.Delivery, error) {
+	filter := bson.D{}
+	if opt.WebhookID != "" {
+		filter = append(filter, bson.E{Key: "webhook_id", Value: opt.WebhookID})
+	}
+	if opt.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: opt.Status})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if opt.Limit > 0 {
+		opts.SetLimit(int64(opt.Limit))
+	}
+
+	return m.findDeliveries("list_delivery", filter, opts, logger)
+}
+
+func (m *MongoWebhookStore) ClaimDeliveries(claim string, now time.Time, ttl time.Duration, limit int, logger logger.ILogDetail) ([]model.Delivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "status", Value: model.DeliveryPending},
+		{Key: "next_attempt", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "claimed_by", Value: claim},
+		{Key: "next_attempt", Value: now.Add(ttl)},
+	}}}
+	logger.AddOutput("mongo", "claim_delivery", map[string]any{"filter": filter, "update": update, "limit": limit}).End()
+
+	// each delivery is claimed on its own, a find and update is atomic
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt", Value: 1}}).
+		SetReturnDocument(options.After)
+	var deliveries []model.Delivery
+	for len(deliveries) < limit {
+		var d model.Delivery
+		err := m.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&d)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			logger.AddError("mongo", "claim_delivery", "input", nil, err)
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	logger.AddInput("mongo", "claim_delivery", len(deliveries))
+	return deliveries, nil
+}
+
+func (m *MongoWebhookStore) findDeliveries(cmd string, filter bson.D, opts *options.FindOptions, logger logger.ILogDetail) ([]model.Delivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	logger.AddOutput("mongo", cmd, map[string]any{"filter": filter}).End()
+
+	cur, err := m.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+
+	var deliveries []model.Delivery
+	if err := cur.All(ctx, &deliveries); err != nil {
+		logger.AddError("mongo", cmd, "input", nil, err)
+		return nil, err
+	}
+	logger.AddInput("mongo", cmd, len(deliveries))
+	return deliveries, nil
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// OutboxStorer is what the relay needs from the outbox. Entries themselves
+// are written by the todo stores, in the transaction of the change.
+type OutboxStorer interface {
+	// PendingOutbox returns the oldest unpublished entries in the order they
+	// were written.
+	PendingOutbox(limit int, logger logger.ILogDetail) ([]model.OutboxEntry, error)
+	MarkPublished(entry *model.OutboxEntry, logger logger.ILogDetail) error
+	MarkFailed(entry *model.OutboxEntry, cause error, logger logger.ILogDetail) error
+	CountOutbox(status string, logger logger.ILogDetail) (int64, error)
+}
+
+func newOutboxEntry(event string, todo *model.Todo) *model.OutboxEntry {
+	return &model.OutboxEntry{
+		ID:        uuid.New().String(),
+		Event:     event,
+		TodoID:    todo.ID,
+		Payload:   model.OutboxPayload(*todo),
+		Status:    model.OutboxPending,
+		CreatedAt: time.Now(),
+	}
+}
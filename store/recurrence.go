@@ -0,0 +1,50 @@
+package store
+
+import (
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/recur"
+)
+
+// startSeries makes a new recurring todo the first of its series.
+func startSeries(todo *model.Todo) {
+	if todo.Recurrence != "" && todo.SeriesID == "" {
+		todo.SeriesID = todo.ID
+		todo.SeriesStart = todo.DueAt
+	}
+}
+
+// nextOccurrence returns the todo that follows a recurring todo once it is
+// completed, nil when it doesn't recur or its series is over.
+func nextOccurrence(todo *model.Todo) *model.Todo {
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return nil
+	}
+	rule, err := recur.Parse(todo.Recurrence)
+	if err != nil {
+		return nil
+	}
+	loc, err := todo.Location()
+	if err != nil {
+		return nil
+	}
+
+	start := todo.DueAt
+	if todo.SeriesStart != nil {
+		start = todo.SeriesStart
+	}
+	due, ok := rule.Next(start.In(loc), todo.DueAt.In(loc))
+	if !ok {
+		return nil
+	}
+
+	return &model.Todo{
+		Title:       todo.Title,
+		UserID:      todo.UserID,
+		ListID:      todo.ListID,
+		DueAt:       &due,
+		TimeZone:    todo.TimeZone,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    todo.SeriesID,
+		SeriesStart: start,
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestNextOccurrenceAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// saturday 9:00 CET, the clocks go forward that night
+	due := time.Date(2026, 3, 28, 9, 0, 0, 0, berlin).UTC()
+	todo := &model.Todo{ID: "1", Title: "water plants", DueAt: &due, TimeZone: "Europe/Berlin", Recurrence: "FREQ=DAILY;COUNT=2"}
+	startSeries(todo)
+
+	next := nextOccurrence(todo)
+	if next == nil {
+		t.Fatal("want a next occurrence")
+	}
+	if got := next.DueAt.In(berlin).Format(time.RFC3339); got != "2026-03-29T09:00:00+02:00" {
+		t.Errorf("want 9:00 CEST, got %s", got)
+	}
+	if next.SeriesID != "1" || !next.SeriesStart.Equal(due) {
+		t.Errorf("want the series carried on, got %+v", next)
+	}
+
+	if last := nextOccurrence(next); last != nil {
+		t.Errorf("want the series over after COUNT=2, got %v", last.DueAt)
+	}
+}
+
+func TestCompletingRecurringTodo(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	s := NewGormStore(db)
+	log := logger.New(slog.Default(), "", nil)
+
+	due := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	todo := &model.Todo{Title: "standup", DueAt: &due, Recurrence: "FREQ=WEEKLY;BYDAY=MO,WE"}
+	if err := s.Create(todo, log); err != nil {
+		t.Fatal(err)
+	}
+
+	todo.Completed = true
+	if err := s.Update(todo, log); err != nil {
+		t.Fatal(err)
+	}
+	if todo.Recurrence != "" {
+		t.Error("want the completed todo to hand the recurrence on")
+	}
+
+	var next model.Todo
+	if err := db.First(&next, "series_id = ? AND id <> ?", todo.ID, todo.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := due.AddDate(0, 0, 2); !next.DueAt.Equal(want) || next.Completed || next.Recurrence == "" {
+		t.Errorf("want an open todo due %s, got %+v", want, next)
+	}
+
+	// completing it again doesn't start another occurrence
+	todo.Completed = false
+	s.Update(todo, log)
+	todo.Completed = true
+	s.Update(todo, log)
+
+	var n int64
+	db.Model(&model.Todo{}).Count(&n)
+	if n != 2 {
+		t.Errorf("want 2 todos, got %d", n)
+	}
+}
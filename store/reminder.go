@@ -0,0 +1,27 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+type ReminderStorer interface {
+	// AcquireLease takes or renews the lease name for holder until ttl from
+	// now. It reports false while another holder's lease hasn't expired.
+	AcquireLease(name, holder string, ttl time.Duration, logger logger.ILogDetail) (bool, error)
+	ReleaseLease(name, holder string, logger logger.ILogDetail) error
+
+	// DueTodos returns open todos due between from and to, soonest first,
+	// leaving out the ones already reminded of for their due date: with a
+	// due soon reminder when due after now, an overdue one otherwise.
+	DueTodos(now, from, to time.Time, limit int, logger logger.ILogDetail) ([]model.Todo, error)
+
+	// ClaimReminder records the reminder, reporting false when it was
+	// already recorded.
+	ClaimReminder(r *model.Reminder, logger logger.ILogDetail) (bool, error)
+	// ReleaseReminder forgets a claimed reminder that couldn't be sent, so
+	// it is tried again.
+	ReleaseReminder(id string, logger logger.ILogDetail) error
+}
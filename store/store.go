@@ -22,6 +22,32 @@ type Storer interface {
 	List(opt FindOption, logger logger.ILogDetail) ([]model.Todo, error)
 	Delete(id string, logger logger.ILogDetail) error
 	FindOne(id string, logger logger.ILogDetail) (*model.Todo, error)
+	Update(todo *model.Todo, logger logger.ILogDetail) error
+}
+
+// stampUpdate stamps the update and completion times of a todo about to be
+// saved.
+func stampUpdate(todo *model.Todo) {
+	todo.UpdatedAt = time.Now()
+	if !todo.Completed {
+		todo.CompletedAt = nil
+	} else if todo.CompletedAt == nil {
+		now := todo.UpdatedAt
+		todo.CompletedAt = &now
+	}
+}
+
+// applyUpdate returns before with the fields Update writes taken from todo.
+func applyUpdate(before model.Todo, todo *model.Todo) model.Todo {
+	after := before
+	after.Title = todo.Title
+	after.Completed = todo.Completed
+	after.CompletedAt = todo.CompletedAt
+	after.DueAt = todo.DueAt
+	after.TimeZone = todo.TimeZone
+	after.Recurrence = todo.Recurrence
+	after.UpdatedAt = todo.UpdatedAt
+	return after
 }
 
 type FindOption struct {
@@ -29,12 +55,43 @@ type FindOption struct {
 	CommandName string
 	SortItem    map[string]interface{}
 	SelectItem  []string
+	Limit       int
+	Offset      int
+	Scope       *Scope
+}
+
+// Scope limits a listing to what a user may see: todos outside any list
+// that are theirs or nobody's, and the todos of ListIDs.
+type Scope struct {
+	UserID  string
+	ListIDs []string
+}
+
+const scopeSQL = "(((list_id = '' OR list_id IS NULL) AND (user_id = '' OR user_id IS NULL OR user_id = ?)) OR list_id IN ?)"
+
+func (s *Scope) mongoFilter() bson.E {
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{
+			{Key: "list_id", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}},
+			{Key: "user_id", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", s.UserID}}}},
+		},
+		bson.D{{Key: "list_id", Value: bson.D{{Key: "$in", Value: append([]string{}, s.ListIDs...)}}}},
+	}}
 }
 
 type Store struct {
 	sql    *gorm.DB
 	mongo  *mongo.Collection
 	logger logger.ILogDetail
+	// ctx carries the mongo session of a transaction, if any
+	ctx context.Context
+}
+
+func (tx *Store) context() context.Context {
+	if tx.ctx != nil {
+		return tx.ctx
+	}
+	return context.Background()
 }
 
 type RequestLog struct {
@@ -67,7 +124,7 @@ func (tx *Store) Create(commandName, name, method string, data any) error {
 
 		tx.logger.AddOutput(node, commandName, reqLog).End()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		ctx, cancel := context.WithTimeout(tx.context(), 15*time.Second)
 		defer cancel()
 		r, err := tx.mongo.InsertOne(ctx, data)
 		if err != nil {
@@ -168,6 +225,9 @@ func buildMongoFilter(opt FindOption) bson.D {
 			filter = append(filter, bson.E{Key: k, Value: v})
 		}
 	}
+	if opt.Scope != nil {
+		filter = append(filter, opt.Scope.mongoFilter())
+	}
 	return filter
 }
 
@@ -189,6 +249,12 @@ func buildMongoFindOptions(opt FindOption) *options.FindOptions {
 			}
 		}
 	}
+	if opt.Limit > 0 {
+		opts.SetLimit(int64(opt.Limit))
+	}
+	if opt.Offset > 0 {
+		opts.SetSkip(int64(opt.Offset))
+	}
 	return opts
 }
 
@@ -210,6 +276,12 @@ func buildMongoRawData(name string, filter bson.D, opts *options.FindOptions) st
 		rawData = strings.Replace(rawData, "}", "", 1)
 		rawData = strings.Replace(rawData, ", ", "", 1)
 	}
+	if opts.Skip != nil {
+		rawData = fmt.Sprintf("%s.skip(%d)", rawData, *opts.Skip)
+	}
+	if opts.Limit != nil {
+		rawData = fmt.Sprintf("%s.limit(%d)", rawData, *opts.Limit)
+	}
 	fmt.Println("RawData=========================", rawData)
 	return rawData
 }
@@ -241,6 +313,9 @@ func (tx *Store) listFromSQL(commandName, name string, opt FindOption, data any,
 	reqLog.Body.Query = conds
 	reqLog.Body.Document = selectTodo
 	rawData := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectTodo, ","), name)
+	if opt.Scope != nil {
+		cond = append(cond, strings.Replace(strings.Replace(scopeSQL, "?", fmt.Sprintf("'%s'", opt.Scope.UserID), 1), "?", fmt.Sprintf("%q", opt.Scope.ListIDs), 1))
+	}
 	if cond != nil {
 		rawData = fmt.Sprintf("%s WHERE %s", rawData, strings.Join(cond, " and "))
 	}
@@ -248,12 +323,25 @@ func (tx *Store) listFromSQL(commandName, name string, opt FindOption, data any,
 	if order != nil {
 		rawData = fmt.Sprintf("%s order by %s", rawData, strings.Join(order, ","))
 	}
+
+	query := tx.sql.Select(selectTodo).Order(strings.Join(order, ","))
+	if opt.Scope != nil {
+		query = query.Where(scopeSQL, opt.Scope.UserID, append([]string{}, opt.Scope.ListIDs...))
+	}
+	if opt.Limit > 0 {
+		query = query.Limit(opt.Limit)
+		rawData = fmt.Sprintf("%s limit %d", rawData, opt.Limit)
+	}
+	if opt.Offset > 0 {
+		query = query.Offset(opt.Offset)
+		rawData = fmt.Sprintf("%s offset %d", rawData, opt.Offset)
+	}
 	reqLog.RawData = rawData
 
 	tx.logger.AddOutput(node, commandName, reqLog).End()
 	fmt.Println("List=========================", reqLog.RawData)
 
-	r := tx.sql.Select(selectTodo).Order(strings.Join(order, ",")).Find(&data, conds...)
+	r := query.Find(&data, conds...)
 	if err := r.Error; err != nil {
 		tx.logger.AddError(node, commandName, "input", nil, err)
 		return nil, err
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+// ErrCycle is returned for a move or dependency that would make a todo its
+// own ancestor or blocker.
+var ErrCycle = errors.New("would create a cycle")
+
+// TreeStorer relates todos to each other: subtasks through ParentID and
+// "blocked by" dependencies.
+type TreeStorer interface {
+	// Children returns the subtasks of id, with recursive their subtasks
+	// too.
+	Children(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error)
+	// Move makes id, and with it its subtree, a subtask of parentID. An
+	// empty parentID makes it a top level todo.
+	Move(id, parentID string, logger logger.ILogDetail) error
+
+	// Blockers returns the todos id is blocked by, with recursive the ones
+	// those are blocked by too.
+	Blockers(id string, recursive bool, logger logger.ILogDetail) ([]model.Todo, error)
+	AddBlocker(id, blockerID string, logger logger.ILogDetail) error
+	RemoveBlocker(id, blockerID string, logger logger.ILogDetail) error
+}
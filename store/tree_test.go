@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestStore(t *testing.T) (*GormStore, logger.ILogDetail) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.Invitation{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	return NewGormStore(db), logger.New(slog.Default(), "", nil)
+}
+
+func ids(todos []model.Todo) map[string]bool {
+	out := map[string]bool{}
+	for _, todo := range todos {
+		out[todo.ID] = true
+	}
+	return out
+}
+
+func TestSubtasks(t *testing.T) {
+	s, log := newTestStore(t)
+
+	// release > docs > changelog, release > tests
+	release := &model.Todo{Title: "release"}
+	s.Create(release, log)
+	docs := &model.Todo{Title: "docs", ParentID: release.ID}
+	s.Create(docs, log)
+	changelog := &model.Todo{Title: "changelog", ParentID: docs.ID}
+	s.Create(changelog, log)
+	tests := &model.Todo{Title: "tests", ParentID: release.ID}
+	s.Create(tests, log)
+
+	children, err := s.Children(release.ID, false, log)
+	if err != nil || len(children) != 2 || !ids(children)[docs.ID] || !ids(children)[tests.ID] {
+		t.Errorf("want docs and tests as children, got %v %v", children, err)
+	}
+	subtree, _ := s.Children(release.ID, true, log)
+	if len(subtree) != 3 || !ids(subtree)[changelog.ID] {
+		t.Errorf("want the whole subtree, got %v", subtree)
+	}
+
+	if err := s.Move(release.ID, changelog.ID, log); !errors.Is(err, ErrCycle) {
+		t.Errorf("moving under a descendant: want ErrCycle, got %v", err)
+	}
+	if err := s.Move(release.ID, release.ID, log); !errors.Is(err, ErrCycle) {
+		t.Errorf("moving under itself: want ErrCycle, got %v", err)
+	}
+	if err := s.Move(changelog.ID, "missing", log); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("moving under a missing todo: want not found, got %v", err)
+	}
+
+	if err := s.Move(changelog.ID, tests.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	if children, _ := s.Children(docs.ID, false, log); len(children) != 0 {
+		t.Errorf("want changelog moved away from docs, got %v", children)
+	}
+
+	// deleting tests lifts changelog up to release
+	if err := s.Delete(tests.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	moved, _ := s.FindOne(changelog.ID, log)
+	if moved.ParentID != release.ID {
+		t.Errorf("want changelog under release, got parent %q", moved.ParentID)
+	}
+}
+
+func TestBlockers(t *testing.T) {
+	s, log := newTestStore(t)
+
+	deploy := &model.Todo{Title: "deploy"}
+	build := &model.Todo{Title: "build"}
+	test := &model.Todo{Title: "test"}
+	for _, todo := range []*model.Todo{deploy, build, test} {
+		s.Create(todo, log)
+	}
+
+	// deploy waits on test, which waits on build
+	if err := s.AddBlocker(deploy.ID, test.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBlocker(test.ID, build.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBlocker(test.ID, build.ID, log); err != nil {
+		t.Errorf("adding a dependency twice: %v", err)
+	}
+
+	direct, _ := s.Blockers(deploy.ID, false, log)
+	if len(direct) != 1 || direct[0].ID != test.ID {
+		t.Errorf("want deploy blocked by test, got %v", direct)
+	}
+	all, _ := s.Blockers(deploy.ID, true, log)
+	if len(all) != 2 || !ids(all)[build.ID] {
+		t.Errorf("want deploy blocked by test and build, got %v", all)
+	}
+
+	if err := s.AddBlocker(build.ID, deploy.ID, log); !errors.Is(err, ErrCycle) {
+		t.Errorf("want ErrCycle, got %v", err)
+	}
+	if err := s.AddBlocker(build.ID, build.ID, log); !errors.Is(err, ErrCycle) {
+		t.Errorf("blocked by itself: want ErrCycle, got %v", err)
+	}
+
+	if err := s.RemoveBlocker(test.ID, build.ID, log); err != nil {
+		t.Fatal(err)
+	}
+	s.Delete(test.ID, log)
+	if all, _ := s.Blockers(deploy.ID, true, log); len(all) != 0 {
+		t.Errorf("want no blockers left, got %v", all)
+	}
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+)
+
+type WebhookStorer interface {
+	CreateWebhook(*model.Webhook, logger.ILogDetail) error
+	ListWebhooks(logger.ILogDetail) ([]model.Webhook, error)
+	FindWebhook(id string, logger logger.ILogDetail) (*model.Webhook, error)
+	DeleteWebhook(id string, logger logger.ILogDetail) error
+
+	// CreateDelivery ignores deliveries whose id already exists, so every
+	// replica can enqueue the same event safely.
+	CreateDelivery(*model.Delivery, logger.ILogDetail) error
+	UpdateDelivery(*model.Delivery, logger.ILogDetail) error
+	FindDelivery(id string, logger logger.ILogDetail) (*model.Delivery, error)
+	ListDeliveries(opt DeliveryFilter, logger logger.ILogDetail) ([]model.Delivery, error)
+	// ClaimDeliveries takes up to limit pending deliveries due at now for
+	// the claim until now+ttl and returns them. Their next attempt moves to
+	// the end of the claim, so no other replica takes them meanwhile and
+	// the ones of a replica that died are due again once it lapses. claim
+	// must differ between calls.
+	ClaimDeliveries(claim string, now time.Time, ttl time.Duration, limit int, logger logger.ILogDetail) ([]model.Delivery, error)
+}
+
+type DeliveryFilter struct {
+	WebhookID string
+	Status    string
+	Limit     int
+}
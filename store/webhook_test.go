@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestClaimDeliveries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webhook.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Delivery{})
+	s, log := NewGormWebhookStore(db), logger.New(slog.Default(), "", nil)
+
+	now := time.Now()
+	for i, next := range []time.Time{now.Add(-3 * time.Second), now.Add(-2 * time.Second), now.Add(-time.Second), now.Add(time.Hour)} {
+		s.CreateDelivery(&model.Delivery{ID: fmt.Sprint(i), Status: model.DeliveryPending, NextAttempt: next, CreatedAt: now.Add(time.Duration(i))}, log)
+	}
+	s.CreateDelivery(&model.Delivery{ID: "dead", Status: model.DeliveryDead, NextAttempt: now.Add(-time.Hour)}, log)
+
+	claim := func(name string, at time.Time, limit int) []string {
+		t.Helper()
+		deliveries, err := s.ClaimDeliveries(name, at, time.Minute, limit, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range deliveries {
+			if d.ClaimedBy != name {
+				t.Errorf("want %s claimed by %s, got %s", d.ID, name, d.ClaimedBy)
+			}
+			got = append(got, d.ID)
+		}
+		return got
+	}
+
+	if got := claim("a", now, 2); fmt.Sprint(got) != "[0 1]" {
+		t.Errorf("want the 2 most overdue, got %v", got)
+	}
+	if got := claim("b", now, 10); fmt.Sprint(got) != "[2]" {
+		t.Errorf("want the one left, got %v", got)
+	}
+	if got := claim("c", now, 10); len(got) != 0 {
+		t.Errorf("want nothing left to claim, got %v", got)
+	}
+	// the claims lapse together with the one not yet due
+	if got := claim("d", now.Add(2*time.Hour), 10); fmt.Sprint(got) != "[0 1 2 3]" {
+		t.Errorf("want the lapsed claims taken over, got %v", got)
+	}
+}
@@ -0,0 +1,200 @@
+package todo
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+// access decides what callers may do with todos and lists. A todo outside
+// any list belongs to its creator, or to everyone when it has none. A todo
+// in a list takes the caller's role on the list. Without a member store
+// everything is allowed.
+type access struct {
+	members store.MemberStorer
+}
+
+func newAccess(s any) access {
+	members, _ := s.(store.MemberStorer)
+	return access{members: members}
+}
+
+// listRole returns the role of user on a list, empty when they have none.
+func (a access) listRole(listID, user string, logger logger.ILogDetail) (string, error) {
+	if a.members == nil {
+		return model.RoleOwner, nil
+	}
+	if user == "" {
+		return "", nil
+	}
+	return a.members.Role(listID, user, logger)
+}
+
+// todoRole returns the role of user on a todo, empty when they have none.
+func (a access) todoRole(todo *model.Todo, user string, logger logger.ILogDetail) (string, error) {
+	if todo.ListID != "" {
+		return a.listRole(todo.ListID, user, logger)
+	}
+	if a.members == nil || todo.UserID == "" || todo.UserID == user {
+		return model.RoleOwner, nil
+	}
+	return "", nil
+}
+
+// scope limits listings to the todos user may see.
+func (a access) scope(user string, logger logger.ILogDetail) (*store.Scope, error) {
+	if a.members == nil {
+		return nil, nil
+	}
+	scope := &store.Scope{UserID: user}
+	if user == "" {
+		return scope, nil
+	}
+	memberships, err := a.members.Memberships(user, logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range memberships {
+		scope.ListIDs = append(scope.ListIDs, m.ListID)
+	}
+	return scope, nil
+}
+
+// check answers the request when role doesn't allow want: with 404 when
+// the caller has no role at all, so they can't tell what exists, and 403
+// otherwise. It reports whether the caller may go on.
+func check(c router.IContext, role, want string, err error) bool {
+	switch {
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+	case role == "":
+		c.JSON(http.StatusNotFound, map[string]any{
+			"error": "not found",
+		})
+	case !model.RoleAllows(role, want):
+		c.JSON(http.StatusForbidden, map[string]any{
+			"error": want + " role required",
+		})
+	default:
+		return true
+	}
+	return false
+}
+
+// authorizeTodo checks the caller may do want with todo.
+func (a access) authorizeTodo(c router.IContext, todo *model.Todo, want string, logger logger.ILogDetail) bool {
+	role, err := a.todoRole(todo, c.Header(router.UserHeader), logger)
+	return check(c, role, want, err)
+}
+
+// authorizeList checks the caller may do want with a list.
+func (a access) authorizeList(c router.IContext, listID, want string, logger logger.ILogDetail) bool {
+	role, err := a.listRole(listID, c.Header(router.UserHeader), logger)
+	return check(c, role, want, err)
+}
+
+var (
+	// ErrNotFound is returned by Access when the todo doesn't exist or the
+	// caller has no role on it, so they can't tell the two apart.
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned by Access when the caller's role doesn't
+	// allow what they asked for.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Access applies the same rules to the APIs that don't answer through a
+// router.IContext: GraphQL, gRPC and the event feeds.
+type Access struct {
+	access
+}
+
+func NewAccess(s any) Access {
+	return Access{newAccess(s)}
+}
+
+// Scope limits listings to the todos user may see.
+func (a Access) Scope(user string, logger logger.ILogDetail) (*store.Scope, error) {
+	return a.scope(user, logger)
+}
+
+// Authorize checks user may do want with todo.
+func (a Access) Authorize(todo *model.Todo, user, want string, logger logger.ILogDetail) error {
+	role, err := a.todoRole(todo, user, logger)
+	return allows(role, want, err)
+}
+
+func allows(role, want string, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case role == "":
+		return ErrNotFound
+	case !model.RoleAllows(role, want):
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Find loads todo id from s when user may do want with it.
+func (a Access) Find(s store.Storer, id, user, want string, logger logger.ILogDetail) (*model.Todo, error) {
+	todo, err := s.FindOne(id, logger)
+	if errStatus(err) == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Authorize(todo, user, want, logger); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// ViewerTTL is how long a Viewer trusts the role it looked up on a list.
+const ViewerTTL = time.Minute
+
+// Viewer authorizes the todos of a stream for one caller. It remembers
+// their role on each list for ViewerTTL rather than looking it up for
+// every event, and logs the lookups on the logger it was made with.
+type Viewer struct {
+	access access
+	user   string
+	logger logger.ILogDetail
+	roles  map[string]cachedRole
+}
+
+type cachedRole struct {
+	role    string
+	expires time.Time
+}
+
+// Viewer makes the Viewer of user, logger must not be the one of the
+// request: streams outlive it.
+func (a Access) Viewer(user string, logger logger.ILogDetail) *Viewer {
+	return &Viewer{access: a.access, user: user, logger: logger, roles: map[string]cachedRole{}}
+}
+
+// Authorize checks the viewer may do want with todo.
+func (v *Viewer) Authorize(todo *model.Todo, want string, now time.Time) error {
+	if todo.ListID == "" || v.access.members == nil || v.user == "" {
+		role, err := v.access.todoRole(todo, v.user, v.logger)
+		return allows(role, want, err)
+	}
+	cached, ok := v.roles[todo.ListID]
+	if !ok || !now.Before(cached.expires) {
+		role, err := v.access.listRole(todo.ListID, v.user, v.logger)
+		if err != nil {
+			return err
+		}
+		cached = cachedRole{role: role, expires: now.Add(ViewerTTL)}
+		v.roles[todo.ListID] = cached
+	}
+	return allows(cached.role, want, nil)
+}
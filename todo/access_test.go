@@ -0,0 +1,273 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type authContext struct {
+	TestContext
+	user   string
+	params map[string]string
+	body   string
+	code   int
+	out    any
+}
+
+func (c *authContext) Header(name string) string {
+	if name == router.UserHeader {
+		return c.user
+	}
+	return ""
+}
+func (c *authContext) Param(name string) string { return c.params[name] }
+func (c *authContext) Query(string) string      { return "" }
+func (c *authContext) Bind(v any) error {
+	if c.body == "" {
+		return json.Unmarshal([]byte("{}"), v)
+	}
+	return json.Unmarshal([]byte(c.body), v)
+}
+func (c *authContext) JSON(code int, v any) {
+	c.code = code
+	c.out = v
+}
+
+// as runs handler for user and returns what it answered.
+func as(user string, handler func(router.IContext), params map[string]string, body string) *authContext {
+	c := &authContext{user: user, params: params, body: body}
+	handler(c)
+	return c
+}
+
+func newAuthStore(t *testing.T) *store.GormStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.Invitation{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	return store.NewGormStore(db)
+}
+
+func TestTodoAccess(t *testing.T) {
+	s := newAuthStore(t)
+	log := actorLog("alice")
+	todos := NewTodoHandler(s)
+
+	// alice owns the list, bob can view it and carol isn't in it
+	list := &model.List{Name: "release", UserID: "alice"}
+	s.CreateList(list, log)
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "bob", Role: model.RoleViewer}, log)
+
+	shared := &model.Todo{Title: "tag release", ListID: list.ID}
+	s.Create(shared, log)
+	private := &model.Todo{Title: "dentist", UserID: "alice"}
+	s.Create(private, log)
+	public := &model.Todo{Title: "lunch"}
+	s.Create(public, log)
+
+	id := func(todo *model.Todo) map[string]string { return map[string]string{"id": todo.ID} }
+
+	for _, tt := range []struct {
+		name    string
+		user    string
+		handler func(router.IContext)
+		params  map[string]string
+		body    string
+		want    int
+	}{
+		{"owner reads", "alice", todos.FindOne, id(shared), "", http.StatusOK},
+		{"viewer reads", "bob", todos.FindOne, id(shared), "", http.StatusOK},
+		{"outsider reads", "carol", todos.FindOne, id(shared), "", http.StatusNotFound},
+		{"anonymous reads", "", todos.FindOne, id(shared), "", http.StatusNotFound},
+		{"other user reads a private todo", "bob", todos.FindOne, id(private), "", http.StatusNotFound},
+		{"anyone reads an unowned todo", "carol", todos.FindOne, id(public), "", http.StatusOK},
+		{"viewer updates", "bob", todos.Update, id(shared), `{"completed":true}`, http.StatusForbidden},
+		{"outsider updates", "carol", todos.Update, id(shared), `{"completed":true}`, http.StatusNotFound},
+		{"owner updates", "alice", todos.Update, id(shared), `{"completed":true}`, http.StatusOK},
+		{"updating a missing todo", "alice", todos.Update, map[string]string{"id": "missing"}, `{"completed":true}`, http.StatusNotFound},
+		{"viewer adds to the list", "bob", todos.NewTask, nil, `{"text":"announce","list_id":"` + list.ID + `"}`, http.StatusForbidden},
+		{"outsider adds to the list", "carol", todos.NewTask, nil, `{"text":"announce","list_id":"` + list.ID + `"}`, http.StatusNotFound},
+		{"outsider adds to a missing list", "carol", todos.NewTask, nil, `{"text":"announce","list_id":"missing"}`, http.StatusNotFound},
+		{"adding under a missing parent", "carol", todos.NewTask, nil, `{"text":"announce","parent_id":"missing"}`, http.StatusNotFound},
+		{"adding under a private todo", "bob", todos.NewTask, nil, `{"text":"announce","parent_id":"` + private.ID + `"}`, http.StatusNotFound},
+		{"viewer reorders", "bob", todos.Reorder, id(shared), `{}`, http.StatusForbidden},
+		{"viewer deletes", "bob", todos.Delete, id(shared), "", http.StatusForbidden},
+		{"outsider deletes", "carol", todos.Delete, id(shared), "", http.StatusNotFound},
+		{"other user deletes a private todo", "bob", todos.Delete, id(private), "", http.StatusNotFound},
+		{"deleting a missing todo", "bob", todos.Delete, map[string]string{"id": "missing"}, "", http.StatusOK},
+	} {
+		if c := as(tt.user, tt.handler, tt.params, tt.body); c.code != tt.want {
+			t.Errorf("%s: want %d, got %d %v", tt.name, tt.want, c.code, c.out)
+		}
+	}
+
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "bob", Role: model.RoleEditor}, log)
+	if c := as("bob", todos.NewTask, nil, `{"text":"announce","list_id":"`+list.ID+`"}`); c.code != http.StatusCreated {
+		t.Errorf("editor adds to the list: want 201, got %d %v", c.code, c.out)
+	}
+	if c := as("alice", todos.Delete, id(shared), ""); c.code != http.StatusOK {
+		t.Errorf("owner deletes: want 200, got %d %v", c.code, c.out)
+	}
+}
+
+func TestListScope(t *testing.T) {
+	s := newAuthStore(t)
+	log := actorLog("alice")
+	todos := NewTodoHandler(s)
+
+	list := &model.List{Name: "release", UserID: "alice"}
+	s.CreateList(list, log)
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "bob", Role: model.RoleViewer}, log)
+	for _, todo := range []*model.Todo{
+		{Title: "tag release", ListID: list.ID},
+		{Title: "dentist", UserID: "alice"},
+		{Title: "gym", UserID: "bob"},
+		{Title: "lunch"},
+	} {
+		s.Create(todo, log)
+	}
+
+	for user, want := range map[string][]string{
+		"alice": {"tag release", "dentist", "lunch"},
+		"bob":   {"tag release", "gym", "lunch"},
+		"carol": {"lunch"},
+		"":      {"lunch"},
+	} {
+		c := as(user, todos.List, nil, "")
+		got := map[string]bool{}
+		for _, todo := range c.out.([]model.Todo) {
+			got[todo.Title] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("%q: want %v, got %v", user, want, got)
+			continue
+		}
+		for _, title := range want {
+			if !got[title] {
+				t.Errorf("%q: want %v, got %v", user, want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	s := newAuthStore(t)
+	log := actorLog("alice")
+	lists := NewListHandler(s, s)
+
+	c := as("alice", lists.Create, nil, `{"name":"release"}`)
+	if c.code != http.StatusCreated {
+		t.Fatalf("want 201, got %d %v", c.code, c.out)
+	}
+	list := c.out.(model.List)
+	id := map[string]string{"id": list.ID}
+
+	if c := as("", lists.Create, nil, `{"name":"anonymous"}`); c.code != http.StatusUnauthorized {
+		t.Errorf("anonymous creates a list: want 401, got %d", c.code)
+	}
+	if c := as("bob", lists.Invite, id, `{"role":"editor"}`); c.code != http.StatusNotFound {
+		t.Errorf("outsider invites: want 404, got %d", c.code)
+	}
+	if c := as("alice", lists.Invite, id, `{"role":"owner"}`); c.code != http.StatusBadRequest {
+		t.Errorf("inviting an owner: want 400, got %d", c.code)
+	}
+
+	c = as("alice", lists.Invite, id, `{"role":"editor"}`)
+	if c.code != http.StatusCreated {
+		t.Fatalf("want 201, got %d %v", c.code, c.out)
+	}
+	token := map[string]string{"token": c.out.(model.Invitation).Token}
+
+	if c := as("", lists.Accept, token, ""); c.code != http.StatusUnauthorized {
+		t.Errorf("anonymous accepts: want 401, got %d", c.code)
+	}
+	if c := as("bob", lists.Accept, token, ""); c.code != http.StatusOK || c.out.(*model.Membership).Role != model.RoleEditor {
+		t.Fatalf("want bob to join as editor, got %d %v", c.code, c.out)
+	}
+	if c := as("carol", lists.Accept, token, ""); c.code != http.StatusNotFound {
+		t.Errorf("reusing an invitation: want 404, got %d", c.code)
+	}
+
+	expired := model.Invitation{Token: "expired", ListID: list.ID, Role: model.RoleViewer, ExpiresAt: time.Now().Add(-time.Minute)}
+	s.CreateInvitation(&expired, log)
+	if c := as("carol", lists.Accept, map[string]string{"token": "expired"}, ""); c.code != http.StatusNotFound {
+		t.Errorf("expired invitation: want 404, got %d", c.code)
+	}
+
+	// accepting a viewer invitation doesn't demote an editor
+	viewer := model.Invitation{Token: "viewer", ListID: list.ID, Role: model.RoleViewer, ExpiresAt: time.Now().Add(time.Hour)}
+	s.CreateInvitation(&viewer, log)
+	if c := as("bob", lists.Accept, map[string]string{"token": "viewer"}, ""); c.code != http.StatusOK || c.out.(*model.Membership).Role != model.RoleEditor {
+		t.Errorf("want bob to stay editor, got %d %v", c.code, c.out)
+	}
+
+	if c := as("bob", lists.Update, id, `{"name":"renamed"}`); c.code != http.StatusForbidden {
+		t.Errorf("editor renames: want 403, got %d", c.code)
+	}
+	if c := as("bob", lists.SetMember, map[string]string{"id": list.ID, "user": "carol"}, `{"role":"viewer"}`); c.code != http.StatusForbidden {
+		t.Errorf("editor adds a member: want 403, got %d", c.code)
+	}
+	if c := as("alice", lists.SetMember, map[string]string{"id": list.ID, "user": "alice"}, `{"role":"viewer"}`); c.code != http.StatusConflict {
+		t.Errorf("demoting the creator: want 409, got %d", c.code)
+	}
+	if c := as("alice", lists.RemoveMember, map[string]string{"id": list.ID, "user": "alice"}, ""); c.code != http.StatusConflict {
+		t.Errorf("removing the creator: want 409, got %d", c.code)
+	}
+
+	c = as("bob", lists.List, nil, "")
+	if got := c.out.([]model.List); len(got) != 1 || got[0].ID != list.ID {
+		t.Errorf("want bob to see the list, got %v", c.out)
+	}
+	if c := as("bob", lists.RemoveMember, map[string]string{"id": list.ID, "user": "bob"}, ""); c.code != http.StatusOK {
+		t.Errorf("leaving a list: want 200, got %d %v", c.code, c.out)
+	}
+	if c := as("bob", lists.FindOne, id, ""); c.code != http.StatusNotFound {
+		t.Errorf("after leaving: want 404, got %d", c.code)
+	}
+}
+
+func TestViewer(t *testing.T) {
+	s := newAuthStore(t)
+	log := actorLog("alice")
+
+	list := &model.List{Name: "release", UserID: "alice"}
+	s.CreateList(list, log)
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "bob", Role: model.RoleViewer}, log)
+	shared := &model.Todo{Title: "tag release", ListID: list.ID}
+	private := &model.Todo{Title: "dentist", UserID: "alice"}
+
+	now := time.Now()
+	bob := NewAccess(s).Viewer("bob", log)
+	if err := bob.Authorize(shared, model.RoleViewer, now); err != nil {
+		t.Fatalf("want bob to see the list, got %v", err)
+	}
+	if err := bob.Authorize(shared, model.RoleEditor, now); err != ErrForbidden {
+		t.Errorf("want bob kept from editing, got %v", err)
+	}
+	if err := bob.Authorize(private, model.RoleViewer, now); err != ErrNotFound {
+		t.Errorf("want alice's own todo hidden from bob, got %v", err)
+	}
+
+	// the role is remembered until it expires
+	s.RemoveMember(list.ID, "bob", log)
+	if err := bob.Authorize(shared, model.RoleViewer, now.Add(ViewerTTL/2)); err != nil {
+		t.Errorf("want the cached role used, got %v", err)
+	}
+	if err := bob.Authorize(shared, model.RoleViewer, now.Add(ViewerTTL)); err != ErrNotFound {
+		t.Errorf("want the removal seen once the role expired, got %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/blob"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+const defaultMaxAttachmentSize = 10 << 20
+
+// defaultAttachmentTypes are screenshots, documents and plain text.
+var defaultAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain",
+}
+
+// attachmentStore answers 501 when attachments aren't configured or the
+// store can't keep them.
+func (t *TodoHandler) attachmentStore(c router.IContext) store.AttachmentStorer {
+	if t.Blobs == nil || t.attachments == nil {
+		c.JSON(http.StatusNotImplemented, map[string]any{
+			"error": "attachments are not supported by this server",
+		})
+		return nil
+	}
+	return t.attachments
+}
+
+// blobKey is where the content of an attachment is kept.
+func blobKey(todoID, attachmentID string) string {
+	return "todos/" + todoID + "/" + attachmentID
+}
+
+// sniff detects the content type of f from its first bytes, the type sent
+// by the client isn't trusted.
+func sniff(f io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// Upload attaches the file sent in the multipart field "file" to a todo.
+func (t *TodoHandler) Upload(c router.IContext) {
+	cmd := "upload attachment"
+	node := "client"
+	logger := c.Log("upload_attachment")
+	id := c.Param("id")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	// the body is the file, log what it is instead
+	logger.AddInput(node, cmd, map[string]any{
+		"params": map[string]any{"id": id},
+		"file": map[string]any{
+			"name":         file.Filename,
+			"size":         file.Size,
+			"content_type": file.Header.Get("Content-Type"),
+		},
+	})
+
+	attachments := t.attachmentStore(c)
+	if attachments == nil {
+		return
+	}
+	if !t.authorizeID(c, id, model.RoleEditor, logger) {
+		return
+	}
+
+	if file.Size > t.MaxAttachmentSize {
+		err := fmt.Errorf("file is larger than %d bytes", t.MaxAttachmentSize)
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "too_large",
+		}, err)
+		c.JSON(http.StatusRequestEntityTooLarge, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer f.Close()
+
+	contentType, err := sniff(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !slices.Contains(t.AttachmentTypes, mediaType) {
+		err := fmt.Errorf("content type %s is not allowed", mediaType)
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "unsupported_media_type",
+		}, err)
+		c.JSON(http.StatusUnsupportedMediaType, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	attachment := &model.Attachment{
+		ID:          uuid.New().String(),
+		Name:        filepath.Base(file.Filename),
+		ContentType: contentType,
+		Size:        file.Size,
+		UserID:      c.Header(router.UserHeader),
+		CreatedAt:   time.Now(),
+	}
+	key := blobKey(id, attachment.ID)
+
+	logger.AddOutput("blob", "put_blob", map[string]any{"key": key, "size": attachment.Size}).End()
+	if err := t.Blobs.Put(c.UserContext(), key, io.LimitReader(f, file.Size), file.Size, contentType); err != nil {
+		logger.AddError("blob", "put_blob", "input", nil, err)
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	logger.AddInput("blob", "put_blob", key)
+
+	if err := attachments.AddAttachment(id, attachment, logger); err != nil {
+		t.deleteBlob(c, key, logger)
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, attachment).End()
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// Download streams an attachment, a Range header asks for part of it.
+func (t *TodoHandler) Download(c router.IContext) {
+	cmd := "download attachment"
+	node := "client"
+	logger := c.Log("download_attachment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	if t.attachmentStore(c) == nil {
+		return
+	}
+
+	todo, err := t.store.FindOne(c.Param("id"), logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !t.access.authorizeTodo(c, todo, model.RoleViewer, logger) {
+		return
+	}
+
+	i := slices.IndexFunc(todo.Attachments, func(a model.Attachment) bool {
+		return a.ID == c.Param("attachment")
+	})
+	if i < 0 {
+		c.JSON(http.StatusNotFound, map[string]any{
+			"error": "attachment not found",
+		})
+		return
+	}
+	attachment := todo.Attachments[i]
+
+	code, offset, length := http.StatusOK, int64(0), int64(-1)
+	rng, err := blob.ParseRange(c.Header("Range"), attachment.Size)
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "range_not_satisfiable",
+		}, err)
+		c.SetHeader("Content-Range", "bytes */"+strconv.FormatInt(attachment.Size, 10))
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if rng != nil {
+		code, offset, length = http.StatusPartialContent, rng.Offset, rng.Length
+		c.SetHeader("Content-Range", rng.ContentRange(attachment.Size))
+	}
+
+	key := blobKey(todo.ID, attachment.ID)
+	logger.AddOutput("blob", "get_blob", map[string]any{"key": key, "offset": offset, "length": length}).End()
+	r, err := t.Blobs.Get(c.UserContext(), key, offset, length)
+	if err != nil {
+		logger.AddError("blob", "get_blob", "input", nil, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, blob.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	logger.AddInput("blob", "get_blob", key)
+
+	size := attachment.Size
+	if length >= 0 {
+		size = length
+	}
+	c.SetHeader("Accept-Ranges", "bytes")
+	c.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+
+	logger.AddOutput(node, cmd, map[string]any{"id": attachment.ID, "status": code, "size": size}).End()
+	c.SendReader(code, attachment.ContentType, size, r)
+}
+
+// DeleteAttachment removes an attachment and its content.
+func (t *TodoHandler) DeleteAttachment(c router.IContext) {
+	cmd := "delete attachment"
+	node := "client"
+	logger := c.Log("delete_attachment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	attachments := t.attachmentStore(c)
+	if attachments == nil {
+		return
+	}
+	id := c.Param("id")
+	if !t.authorizeID(c, id, model.RoleEditor, logger) {
+		return
+	}
+
+	removed, err := attachments.RemoveAttachment(id, c.Param("attachment"), logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	t.deleteBlob(c, blobKey(id, removed.ID), logger)
+
+	data := map[string]any{
+		"ID":     removed.ID,
+		"status": "success",
+	}
+	logger.AddOutput(node, cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// deleteBlobs removes the content of the attachments of a deleted todo.
+func (t *TodoHandler) deleteBlobs(c router.IContext, todo *model.Todo, logger logger.ILogDetail) {
+	if t.Blobs == nil || todo == nil {
+		return
+	}
+	for _, a := range todo.Attachments {
+		t.deleteBlob(c, blobKey(todo.ID, a.ID), logger)
+	}
+}
+
+// deleteBlob removes key from the blob store. The metadata is gone by then,
+// so a failure only leaves an orphaned blob behind and is just logged.
+func (t *TodoHandler) deleteBlob(c router.IContext, key string, logger logger.ILogDetail) {
+	logger.AddOutput("blob", "delete_blob", key).End()
+	if err := t.Blobs.Delete(c.UserContext(), key); err != nil {
+		logger.AddError("blob", "delete_blob", "input", nil, err)
+		return
+	}
+	logger.AddInput("blob", "delete_blob", key)
+}
@@ -0,0 +1,158 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/todoapi/blob"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+)
+
+type routes interface {
+	GET(path string, h func(router.IContext))
+	POST(path string, h func(router.IContext))
+	DELETE(path string, h func(router.IContext))
+}
+
+func upload(t *testing.T, path, name string, content []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set(router.UserHeader, "alice")
+	return req
+}
+
+func TestAttachments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+
+	for _, adapter := range []struct {
+		name string
+		new  func() (routes, func(*http.Request) *http.Response)
+	}{
+		{"fiber", func() (routes, func(*http.Request) *http.Response) {
+			r := router.NewFiberRouter(slog.Default())
+			return r, func(req *http.Request) *http.Response {
+				res, err := r.Test(req, -1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return res
+			}
+		}},
+		{"gin", func() (routes, func(*http.Request) *http.Response) {
+			r := router.NewMyRouter(slog.Default())
+			return r, func(req *http.Request) *http.Response {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w.Result()
+			}
+		}},
+	} {
+		t.Run(adapter.name, func(t *testing.T) {
+			s := newAuthStore(t)
+			todo := &model.Todo{Title: "bug report", UserID: "alice"}
+			s.Create(todo, actorLog("alice"))
+
+			blobs := blob.NewFSStore(t.TempDir())
+			h := NewTodoHandler(s)
+			h.Blobs = blobs
+			h.MaxAttachmentSize = 1024
+
+			r, do := adapter.new()
+			r.POST("/todo/:id/attachments", h.Upload)
+			r.GET("/todo/:id/attachments/:attachment", h.Download)
+			r.DELETE("/todo/:id/attachments/:attachment", h.DeleteAttachment)
+
+			path := "/todo/" + todo.ID + "/attachments"
+
+			for _, tt := range []struct {
+				name    string
+				file    string
+				content []byte
+				want    int
+			}{
+				{"html", "page.png", []byte("<html><body>hi</body></html>"), http.StatusUnsupportedMediaType},
+				{"too large", "big.txt", bytes.Repeat([]byte("a"), 2048), http.StatusRequestEntityTooLarge},
+			} {
+				if res := do(upload(t, path, tt.file, tt.content)); res.StatusCode != tt.want {
+					t.Errorf("%s: want %d, got %d", tt.name, tt.want, res.StatusCode)
+				}
+			}
+
+			res := do(upload(t, path, "screenshot.png", png))
+			if res.StatusCode != http.StatusCreated {
+				body, _ := io.ReadAll(res.Body)
+				t.Fatalf("want 201, got %d %s", res.StatusCode, body)
+			}
+			var attachment model.Attachment
+			json.NewDecoder(res.Body).Decode(&attachment)
+			if attachment.ContentType != "image/png" || attachment.Size != int64(len(png)) {
+				t.Errorf("want a png of %d bytes, got %+v", len(png), attachment)
+			}
+			stored, _ := s.FindOne(todo.ID, actorLog("alice"))
+			if len(stored.Attachments) != 1 || stored.Attachments[0].ID != attachment.ID {
+				t.Fatalf("want the attachment on the todo, got %+v", stored.Attachments)
+			}
+
+			get := func(rng string) *http.Response {
+				req := httptest.NewRequest(http.MethodGet, path+"/"+attachment.ID, nil)
+				req.Header.Set(router.UserHeader, "alice")
+				if rng != "" {
+					req.Header.Set("Range", rng)
+				}
+				return do(req)
+			}
+
+			res = get("")
+			body, _ := io.ReadAll(res.Body)
+			if res.StatusCode != http.StatusOK || !bytes.Equal(body, png) || res.Header.Get("Content-Type") != "image/png" {
+				t.Errorf("want the whole png, got %d %s %d bytes", res.StatusCode, res.Header.Get("Content-Type"), len(body))
+			}
+
+			res = get("bytes=1-3")
+			body, _ = io.ReadAll(res.Body)
+			if res.StatusCode != http.StatusPartialContent || string(body) != "PNG" || res.Header.Get("Content-Range") != "bytes 1-3/108" {
+				t.Errorf("want bytes 1-3, got %d %q %s", res.StatusCode, body, res.Header.Get("Content-Range"))
+			}
+
+			if res = get("bytes=500-"); res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+				t.Errorf("want 416, got %d", res.StatusCode)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path+"/"+attachment.ID, nil)
+			req.Header.Set(router.UserHeader, "bob")
+			if res = do(req); res.StatusCode != http.StatusNotFound {
+				t.Errorf("other user downloads: want 404, got %d", res.StatusCode)
+			}
+
+			req = httptest.NewRequest(http.MethodDelete, path+"/"+attachment.ID, nil)
+			req.Header.Set(router.UserHeader, "alice")
+			if res = do(req); res.StatusCode != http.StatusOK {
+				t.Errorf("delete: want 200, got %d", res.StatusCode)
+			}
+			if _, err := blobs.Get(req.Context(), blobKey(todo.ID, attachment.ID), 0, -1); err != blob.ErrNotFound {
+				t.Errorf("want the blob deleted, got %v", err)
+			}
+			if res = get(""); res.StatusCode != http.StatusNotFound {
+				t.Errorf("download after delete: want 404, got %d", res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package todo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/markdown"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+const (
+	defaultCommentLimit = 50
+	maxCommentLimit     = 200
+	maxCommentLength    = 10000
+)
+
+type commentBody struct {
+	Body string `json:"body"`
+}
+
+// commentStore answers 501 when the store can't keep comments.
+func (t *TodoHandler) commentStore(c router.IContext) store.CommentStorer {
+	if t.comments == nil {
+		c.JSON(http.StatusNotImplemented, map[string]any{
+			"error": "comments are not supported by this store",
+		})
+	}
+	return t.comments
+}
+
+// render reads the body of a comment request into comment.
+func render(c router.IContext, comment *model.Comment) error {
+	var body commentBody
+	if err := c.Bind(&body); err != nil {
+		return err
+	}
+	body.Body = strings.TrimSpace(body.Body)
+	if body.Body == "" {
+		return fmt.Errorf("body is required")
+	}
+	if utf8.RuneCountInString(body.Body) > maxCommentLength {
+		return fmt.Errorf("body is longer than %d characters", maxCommentLength)
+	}
+
+	html, err := markdown.Render(body.Body)
+	if err != nil {
+		return err
+	}
+	comment.Body = body.Body
+	comment.HTML = html
+	comment.Mentions = markdown.Mentions(body.Body)
+	return nil
+}
+
+// Comments lists the discussion of a todo, oldest first, paged with
+// ?limit= and ?offset=.
+func (t *TodoHandler) Comments(c router.IContext) {
+	cmd := "list comments"
+	node := "client"
+	logger := c.Log("task_comments")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	comments := t.commentStore(c)
+	if comments == nil {
+		return
+	}
+
+	limit, err := queryInt(c, "limit", defaultCommentLimit, 1, maxCommentLimit)
+	var offset int
+	if err == nil {
+		offset, err = queryInt(c, "offset", 0, 0, math.MaxInt32)
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if !t.authorizeID(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	list, err := comments.Comments(id, limit, offset, logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, len(list)).End()
+	c.JSON(http.StatusOK, list)
+}
+
+// NewComment adds a comment to a todo, anyone who can see the todo may
+// join its discussion. Users @mentioned in the body are notified.
+func (t *TodoHandler) NewComment(c router.IContext) {
+	cmd := "new comment"
+	node := "client"
+	logger := c.Log("new_comment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	comments := t.commentStore(c)
+	if comments == nil {
+		return
+	}
+	user, ok := caller(c)
+	if !ok {
+		return
+	}
+
+	comment := model.Comment{TodoID: c.Param("id"), UserID: user}
+	if err := render(c, &comment); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !t.authorizeID(c, comment.TodoID, model.RoleViewer, logger) {
+		return
+	}
+
+	if err := comments.CreateComment(&comment, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, comment).End()
+	c.JSON(http.StatusCreated, comment)
+}
+
+func (t *TodoHandler) FindComment(c router.IContext) {
+	cmd := "find comment"
+	node := "client"
+	logger := c.Log("find_comment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	comments := t.commentStore(c)
+	if comments == nil {
+		return
+	}
+	id := c.Param("id")
+	if !t.authorizeID(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	comment, err := comments.FindComment(id, c.Param("comment"), logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, comment).End()
+	c.JSON(http.StatusOK, comment)
+}
+
+// UpdateComment edits the body of a comment, only its author may.
+func (t *TodoHandler) UpdateComment(c router.IContext) {
+	cmd := "update comment"
+	node := "client"
+	logger := c.Log("update_comment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	comments := t.commentStore(c)
+	if comments == nil {
+		return
+	}
+	comment, ok := t.ownComment(c, comments, false, logger)
+	if !ok {
+		return
+	}
+
+	if err := render(c, comment); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := comments.UpdateComment(comment, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, comment).End()
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment removes a comment, its author or an owner of the todo may.
+func (t *TodoHandler) DeleteComment(c router.IContext) {
+	cmd := "delete comment"
+	node := "client"
+	logger := c.Log("delete_comment")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	comments := t.commentStore(c)
+	if comments == nil {
+		return
+	}
+	comment, ok := t.ownComment(c, comments, true, logger)
+	if !ok {
+		return
+	}
+
+	if err := comments.DeleteComment(comment.TodoID, comment.ID, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"ID":     comment.ID,
+		"status": "success",
+	}
+	logger.AddOutput(node, cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// ownComment loads the comment of the request and checks the caller wrote
+// it, or with owners also is an owner of the todo.
+func (t *TodoHandler) ownComment(c router.IContext, comments store.CommentStorer, owners bool, logger logger.ILogDetail) (*model.Comment, bool) {
+	user, ok := caller(c)
+	if !ok {
+		return nil, false
+	}
+
+	todo, err := t.store.FindOne(c.Param("id"), logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	role, err := t.access.todoRole(todo, user, logger)
+	if !check(c, role, model.RoleViewer, err) {
+		return nil, false
+	}
+
+	comment, err := comments.FindComment(todo.ID, c.Param("comment"), logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	if comment.UserID == user {
+		return comment, true
+	}
+	if owners && model.RoleAllows(role, model.RoleOwner) {
+		return comment, true
+	}
+	c.JSON(http.StatusForbidden, map[string]any{
+		"error": "only the author may change a comment",
+	})
+	return nil, false
+}
@@ -0,0 +1,59 @@
+package todo
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sing3demons/todoapi/model"
+)
+
+func TestComments(t *testing.T) {
+	s := newAuthStore(t)
+	log := actorLog("alice")
+	todos := NewTodoHandler(s)
+
+	list := &model.List{Name: "release", UserID: "alice"}
+	s.CreateList(list, log)
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "bob", Role: model.RoleViewer}, log)
+	s.AddMember(&model.Membership{ListID: list.ID, UserID: "dave", Role: model.RoleEditor}, log)
+	todo := &model.Todo{Title: "tag release", ListID: list.ID}
+	s.Create(todo, log)
+	id := map[string]string{"id": todo.ID}
+
+	c := as("bob", todos.NewComment, id, `{"body":"**LGTM** @alice, mail me at bob@example.com <script>alert(1)</script>"}`)
+	if c.code != http.StatusCreated {
+		t.Fatalf("viewer comments: want 201, got %d %v", c.code, c.out)
+	}
+	comment := c.out.(model.Comment)
+	if !strings.Contains(comment.HTML, "<strong>LGTM</strong>") || strings.Contains(comment.HTML, "<script") {
+		t.Errorf("want rendered and sanitised html, got %s", comment.HTML)
+	}
+	if !reflect.DeepEqual(comment.Mentions, []string{"alice"}) {
+		t.Errorf("want alice mentioned, got %v", comment.Mentions)
+	}
+	cid := map[string]string{"id": todo.ID, "comment": comment.ID}
+
+	// the calls run in order, each one sees the effects of those before
+	for _, tt := range []struct {
+		name string
+		c    *authContext
+		want int
+	}{
+		{"anonymous comments", as("", todos.NewComment, id, `{"body":"hi"}`), http.StatusUnauthorized},
+		{"outsider comments", as("carol", todos.NewComment, id, `{"body":"hi"}`), http.StatusNotFound},
+		{"empty comment", as("bob", todos.NewComment, id, `{"body":"  "}`), http.StatusBadRequest},
+		{"outsider reads", as("carol", todos.Comments, id, ""), http.StatusNotFound},
+		{"viewer reads", as("bob", todos.Comments, id, ""), http.StatusOK},
+		{"editor edits someone else's comment", as("dave", todos.UpdateComment, cid, `{"body":"nope"}`), http.StatusForbidden},
+		{"editor deletes someone else's comment", as("dave", todos.DeleteComment, cid, ""), http.StatusForbidden},
+		{"author edits", as("bob", todos.UpdateComment, cid, `{"body":"LGTM @dave"}`), http.StatusOK},
+		{"owner deletes", as("alice", todos.DeleteComment, cid, ""), http.StatusOK},
+		{"reading a deleted comment", as("bob", todos.FindComment, cid, ""), http.StatusNotFound},
+	} {
+		if tt.c.code != tt.want {
+			t.Errorf("%s: want %d, got %d %v", tt.name, tt.want, tt.c.code, tt.c.out)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package todo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type HistoryHandler struct {
+	todos  store.Storer
+	store  store.AuditStorer
+	access access
+}
+
+func NewHistoryHandler(todos store.Storer, store store.AuditStorer) *HistoryHandler {
+	return &HistoryHandler{todos: todos, store: store, access: newAccess(todos)}
+}
+
+// History lists the audit trail of a todo, newest first, paged with
+// ?limit= and ?offset=.
+func (h *HistoryHandler) History(c router.IContext) {
+	logger := c.Log("task_history")
+	cmd := "task history"
+	node := "client"
+
+	logger.AddInput(node, cmd, c.Incoming())
+
+	limit, err := queryInt(c, "limit", defaultHistoryLimit, 1, maxHistoryLimit)
+	var offset int
+	if err == nil {
+		offset, err = queryInt(c, "offset", 0, 0, math.MaxInt32)
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !h.authorize(c, c.Param("id"), logger) {
+		return
+	}
+
+	entries, err := h.store.History(c.Param("id"), limit, offset, logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, len(entries)).End()
+	c.JSON(http.StatusOK, entries)
+}
+
+// authorize checks the caller may view todo id. The trail outlives the
+// todo, a deleted one is checked as the trail last saw it.
+func (h *HistoryHandler) authorize(c router.IContext, id string, logger logger.ILogDetail) bool {
+	todo, err := h.todos.FindOne(id, logger)
+	if errStatus(err) == http.StatusNotFound {
+		todo, err = h.lastSnapshot(id, logger)
+	}
+	if err != nil || todo == nil {
+		return check(c, "", model.RoleViewer, err)
+	}
+	return h.access.authorizeTodo(c, todo, model.RoleViewer, logger)
+}
+
+// lastSnapshot returns todo id from its newest audit entry, nil when it has
+// none.
+func (h *HistoryHandler) lastSnapshot(id string, logger logger.ILogDetail) (*model.Todo, error) {
+	entries, err := h.store.History(id, 1, 0, logger)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	if entries[0].After != nil {
+		return entries[0].After, nil
+	}
+	return entries[0].Before, nil
+}
+
+// queryInt reads an integer query parameter between min and max.
+func queryInt(c router.IContext, name string, fallback, min, max int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a number between %d and %d", name, min, max)
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type historyContext struct {
+	TestContext
+	id    string
+	query map[string]string
+	code  int
+	body  any
+}
+
+func (c *historyContext) Param(string) string      { return c.id }
+func (c *historyContext) Query(name string) string { return c.query[name] }
+func (c *historyContext) JSON(code int, v any) {
+	c.code = code
+	c.body = v
+}
+
+func actorLog(user string) logger.ILogDetail {
+	return logger.New(slog.Default(), "", map[string]any{"session": "s-" + user, "user_id": user})
+}
+
+func TestHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	todos := store.NewGormStore(db)
+
+	todo := &model.Todo{Title: "write tests"}
+	todos.Create(todo, actorLog("alice"))
+	todo.Completed = true
+	todos.Update(todo, actorLog("bob"))
+	todos.Delete(todo.ID, actorLog("alice"))
+
+	handler := NewHistoryHandler(todos, store.NewGormAuditStore(db))
+	c := &historyContext{id: todo.ID}
+	handler.History(c)
+
+	entries, ok := c.body.([]model.AuditEntry)
+	if c.code != http.StatusOK || !ok {
+		t.Fatalf("want 200 with entries, got %d %v", c.code, c.body)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("want 3 entries, got %d", len(entries))
+	}
+
+	deleted, completed, created := entries[0], entries[1], entries[2]
+	if created.Action != model.TodoCreated || created.Before != nil || created.After.Title != "write tests" {
+		t.Errorf("unexpected create entry %+v", created)
+	}
+	if completed.Action != model.TodoCompleted || completed.UserID != "bob" || completed.Session != "s-bob" {
+		t.Errorf("unexpected complete entry %+v", completed)
+	}
+	if d := completed.Diff["completed"]; d.From != false || d.To != true {
+		t.Errorf("want completed false -> true, got %+v", completed.Diff)
+	}
+	if _, ok := completed.Diff["text"]; ok {
+		t.Errorf("unchanged text in diff %+v", completed.Diff)
+	}
+	if deleted.Action != model.TodoDeleted || deleted.After != nil || deleted.Diff["text"].From != "write tests" {
+		t.Errorf("unexpected delete entry %+v", deleted)
+	}
+
+	c = &historyContext{id: todo.ID, query: map[string]string{"limit": "1", "offset": "1"}}
+	handler.History(c)
+	if entries := c.body.([]model.AuditEntry); len(entries) != 1 || entries[0].ID != completed.ID {
+		t.Errorf("want the second entry, got %+v", entries)
+	}
+
+	c = &historyContext{id: todo.ID, query: map[string]string{"limit": "0"}}
+	handler.History(c)
+	if c.code != http.StatusBadRequest {
+		t.Errorf("want 400 for limit 0, got %d", c.code)
+	}
+}
+
+func TestHistoryAccess(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "todo.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&model.Todo{}, &model.Dependency{}, &model.Comment{}, &model.List{}, &model.Membership{}, &model.OutboxEntry{}, &model.AuditEntry{})
+	todos := store.NewGormStore(db)
+	handler := NewHistoryHandler(todos, store.NewGormAuditStore(db))
+
+	private := &model.Todo{Title: "dentist", UserID: "alice"}
+	todos.Create(private, actorLog("alice"))
+	id := map[string]string{"id": private.ID}
+
+	if c := as("bob", handler.History, id, ""); c.code != http.StatusNotFound {
+		t.Errorf("other user reads the history: want 404, got %d", c.code)
+	}
+	if c := as("alice", handler.History, id, ""); c.code != http.StatusOK {
+		t.Errorf("owner reads the history: want 200, got %d", c.code)
+	}
+
+	// the snapshots of a deleted todo stay private
+	todos.Delete(private.ID, actorLog("alice"))
+	if c := as("bob", handler.History, id, ""); c.code != http.StatusNotFound {
+		t.Errorf("other user reads a deleted todo's history: want 404, got %d", c.code)
+	}
+	if c := as("alice", handler.History, id, ""); c.code != http.StatusOK {
+		t.Errorf("owner reads a deleted todo's history: want 200, got %d", c.code)
+	}
+	if c := as("alice", handler.History, map[string]string{"id": "missing"}, ""); c.code != http.StatusNotFound {
+		t.Errorf("history of a missing todo: want 404, got %d", c.code)
+	}
+}
@@ -0,0 +1,279 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+// ListHandler serves lists, their members and invitations. Callers are
+// identified by router.UserHeader and see only the lists they belong to.
+type ListHandler struct {
+	store   store.ListStorer
+	members store.MemberStorer
+	access  access
+}
+
+func NewListHandler(store store.ListStorer, members store.MemberStorer) *ListHandler {
+	return &ListHandler{store: store, members: members, access: access{members: members}}
+}
+
+// caller returns the id of the caller, answering 401 when there is none.
+func caller(c router.IContext) (string, bool) {
+	user := c.Header(router.UserHeader)
+	if user == "" {
+		c.JSON(http.StatusUnauthorized, map[string]any{
+			"error": router.UserHeader + " header is required",
+		})
+	}
+	return user, user != ""
+}
+
+// listStatus maps a list store error to the status it is answered with.
+func listStatus(err error) int {
+	if errors.Is(err, store.ErrNotInList) {
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
+
+func (h *ListHandler) Create(c router.IContext) {
+	cmd := "new list"
+	node := "client"
+	logger := c.Log("new_list")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var list model.List
+	err := c.Bind(&list)
+	if err == nil && strings.TrimSpace(list.Name) == "" {
+		err = fmt.Errorf("name is required")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, ok := caller(c)
+	if !ok {
+		return
+	}
+	list.UserID = user
+
+	if err := h.store.CreateList(&list, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, list).End()
+	c.JSON(http.StatusCreated, list)
+}
+
+func (h *ListHandler) List(c router.IContext) {
+	cmd := "list lists"
+	logger := c.Log("lists_list")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	memberships, err := h.members.Memberships(c.Header(router.UserHeader), logger)
+	var lists []model.List
+	if err == nil {
+		ids := make([]string, len(memberships))
+		for i, m := range memberships {
+			ids[i] = m.ListID
+		}
+		lists, err = h.store.Lists(ids, logger)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput("client", cmd, lists).End()
+	c.JSON(http.StatusOK, lists)
+}
+
+func (h *ListHandler) FindOne(c router.IContext) {
+	cmd := "find list"
+	logger := c.Log("find_list")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	list, err := h.store.FindList(id, logger)
+	if err != nil {
+		c.JSON(listStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput("client", cmd, list).End()
+	c.JSON(http.StatusOK, list)
+}
+
+type patchList struct {
+	Name *string `json:"name"`
+}
+
+func (h *ListHandler) Update(c router.IContext) {
+	cmd := "update list"
+	node := "client"
+	logger := c.Log("update_list")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var patch patchList
+	err := c.Bind(&patch)
+	if err == nil && patch.Name != nil && strings.TrimSpace(*patch.Name) == "" {
+		err = fmt.Errorf("name is required")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleOwner, logger) {
+		return
+	}
+
+	list, err := h.store.FindList(id, logger)
+	if err != nil {
+		c.JSON(listStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if patch.Name != nil {
+		list.Name = *patch.Name
+	}
+
+	if err := h.store.UpdateList(list, logger); err != nil {
+		c.JSON(listStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, list).End()
+	c.JSON(http.StatusOK, list)
+}
+
+// Delete removes a list. Its todos are kept, without a list.
+func (h *ListHandler) Delete(c router.IContext) {
+	cmd := "delete list"
+	logger := c.Log("delete_list")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleOwner, logger) {
+		return
+	}
+
+	if err := h.store.DeleteList(id, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"ID":     id,
+		"status": "success",
+	}
+	logger.AddOutput("client", cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// Todos lists the todos of a list in their manual order.
+func (h *ListHandler) Todos(c router.IContext) {
+	cmd := "list todos of list"
+	logger := c.Log("list_todos")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	todos, err := h.store.ListTodos(id, logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput("client", cmd, todos).End()
+	c.JSON(http.StatusOK, todos)
+}
+
+// Reorder places a todo after or before another todo of a list, or at its
+// end, moving it to list_id when given.
+func (t *TodoHandler) Reorder(c router.IContext) {
+	cmd := "reorder task"
+	node := "client"
+	logger := c.Log("reorder_task")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	if t.lists == nil {
+		c.JSON(http.StatusNotImplemented, map[string]any{
+			"error": "lists are not supported by this store",
+		})
+		return
+	}
+
+	var pos store.Position
+	err := c.Bind(&pos)
+	if err == nil && pos.After != "" && pos.Before != "" {
+		err = fmt.Errorf("give either after or before")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if !t.authorizeID(c, id, model.RoleEditor, logger) {
+		return
+	}
+	if pos.ListID != "" && !t.access.authorizeList(c, pos.ListID, model.RoleEditor, logger) {
+		return
+	}
+
+	todo, err := t.lists.Reorder(id, pos, logger)
+	if err != nil {
+		c.JSON(listStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, todo).End()
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,213 @@
+package todo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+// invitationTTL is how long an invitation can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+// Members lists who belongs to a list and with which role.
+func (h *ListHandler) Members(c router.IContext) {
+	cmd := "list members"
+	logger := c.Log("list_members")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	members, err := h.members.Members(id, logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput("client", cmd, members).End()
+	c.JSON(http.StatusOK, members)
+}
+
+type memberRole struct {
+	Role string `json:"role"`
+}
+
+// SetMember gives a user a role on a list. Only owners manage members, and
+// the list's creator always stays its owner.
+func (h *ListHandler) SetMember(c router.IContext) {
+	cmd := "set member"
+	node := "client"
+	logger := c.Log("set_member")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var body memberRole
+	err := c.Bind(&body)
+	if err == nil && !model.ValidRole(body.Role) {
+		err = fmt.Errorf("role must be viewer, editor or owner")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id, user := c.Param("id"), c.Param("user")
+	if !h.access.authorizeList(c, id, model.RoleOwner, logger) || !h.keepsCreator(c, id, user, body.Role, logger) {
+		return
+	}
+
+	m := &model.Membership{ListID: id, UserID: user, Role: body.Role}
+	if err := h.members.AddMember(m, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, m).End()
+	c.JSON(http.StatusOK, m)
+}
+
+// RemoveMember takes a user off a list. Owners can remove anyone but the
+// creator, other members only themselves.
+func (h *ListHandler) RemoveMember(c router.IContext) {
+	cmd := "remove member"
+	logger := c.Log("remove_member")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id, user := c.Param("id"), c.Param("user")
+	want := model.RoleOwner
+	if user == c.Header(router.UserHeader) {
+		want = model.RoleViewer
+	}
+	if !h.access.authorizeList(c, id, want, logger) || !h.keepsCreator(c, id, user, "", logger) {
+		return
+	}
+
+	if err := h.members.RemoveMember(id, user, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"list_id": id,
+		"user_id": user,
+		"status":  "success",
+	}
+	logger.AddOutput("client", cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// keepsCreator answers 409 when user created the list and role isn't
+// owner.
+func (h *ListHandler) keepsCreator(c router.IContext, listID, user, role string, logger logger.ILogDetail) bool {
+	list, err := h.store.FindList(listID, logger)
+	if err != nil {
+		c.JSON(listStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if list.UserID == user && role != model.RoleOwner {
+		c.JSON(http.StatusConflict, map[string]any{
+			"error": "the creator of a list stays its owner",
+		})
+		return false
+	}
+	return true
+}
+
+// Invite creates an invitation to a list. The token in the response is
+// shown only once, whoever accepts it joins with the invitation's role.
+func (h *ListHandler) Invite(c router.IContext) {
+	cmd := "invite member"
+	node := "client"
+	logger := c.Log("invite_member")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var body memberRole
+	err := c.Bind(&body)
+	if err == nil && body.Role != model.RoleViewer && body.Role != model.RoleEditor {
+		err = fmt.Errorf("role must be viewer or editor")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if !h.access.authorizeList(c, id, model.RoleOwner, logger) {
+		return
+	}
+
+	token := make([]byte, 32)
+	rand.Read(token)
+	inv := model.Invitation{
+		Token:     hex.EncodeToString(token),
+		ListID:    id,
+		Role:      body.Role,
+		InvitedBy: c.Header(router.UserHeader),
+		ExpiresAt: time.Now().Add(invitationTTL),
+	}
+	if err := h.members.CreateInvitation(&inv, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, map[string]any{"list_id": id, "role": inv.Role}).End()
+	c.JSON(http.StatusCreated, inv)
+}
+
+// Accept joins the caller to the list of an invitation.
+func (h *ListHandler) Accept(c router.IContext) {
+	cmd := "accept invitation"
+	logger := c.Log("accept_invitation")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	user, ok := caller(c)
+	if !ok {
+		return
+	}
+
+	m, err := h.members.AcceptInvitation(c.Param("token"), user, logger)
+	if errors.Is(err, store.ErrInvitation) {
+		c.JSON(http.StatusNotFound, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput("client", cmd, m).End()
+	c.JSON(http.StatusOK, m)
+}
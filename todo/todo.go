@@ -5,18 +5,47 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/sing3demons/todoapi/blob"
+	"github.com/sing3demons/todoapi/logger"
 	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/recur"
 	"github.com/sing3demons/todoapi/router"
 	"github.com/sing3demons/todoapi/store"
 )
 
 type TodoHandler struct {
-	store store.Storer
+	store       store.Storer
+	tree        store.TreeStorer
+	lists       store.ListStorer
+	attachments store.AttachmentStorer
+	comments    store.CommentStorer
+	access      access
+
+	// Blobs keeps the content of attachments, without it they are turned
+	// off.
+	Blobs             blob.BlobStore
+	MaxAttachmentSize int64
+	// AttachmentTypes are the media types allowed for attachments.
+	AttachmentTypes []string
 }
 
-func NewTodoHandler(store store.Storer) *TodoHandler {
-	return &TodoHandler{store: store}
+func NewTodoHandler(s store.Storer) *TodoHandler {
+	tree, _ := s.(store.TreeStorer)
+	lists, _ := s.(store.ListStorer)
+	attachments, _ := s.(store.AttachmentStorer)
+	comments, _ := s.(store.CommentStorer)
+	return &TodoHandler{
+		store:             s,
+		tree:              tree,
+		lists:             lists,
+		attachments:       attachments,
+		comments:          comments,
+		access:            newAccess(s),
+		MaxAttachmentSize: defaultMaxAttachmentSize,
+		AttachmentTypes:   defaultAttachmentTypes,
+	}
 }
 
 func (t *TodoHandler) NewTask(c router.IContext) {
@@ -36,6 +65,12 @@ func (t *TodoHandler) NewTask(c router.IContext) {
 		return
 	}
 
+	todo.UserID = c.Header(router.UserHeader)
+	todo.SeriesID = ""
+	todo.SeriesStart = nil
+	todo.Rank = ""
+	todo.Attachments = nil
+
 	if todo.Title == "sleep" {
 		logger.AddError(node, cmd, "output", todo, fmt.Errorf("not allowed"))
 		c.JSON(http.StatusBadRequest, map[string]any{
@@ -44,6 +79,51 @@ func (t *TodoHandler) NewTask(c router.IContext) {
 		return
 	}
 
+	if err := validateSchedule(&todo); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// authorize first, a caller with no role on the list gets the same 404
+	// whether it exists or not
+	if todo.ListID != "" && !t.access.authorizeList(c, todo.ListID, model.RoleEditor, logger) {
+		return
+	}
+	if todo.ListID != "" && t.lists != nil {
+		if _, err := t.lists.FindList(todo.ListID, logger); err != nil {
+			err = fmt.Errorf("list %s not found", todo.ListID)
+			logger.AddError(node, cmd, "output", map[string]any{
+				"error": "bad_request",
+			}, err)
+			c.JSON(http.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	if todo.ParentID != "" {
+		parent, err := t.store.FindOne(todo.ParentID, logger)
+		if err != nil {
+			// the same answer as for a parent the caller may not see
+			logger.AddError(node, cmd, "output", map[string]any{
+				"error": "not_found",
+			}, fmt.Errorf("parent %s not found", todo.ParentID))
+			c.JSON(http.StatusNotFound, map[string]any{
+				"error": "not found",
+			})
+			return
+		}
+		if !t.access.authorizeTodo(c, parent, model.RoleEditor, logger) {
+			return
+		}
+	}
+
 	err := t.store.Create(&todo, logger)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, map[string]any{
@@ -90,6 +170,15 @@ func (t *TodoHandler) List(c router.IContext) {
 		opt.SelectItem = strings.Split(fields, ",")
 	}
 
+	scope, err := t.access.scope(c.Header(router.UserHeader), logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	opt.Scope = scope
+
 	todos, err := t.store.List(opt, logger)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, map[string]any{
@@ -109,6 +198,11 @@ func (t *TodoHandler) Remove(c router.IContext) {
 
 	logger.Info(cmd, slog.Group("param", slog.String("id", idParam)))
 
+	todo, ok := t.authorizeDelete(c, idParam, logger)
+	if !ok {
+		return
+	}
+
 	err := t.store.Delete(idParam, logger)
 	if err != nil {
 		logger.Error(cmd, slog.Any("error", err))
@@ -117,6 +211,7 @@ func (t *TodoHandler) Remove(c router.IContext) {
 		})
 		return
 	}
+	t.deleteBlobs(c, todo, logger)
 
 	data := map[string]any{
 		"ID":     idParam,
@@ -142,6 +237,9 @@ func (t *TodoHandler) FindOne(c router.IContext) {
 		})
 		return
 	}
+	if !t.access.authorizeTodo(c, todo, model.RoleViewer, logger) {
+		return
+	}
 
 	logger.AddOutput("client", cmd, todo).End()
 	c.JSON(http.StatusOK, todo)
@@ -154,6 +252,11 @@ func (t *TodoHandler) Delete(c router.IContext) {
 
 	logger.AddInput("client", cmd, c.Incoming())
 
+	todo, ok := t.authorizeDelete(c, idParam, logger)
+	if !ok {
+		return
+	}
+
 	err := t.store.Delete(idParam, logger)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError,
@@ -162,6 +265,7 @@ func (t *TodoHandler) Delete(c router.IContext) {
 			})
 		return
 	}
+	t.deleteBlobs(c, todo, logger)
 
 	data := map[string]any{
 		"ID":     idParam,
@@ -172,3 +276,126 @@ func (t *TodoHandler) Delete(c router.IContext) {
 
 	c.JSON(http.StatusOK, data)
 }
+
+// authorizeDelete checks the caller may delete todo id and returns it.
+// Deleting a todo that doesn't exist is allowed, it succeeds without doing
+// anything.
+func (t *TodoHandler) authorizeDelete(c router.IContext, id string, logger logger.ILogDetail) (*model.Todo, bool) {
+	todo, err := t.store.FindOne(id, logger)
+	if errStatus(err) == http.StatusNotFound {
+		return nil, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	return todo, t.access.authorizeTodo(c, todo, model.RoleEditor, logger)
+}
+
+type patchTodo struct {
+	Text       *string    `json:"text"`
+	Completed  *bool      `json:"completed"`
+	DueAt      *time.Time `json:"due_at"`
+	TimeZone   *string    `json:"time_zone"`
+	Recurrence *string    `json:"recurrence"`
+	// Cascade completes the subtasks along with the todo.
+	Cascade bool `json:"cascade"`
+}
+
+// validateSchedule checks the due date fields of todo and normalizes its
+// recurrence rule.
+func validateSchedule(todo *model.Todo) error {
+	if _, err := todo.Location(); err != nil {
+		return fmt.Errorf("unknown time_zone %s", todo.TimeZone)
+	}
+	if todo.Recurrence == "" {
+		return nil
+	}
+	if todo.DueAt == nil {
+		return fmt.Errorf("a recurring todo needs a due_at")
+	}
+	rule, err := recur.Parse(todo.Recurrence)
+	if err != nil {
+		return fmt.Errorf("invalid recurrence: %w", err)
+	}
+	todo.Recurrence = rule.String()
+	return nil
+}
+
+func (t *TodoHandler) Update(c router.IContext) {
+	logger := c.Log("update_task")
+	idParam := c.Param("id")
+	cmd := "update task"
+	node := "client"
+
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var patch patchTodo
+	if err := c.Bind(&patch); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	todo, err := t.store.FindOne(idParam, logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !t.access.authorizeTodo(c, todo, model.RoleEditor, logger) {
+		return
+	}
+
+	if patch.Text != nil {
+		todo.Title = *patch.Text
+	}
+	if patch.Completed != nil {
+		todo.Completed = *patch.Completed
+	}
+	if patch.DueAt != nil {
+		todo.DueAt = patch.DueAt
+	}
+	if patch.TimeZone != nil {
+		todo.TimeZone = *patch.TimeZone
+	}
+	if patch.Recurrence != nil {
+		todo.Recurrence = *patch.Recurrence
+	}
+
+	if err := validateSchedule(todo); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := t.store.Update(todo, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if patch.Cascade && todo.Completed && t.tree != nil {
+		if err := t.completeSubtree(todo.ID, c.Header(router.UserHeader), logger); err != nil {
+			c.JSON(http.StatusInternalServerError, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	logger.AddOutput(node, cmd, todo).End()
+	c.JSON(http.StatusOK, todo)
+}
@@ -1,7 +1,11 @@
 package todo
 
 import (
+	"context"
+	"io"
 	"log/slog"
+	"mime/multipart"
+	"net/http"
 	"testing"
 
 	"github.com/sing3demons/todoapi/logger"
@@ -30,6 +34,14 @@ func (t *TestContext) Query(string) string    { return "" }
 func (t *TestContext) Incoming() map[string]interface{} {
 	return map[string]interface{}{}
 }
+func (t *TestContext) Header(string) string          { return "" }
+func (t *TestContext) SetHeader(string, string)      {}
+func (t *TestContext) UserContext() context.Context  { return context.Background() }
+func (t *TestContext) Stream(func(w io.Writer) bool) {}
+func (t *TestContext) FormFile(string) (*multipart.FileHeader, error) {
+	return nil, http.ErrMissingFile
+}
+func (t *TestContext) SendReader(int, string, int64, io.Reader) {}
 
 type TestDB struct{}
 
@@ -42,6 +54,7 @@ func (*TestDB) Delete(string, logger.ILogDetail) error { return nil }
 func (*TestDB) FindOne(string, logger.ILogDetail) (*model.Todo, error) {
 	return &model.Todo{Title: "sleep"}, nil
 }
+func (*TestDB) Update(*model.Todo, logger.ILogDetail) error { return nil }
 
 // func TestCreateTodo(t *testing.T) {
 // 	handler := NewTodoHandler(&TestDB{})
@@ -0,0 +1,272 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// errStatus maps a store error to the status it is answered with.
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrCycle):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// treeStore answers 501 when the store can't relate todos.
+func (t *TodoHandler) treeStore(c router.IContext) store.TreeStorer {
+	if t.tree == nil {
+		c.JSON(http.StatusNotImplemented, map[string]any{
+			"error": "subtasks are not supported by this store",
+		})
+	}
+	return t.tree
+}
+
+// authorizeID checks the caller may do want with todo id.
+func (t *TodoHandler) authorizeID(c router.IContext, id, want string, logger logger.ILogDetail) bool {
+	todo, err := t.store.FindOne(id, logger)
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return t.access.authorizeTodo(c, todo, want, logger)
+}
+
+// allowed keeps the todos user may do want with.
+func (t *TodoHandler) allowed(todos []model.Todo, user, want string, logger logger.ILogDetail) ([]model.Todo, error) {
+	roles := map[string]string{}
+	out := todos[:0]
+	for _, todo := range todos {
+		role, ok := roles[todo.ListID]
+		if !ok || todo.ListID == "" {
+			var err error
+			if role, err = t.access.todoRole(&todo, user, logger); err != nil {
+				return nil, err
+			}
+			roles[todo.ListID] = role
+		}
+		if model.RoleAllows(role, want) {
+			out = append(out, todo)
+		}
+	}
+	return out, nil
+}
+
+// Children lists the subtasks of a todo, with ?recursive=true the whole
+// subtree.
+func (t *TodoHandler) Children(c router.IContext) {
+	t.related(c, "task_children", "list children", store.TreeStorer.Children)
+}
+
+// Blockers lists the todos a todo is blocked by, with ?recursive=true
+// everything they wait on too.
+func (t *TodoHandler) Blockers(c router.IContext) {
+	t.related(c, "task_blockers", "list blockers", store.TreeStorer.Blockers)
+}
+
+func (t *TodoHandler) related(c router.IContext, name, cmd string, list func(store.TreeStorer, string, bool, logger.ILogDetail) ([]model.Todo, error)) {
+	logger := c.Log(name)
+	node := "client"
+	logger.AddInput(node, cmd, c.Incoming())
+
+	tree := t.treeStore(c)
+	if tree == nil {
+		return
+	}
+
+	id := c.Param("id")
+	if !t.authorizeID(c, id, model.RoleViewer, logger) {
+		return
+	}
+
+	todos, err := list(tree, id, c.Query("recursive") == "true", logger)
+	if err == nil {
+		// related todos can be in lists the caller isn't in
+		todos, err = t.allowed(todos, c.Header(router.UserHeader), model.RoleViewer, logger)
+	}
+	if err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logger.AddOutput(node, cmd, todos).End()
+	c.JSON(http.StatusOK, todos)
+}
+
+type moveTodo struct {
+	ParentID string `json:"parent_id"`
+}
+
+// Move makes a todo a subtask of parent_id, or a top level todo when it is
+// empty. Its subtasks move along.
+func (t *TodoHandler) Move(c router.IContext) {
+	logger := c.Log("move_task")
+	idParam := c.Param("id")
+	cmd := "move task"
+	node := "client"
+
+	logger.AddInput(node, cmd, c.Incoming())
+
+	tree := t.treeStore(c)
+	if tree == nil {
+		return
+	}
+
+	var body moveTodo
+	if err := c.Bind(&body); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !t.authorizeID(c, idParam, model.RoleEditor, logger) {
+		return
+	}
+	if body.ParentID != "" && !t.authorizeID(c, body.ParentID, model.RoleEditor, logger) {
+		return
+	}
+
+	if err := tree.Move(idParam, body.ParentID, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"ID":        idParam,
+		"parent_id": body.ParentID,
+	}
+	logger.AddOutput(node, cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+type addBlocker struct {
+	BlockedBy string `json:"blocked_by"`
+}
+
+// AddBlocker records that a todo is blocked by the todo in blocked_by.
+func (t *TodoHandler) AddBlocker(c router.IContext) {
+	logger := c.Log("add_blocker")
+	idParam := c.Param("id")
+	cmd := "add blocker"
+	node := "client"
+
+	logger.AddInput(node, cmd, c.Incoming())
+
+	tree := t.treeStore(c)
+	if tree == nil {
+		return
+	}
+
+	var body addBlocker
+	err := c.Bind(&body)
+	if err == nil && body.BlockedBy == "" {
+		err = fmt.Errorf("blocked_by is required")
+	}
+	if err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{
+			"error": "bad_request",
+		}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !t.authorizeID(c, idParam, model.RoleEditor, logger) {
+		return
+	}
+	if !t.authorizeID(c, body.BlockedBy, model.RoleViewer, logger) {
+		return
+	}
+
+	if err := tree.AddBlocker(idParam, body.BlockedBy, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"ID":         idParam,
+		"blocked_by": body.BlockedBy,
+	}
+	logger.AddOutput(node, cmd, data).End()
+	c.JSON(http.StatusCreated, data)
+}
+
+func (t *TodoHandler) RemoveBlocker(c router.IContext) {
+	logger := c.Log("remove_blocker")
+	idParam := c.Param("id")
+	blocker := c.Param("blocker")
+	cmd := "remove blocker"
+	node := "client"
+
+	logger.AddInput(node, cmd, c.Incoming())
+
+	tree := t.treeStore(c)
+	if tree == nil {
+		return
+	}
+
+	if !t.authorizeID(c, idParam, model.RoleEditor, logger) {
+		return
+	}
+
+	if err := tree.RemoveBlocker(idParam, blocker, logger); err != nil {
+		c.JSON(errStatus(err), map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]any{
+		"ID":         idParam,
+		"blocked_by": blocker,
+		"status":     "success",
+	}
+	logger.AddOutput(node, cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// completeSubtree completes the unfinished subtasks of id user may edit.
+func (t *TodoHandler) completeSubtree(id, user string, logger logger.ILogDetail) error {
+	children, err := t.tree.Children(id, true, logger)
+	if err == nil {
+		children, err = t.allowed(children, user, model.RoleEditor, logger)
+	}
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		if children[i].Completed {
+			continue
+		}
+		children[i].Completed = true
+		if err := t.store.Update(&children[i], logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
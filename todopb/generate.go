@@ -0,0 +1,3 @@
+package todopb
+
+//go:generate buf generate
@@ -0,0 +1,62 @@
+// Package tracing sets up the OpenTelemetry tracer provider the router and
+// the logger create their spans with.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Setup installs the W3C trace context propagator and a tracer provider
+// sending the spans to exporter:
+//
+//   - otlp posts them to an OTLP/HTTP collector, configured with the
+//     standard OTEL_EXPORTER_OTLP_* variables.
+//   - stdout writes them as JSON to w.
+//   - none or "" creates no spans, but the trace of an incoming
+//     traceparent header still reaches the log.
+//
+// The returned function flushes and stops the provider.
+func Setup(ctx context.Context, exporter, service string, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exp, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		if w == nil {
+			w = os.Stdout
+		}
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tp := NewProvider(service, sdktrace.WithBatcher(exp))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider makes a tracer provider for service, tests pass
+// sdktrace.WithSyncer of an in-memory exporter.
+func NewProvider(service string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
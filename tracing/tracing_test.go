@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), "stdout", "todoapi", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "GET /todo")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `"Name":"GET /todo"`) || !strings.Contains(out, `"Value":"todoapi"`) {
+		t.Errorf("want the span of the service written, got %s", out)
+	}
+
+	if _, err := Setup(context.Background(), "zipkin", "todoapi", nil); err == nil {
+		t.Error("want an error for an unknown exporter")
+	}
+}
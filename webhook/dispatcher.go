@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Ping is the event sent by the test endpoint.
+const Ping = "ping"
+
+// Sign returns the value of the signature header for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Payload struct {
+	ID    string    `json:"id"`
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data,omitempty"`
+}
+
+// mention is the data of a todo.mentioned delivery.
+type mention struct {
+	Todo    model.Todo    `json:"todo"`
+	Comment model.Comment `json:"comment"`
+}
+
+// Dispatcher turns outbox entries into deliveries and sends the due ones,
+// retrying failures with exponential backoff until MaxAttempts is reached
+// and the delivery is dead-lettered. Each replica claims the deliveries it
+// sends, so a delivery goes out once however many replicas run.
+type Dispatcher struct {
+	store  store.WebhookStorer
+	client *http.Client
+
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Interval    time.Duration
+	// ClaimTTL is how long the deliveries of a pass stay claimed, it has
+	// to outlast sending a whole batch.
+	ClaimTTL time.Duration
+
+	holder string
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewDispatcher(store store.WebhookStorer, log *slog.Logger) *Dispatcher {
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 8,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Hour,
+		Interval:    time.Second,
+		ClaimTTL:    10 * time.Minute,
+		holder:      host + "/" + uuid.New().String(),
+		log:         log,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (d *Dispatcher) Serve() error {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.deliverDue()
+		}
+	}
+}
+
+func (d *Dispatcher) Shutdown(context.Context) error {
+	d.cancel()
+	return nil
+}
+
+func (d *Dispatcher) detailLog(name string) logger.ILogDetail {
+	return logger.New(d.log, name, map[string]any{"route": "webhook", "method": "worker"})
+}
+
+func (d *Dispatcher) pollLog(name string) *logger.Poll {
+	return logger.NewPoll(d.log, name, map[string]any{"route": "webhook", "method": "worker"})
+}
+
+// Publish records the deliveries of an outbox entry. The relay marks the
+// entry published once they are all recorded, so unlike a bus subscriber
+// the dispatcher misses none in a burst of changes.
+func (d *Dispatcher) Publish(_ context.Context, entry model.OutboxEntry) error {
+	todo, err := entry.Todo()
+	if err != nil {
+		return err
+	}
+	comment, err := entry.Comment()
+	if err != nil {
+		return err
+	}
+	return d.enqueue(events.Event{Type: entry.Event, Todo: todo, Time: entry.CreatedAt, Comment: comment})
+}
+
+// enqueue records one delivery per interested webhook. Delivery ids are
+// derived from the event so an entry published again doesn't enqueue it
+// twice.
+func (d *Dispatcher) enqueue(e events.Event) error {
+	log := d.detailLog("webhook_enqueue")
+	event := "todo." + e.Type
+
+	hooks, err := d.store.ListWebhooks(log)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		if !hook.Wants(event) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%s|%s|%d", hook.ID, event, e.Todo.ID, e.Todo.UpdatedAt.UnixMilli())
+		var data any = e.Todo
+		if e.Comment != nil {
+			// a mention leaves the todo as it was
+			key = fmt.Sprintf("%s|%s|%d", key, e.Comment.ID, e.Comment.UpdatedAt.UnixMilli())
+			data = mention{Todo: e.Todo, Comment: *e.Comment}
+		}
+		sum := sha256.Sum256([]byte(key))
+		id := hex.EncodeToString(sum[:16])
+
+		body, _ := json.Marshal(Payload{ID: id, Event: event, Time: e.Time, Data: data})
+		err := d.store.CreateDelivery(&model.Delivery{
+			ID:          id,
+			WebhookID:   hook.ID,
+			Event:       event,
+			Payload:     string(body),
+			Status:      model.DeliveryPending,
+			NextAttempt: time.Now(),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}, log)
+		if err != nil {
+			log.Error("record webhook delivery", slog.String("id", id), slog.String("webhook_id", hook.ID), slog.Any("error", err))
+			return err
+		}
+	}
+	return nil
+}
+
+// batchSize deliveries are claimed at most per pass, sent one after the
+// other within ClaimTTL.
+const batchSize = 50
+
+// deliverDue sends the deliveries due, a pass finding none logs nothing.
+func (d *Dispatcher) deliverDue() {
+	log := d.pollLog("webhook_dispatch")
+	claim := d.holder + "/" + uuid.New().String()
+	due, err := d.store.ClaimDeliveries(claim, time.Now(), d.ClaimTTL, batchSize, log)
+	if err != nil {
+		return
+	}
+	if len(due) > 0 {
+		log.Keep()
+	}
+
+	for i := range due {
+		if d.ctx.Err() != nil {
+			return
+		}
+		d.Deliver(&due[i], log)
+	}
+}
+
+// Deliver makes one attempt at sending the delivery and records the outcome.
+func (d *Dispatcher) Deliver(delivery *model.Delivery, log logger.ILogDetail) {
+	node := "webhook"
+	cmd := "deliver"
+	// the outcome ends the claim
+	delivery.ClaimedBy = ""
+
+	hook, err := d.store.FindWebhook(delivery.WebhookID, log)
+	if err != nil {
+		delivery.Status = model.DeliveryDead
+		delivery.LastError = "webhook not found"
+		d.record(delivery, log)
+		return
+	}
+
+	delivery.Attempts++
+	code, err := d.send(hook, delivery)
+	delivery.ResponseCode = code
+
+	if err == nil {
+		delivery.Status = model.DeliveryDelivered
+		delivery.LastError = ""
+		log.AddOutput(node, cmd, map[string]any{"id": delivery.ID, "url": hook.URL, "status": code}).End()
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.MaxAttempts {
+			delivery.Status = model.DeliveryDead
+		} else {
+			delivery.NextAttempt = time.Now().Add(d.backoff(delivery.Attempts))
+		}
+		log.AddError(node, cmd, "output", map[string]any{"id": delivery.ID, "url": hook.URL, "attempts": delivery.Attempts}, err)
+	}
+
+	d.record(delivery, log)
+}
+
+// record saves the outcome of an attempt. When that fails the delivery
+// stays claimed, and is sent again once ClaimTTL has passed.
+func (d *Dispatcher) record(delivery *model.Delivery, log logger.ILogDetail) {
+	if err := d.store.UpdateDelivery(delivery, log); err != nil {
+		log.Error("record webhook delivery outcome", slog.String("id", delivery.ID), slog.String("status", delivery.Status), slog.Any("error", err))
+	}
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff << (attempts - 1)
+	if wait <= 0 || wait > d.MaxBackoff {
+		return d.MaxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) send(hook *model.Webhook, delivery *model.Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, 10*time.Second)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
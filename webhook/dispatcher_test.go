@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/todoapi/events"
+	"github.com/sing3demons/todoapi/logger"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newStore(t *testing.T) *store.GormWebhookStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webhook.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Webhook{}, &model.Delivery{}); err != nil {
+		t.Fatal(err)
+	}
+	return store.NewGormWebhookStore(db)
+}
+
+func testLog() logger.ILogDetail {
+	return logger.New(slog.Default(), "", nil)
+}
+
+func TestDeliverSigned(t *testing.T) {
+	var got atomic.Value
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		got.Store(r.Header.Get(EventHeader))
+	}))
+	defer receiver.Close()
+
+	s := newStore(t)
+	s.CreateWebhook(&model.Webhook{ID: "hook", URL: receiver.URL, Secret: "secret", Events: []string{"todo.completed"}}, testLog())
+
+	d := NewDispatcher(s, slog.Default())
+	d.Interval = 10 * time.Millisecond
+	go d.Serve()
+	defer d.Shutdown(nil)
+
+	for _, event := range []string{events.Created, events.Completed} {
+		entry := model.OutboxEntry{ID: event, Event: event, Payload: model.OutboxPayload(model.Todo{ID: "1"}), CreatedAt: time.Now()}
+		if err := d.Publish(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for got.Load() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got.Load() != "todo.completed" {
+		t.Fatalf("want todo.completed delivered, got %v", got.Load())
+	}
+
+	deliveries, _ := s.ListDeliveries(store.DeliveryFilter{WebhookID: "hook"}, testLog())
+	if len(deliveries) != 1 || deliveries[0].Status != model.DeliveryDelivered {
+		t.Errorf("want one delivered delivery, got %+v", deliveries)
+	}
+}
+
+func TestPublishBurst(t *testing.T) {
+	s := newStore(t)
+	s.CreateWebhook(&model.Webhook{ID: "hook", URL: "http://127.0.0.1:0", Secret: "secret"}, testLog())
+	d := NewDispatcher(s, slog.Default())
+
+	// far more than a bus subscriber buffers
+	for i := 0; i < 100; i++ {
+		entry := model.OutboxEntry{ID: fmt.Sprint(i), Event: events.Updated, Payload: model.OutboxPayload(model.Todo{ID: fmt.Sprint(i)}), CreatedAt: time.Now()}
+		if err := d.Publish(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, _ := s.ListDeliveries(store.DeliveryFilter{WebhookID: "hook", Limit: 200}, testLog())
+	if len(deliveries) != 100 {
+		t.Errorf("want a delivery per entry, got %d", len(deliveries))
+	}
+}
+
+func TestDeliverRetriesThenDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	s := newStore(t)
+	s.CreateWebhook(&model.Webhook{ID: "hook", URL: receiver.URL, Secret: "secret"}, testLog())
+
+	d := NewDispatcher(s, slog.Default())
+	d.MaxAttempts = 3
+	d.Backoff = time.Millisecond
+
+	delivery := &model.Delivery{ID: "1", WebhookID: "hook", Event: Ping, Status: model.DeliveryPending, NextAttempt: time.Now()}
+	s.CreateDelivery(delivery, testLog())
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+		d.deliverDue()
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("want 3 attempts, got %d", calls.Load())
+	}
+
+	dead, _ := s.ListDeliveries(store.DeliveryFilter{Status: model.DeliveryDead}, testLog())
+	if len(dead) != 1 || dead[0].ResponseCode != http.StatusInternalServerError {
+		t.Errorf("want one dead delivery, got %+v", dead)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, slog.Default())
+	d.Backoff = time.Second
+	d.MaxBackoff = 10 * time.Second
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 80: 10 * time.Second} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("attempt %d: want %s, got %s", attempts, want, got)
+		}
+	}
+}
+
+// failingUpdates can't record the outcome of a delivery.
+type failingUpdates struct {
+	*store.GormWebhookStore
+}
+
+func (failingUpdates) UpdateDelivery(*model.Delivery, logger.ILogDetail) error {
+	return errors.New("disk full")
+}
+
+func TestDispatchLogging(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer receiver.Close()
+
+	s := newStore(t)
+	var buf bytes.Buffer
+	d := NewDispatcher(failingUpdates{s}, slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	d.deliverDue()
+	if buf.Len() != 0 {
+		t.Errorf("want nothing logged without deliveries due, got:\n%s", buf.String())
+	}
+
+	s.CreateWebhook(&model.Webhook{ID: "hook", URL: receiver.URL, Secret: "secret"}, testLog())
+	s.CreateDelivery(&model.Delivery{ID: "1", WebhookID: "hook", Event: Ping, Status: model.DeliveryPending, NextAttempt: time.Now()}, testLog())
+	d.deliverDue()
+	if !bytes.Contains(buf.Bytes(), []byte("record webhook delivery outcome")) {
+		t.Errorf("want the failed update logged, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/todoapi/model"
+	"github.com/sing3demons/todoapi/router"
+	"github.com/sing3demons/todoapi/store"
+)
+
+type Handler struct {
+	store      store.WebhookStorer
+	dispatcher *Dispatcher
+}
+
+func NewHandler(store store.WebhookStorer, dispatcher *Dispatcher) *Handler {
+	return &Handler{store: store, dispatcher: dispatcher}
+}
+
+func (h *Handler) Register(c router.IContext) {
+	cmd := "register webhook"
+	node := "client"
+	logger := c.Log("register_webhook")
+	logger.AddInput(node, cmd, c.Incoming())
+
+	var hook model.Webhook
+	if err := c.Bind(&hook); err != nil {
+		logger.AddError(node, cmd, "output", map[string]any{"error": "bad_request"}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		err := fmt.Errorf("url must be an absolute http(s) url")
+		logger.AddError(node, cmd, "output", map[string]any{"error": "bad_request"}, err)
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	if hook.Secret == "" {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		hook.Secret = hex.EncodeToString(secret)
+	}
+	hook.ID = uuid.New().String()
+	hook.CreatedAt = time.Now()
+
+	if err := h.store.CreateWebhook(&hook, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	logger.AddOutput(node, cmd, map[string]any{"id": hook.ID}).End()
+	// the secret is only shown once
+	c.JSON(http.StatusCreated, hook)
+}
+
+func (h *Handler) List(c router.IContext) {
+	cmd := "list webhook"
+	logger := c.Log("list_webhook")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	hooks, err := h.store.ListWebhooks(logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	logger.AddOutput("client", cmd, hooks).End()
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *Handler) Delete(c router.IContext) {
+	cmd := "delete webhook"
+	logger := c.Log("delete_webhook")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	id := c.Param("id")
+	if err := h.store.DeleteWebhook(id, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	data := map[string]any{"ID": id, "status": "success"}
+	logger.AddOutput("client", cmd, data).End()
+	c.JSON(http.StatusOK, data)
+}
+
+// Test sends a ping to the webhook right away and returns the outcome.
+func (h *Handler) Test(c router.IContext) {
+	cmd := "test webhook"
+	logger := c.Log("test_webhook")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	hook, err := h.store.FindWebhook(c.Param("id"), logger)
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": err.Error()})
+		return
+	}
+
+	id := uuid.New().String()
+	body, _ := json.Marshal(Payload{ID: id, Event: Ping, Time: time.Now()})
+	delivery := model.Delivery{
+		ID:          id,
+		WebhookID:   hook.ID,
+		Event:       Ping,
+		Payload:     string(body),
+		Status:      model.DeliveryPending,
+		NextAttempt: time.Now(),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := h.store.CreateDelivery(&delivery, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	h.dispatcher.Deliver(&delivery, logger)
+
+	logger.AddOutput("client", cmd, delivery).End()
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Deliveries lists the deliveries of one webhook, or of every webhook when
+// the route has no id. ?status=dead gives the dead-letter list.
+func (h *Handler) Deliveries(c router.IContext) {
+	cmd := "list delivery"
+	logger := c.Log("list_delivery")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	deliveries, err := h.store.ListDeliveries(store.DeliveryFilter{
+		WebhookID: c.Param("id"),
+		Status:    c.Query("status"),
+		Limit:     100,
+	}, logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	logger.AddOutput("client", cmd, len(deliveries)).End()
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Retry puts a dead delivery back in the queue.
+func (h *Handler) Retry(c router.IContext) {
+	cmd := "retry delivery"
+	logger := c.Log("retry_delivery")
+	logger.AddInput("client", cmd, c.Incoming())
+
+	delivery, err := h.store.FindDelivery(c.Param("id"), logger)
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": err.Error()})
+		return
+	}
+
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now()
+	if err := h.store.UpdateDelivery(delivery, logger); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	logger.AddOutput("client", cmd, delivery.ID).End()
+	c.JSON(http.StatusOK, delivery)
+}